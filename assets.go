@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/zellyn/bedrockprune/resources"
+)
+
+// assetEntry is one cached file in the output of the assets command.
+type assetEntry struct {
+	File string `json:"file"`
+	Size int64  `json:"size"`
+}
+
+func runAssets(args []string) error {
+	f := newFlags("assets")
+	download := f.Bool("download", false, "download the latest Bedrock and Java client assets, if they aren't cached yet")
+	f.Parse(args)
+
+	if *download {
+		ctx := context.Background()
+		fmt.Fprintf(os.Stderr, "Checking for Bedrock assets...\n")
+		bedrockZip, err := resources.LatestBedrockReleaseClientZip(ctx)
+		if err != nil {
+			return err
+		}
+		bedrockZip.Close()
+		fmt.Fprintf(os.Stderr, "Checking for Java assets...\n")
+		javaZip, err := resources.LatestJavaReleaseClientZip(ctx)
+		if err != nil {
+			return err
+		}
+		javaZip.Close()
+	}
+
+	cacheDir, err := resources.CacheDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	assets := []assetEntry{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		assets = append(assets, assetEntry{File: filepath.Join(cacheDir, entry.Name()), Size: info.Size()})
+	}
+	if *f.json {
+		return writeJSON(assets)
+	}
+	if len(assets) == 0 {
+		fmt.Printf("No assets cached in %s; run assets -download\n", cacheDir)
+	}
+	for _, a := range assets {
+		fmt.Printf("%s  %d bytes\n", a.File, a.Size)
+	}
+	return nil
+}
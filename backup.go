@@ -0,0 +1,137 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/zellyn/bedrockprune/backup"
+	"golang.org/x/exp/maps"
+)
+
+// formatChanges describes the key changes of a snapshot briefly, like
+// "OverworldSubChunkPrefix +3 ~10 -1", largest first.
+func formatChanges(changes map[string]*backup.KeyChanges) string {
+	total := func(c *backup.KeyChanges) int { return c.Added + c.Changed + c.Removed }
+	types := maps.Keys(changes)
+	slices.SortFunc(types, func(a, b string) int {
+		return cmp.Or(cmp.Compare(total(changes[b]), total(changes[a])), cmp.Compare(a, b))
+	})
+	var parts []string
+	for _, kt := range types {
+		c := changes[kt]
+		part := kt
+		for _, n := range []struct {
+			sign  string
+			count int
+		}{{"+", c.Added}, {"~", c.Changed}, {"-", c.Removed}} {
+			if n.count > 0 {
+				part += fmt.Sprintf(" %s%d", n.sign, n.count)
+			}
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runBackup(args []string) error {
+	f := newFlags("backup")
+	storeDir := f.String("store", "", "the backup store folder (created if needed)")
+	list := f.Bool("list", false, "list the snapshots in the store, with what changed in each")
+	restore := f.String("restore", "", "write the snapshot with this name to a new world folder given by -o")
+	out := f.String("o", "", "with -restore, the world folder to create")
+	remove := f.String("remove", "", "remove the snapshot with this name (its data is freed by -gc)")
+	gc := f.Bool("gc", false, "delete stored data no snapshot refers to")
+	f.Parse(args)
+
+	if *storeDir == "" {
+		return usageErrorf("-store is required")
+	}
+	ops := 0
+	for _, op := range []bool{*list, *restore != "", *remove != "", *gc} {
+		if op {
+			ops++
+		}
+	}
+	if ops > 1 {
+		return usageErrorf("use only one of -list, -restore, -remove and -gc")
+	}
+	if *restore != "" && *out == "" {
+		return usageErrorf("-restore needs -o")
+	}
+
+	store, err := backup.Open(*storeDir)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch {
+	case *list:
+		snapshots, err := store.List()
+		if err != nil {
+			return err
+		}
+		if *f.json {
+			return writeJSON(snapshots)
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("No snapshots in %s\n", *storeDir)
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s  %q: %d keys, %d bytes, %d new bytes; %s\n", s.Name, s.World, s.Keys, s.Bytes, s.NewBytes, formatChanges(s.Changes))
+		}
+		return nil
+
+	case *restore != "":
+		if err := store.Restore(*restore, *out); err != nil {
+			return err
+		}
+		if *f.json {
+			return writeJSON(map[string]string{"snapshot": *restore, "dir": *out})
+		}
+		fmt.Printf("Restored snapshot %s to %s\n", *restore, *out)
+		return nil
+
+	case *remove != "":
+		if err := store.Remove(*remove); err != nil {
+			return err
+		}
+		if *f.json {
+			return writeJSON(map[string]string{"removed": *remove})
+		}
+		fmt.Printf("Removed snapshot %s; run -gc to free its data\n", *remove)
+		return nil
+
+	case *gc:
+		count, size, err := store.GC()
+		if err != nil {
+			return err
+		}
+		if *f.json {
+			return writeJSON(map[string]any{"blobs": count, "bytes": size})
+		}
+		fmt.Printf("Deleted %d unreferenced blobs (%d bytes)\n", count, size)
+		return nil
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	m, stats, err := store.Backup(w)
+	if err != nil {
+		return err
+	}
+	if *f.json {
+		return writeJSON(map[string]any{"snapshot": m.Name, "stats": stats})
+	}
+	fmt.Printf("Backed up %q as snapshot %s: %d keys and %d files, %d bytes; %d new blobs, %d new bytes\n",
+		w.Name, m.Name, stats.Keys, stats.Files, stats.Bytes, stats.NewBlobs, stats.NewBytes)
+	return nil
+}
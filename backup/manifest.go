@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// Hash is the SHA-256 hash a blob is stored under.
+type Hash [sha256.Size]byte
+
+// HashOf returns the hash of a blob's contents.
+func HashOf(data []byte) Hash {
+	return sha256.Sum256(data)
+}
+
+// String returns the hash in hex.
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Entry is one leveldb key, or one file, in a snapshot, with the hash
+// of its value or contents.
+type Entry struct {
+	Key  []byte // A leveldb key, or a file's path relative to the world folder, with / separators
+	Hash Hash
+	Size int
+}
+
+// Manifest lists everything in one snapshot of a world.
+type Manifest struct {
+	Name    string // The snapshot's name, a timestamp like "20240131-235900"
+	Created time.Time
+	World   string  // The name of the world
+	Source  string  // Where the world was backed up from
+	Files   []Entry // The files of the world folder, other than the db
+	Keys    []Entry // The keys of the db, in order
+}
+
+// Size returns the total size of the snapshot's keys' values and files.
+func (m *Manifest) Size() int64 {
+	var size int64
+	for _, e := range m.Files {
+		size += int64(e.Size)
+	}
+	for _, e := range m.Keys {
+		size += int64(e.Size)
+	}
+	return size
+}
+
+// hashes returns the set of hashes the manifest refers to.
+func (m *Manifest) hashes() map[Hash]bool {
+	res := make(map[Hash]bool, len(m.Keys)+len(m.Files))
+	for _, e := range m.Files {
+		res[e.Hash] = true
+	}
+	for _, e := range m.Keys {
+		res[e.Hash] = true
+	}
+	return res
+}
+
+// Encode returns the manifest as gzipped gob.
+func (m *Manifest) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(m); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseManifest decodes a manifest written by Encode.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest: %w", err)
+	}
+	defer zr.Close()
+	var m Manifest
+	if err := gob.NewDecoder(zr).Decode(&m); err != nil {
+		return nil, fmt.Errorf("unable to decode manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// KeyChanges counts the keys of one type that changed between two
+// snapshots.
+type KeyChanges struct {
+	Added   int `json:"added"`
+	Changed int `json:"changed"`
+	Removed int `json:"removed"`
+}
+
+// walkKeys calls fn for each key that differs between two manifests,
+// in key order, with how it changed. Either can be nil, for an empty
+// snapshot.
+func walkKeys(old, new *Manifest, fn func(key []byte, change parse.Change)) {
+	var oldKeys, newKeys []Entry
+	if old != nil {
+		oldKeys = old.Keys
+	}
+	if new != nil {
+		newKeys = new.Keys
+	}
+	i, j := 0, 0
+	for i < len(oldKeys) || j < len(newKeys) {
+		c := 0
+		switch {
+		case j == len(newKeys):
+			c = -1
+		case i == len(oldKeys):
+			c = 1
+		default:
+			c = bytes.Compare(oldKeys[i].Key, newKeys[j].Key)
+		}
+		switch {
+		case c < 0:
+			fn(oldKeys[i].Key, parse.ChangeRemoved)
+			i++
+		case c > 0:
+			fn(newKeys[j].Key, parse.ChangeAdded)
+			j++
+		default:
+			if oldKeys[i].Hash != newKeys[j].Hash {
+				fn(newKeys[j].Key, parse.ChangeChanged)
+			}
+			i++
+			j++
+		}
+	}
+}
+
+// CompareKeys returns the keys added, changed and removed between two
+// manifests, by key type. Either can be nil, for an empty snapshot.
+func CompareKeys(old, new *Manifest) map[parse.KeyType]*KeyChanges {
+	res := make(map[parse.KeyType]*KeyChanges)
+	walkKeys(old, new, func(key []byte, change parse.Change) {
+		kt := parse.NewKeyVal(key, nil).KeyType()
+		if res[kt] == nil {
+			res[kt] = &KeyChanges{}
+		}
+		switch change {
+		case parse.ChangeAdded:
+			res[kt].Added++
+		case parse.ChangeChanged:
+			res[kt].Changed++
+		case parse.ChangeRemoved:
+			res[kt].Removed++
+		}
+	})
+	return res
+}
+
+// ChangedChunks returns the chunks of one dimension with records that
+// differ between two manifests, without reading any blobs. Either can
+// be nil, for an empty snapshot.
+func ChangedChunks(old, new *Manifest, dimension world.Dimension) map[world.ChunkPos]bool {
+	res := make(map[world.ChunkPos]bool)
+	walkKeys(old, new, func(key []byte, change parse.Change) {
+		info := parse.NewKeyVal(key, nil).KeyTypeAndChunkLocation()
+		if info.HasLocation && info.Dimension == dimension {
+			res[info.ChunkPos] = true
+		}
+	})
+	return res
+}
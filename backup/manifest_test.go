@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	m := &Manifest{
+		Name:    "20240131-235900",
+		Created: time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC),
+		World:   "Test World",
+		Files:   []Entry{{Key: []byte("level.dat"), Hash: HashOf([]byte("dat")), Size: 3}},
+		Keys:    []Entry{{Key: []byte("~local_player"), Hash: HashOf([]byte("player")), Size: 6}},
+	}
+	val, err := m.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseManifest(bytes.NewReader(val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("want %+v; got %+v", m, got)
+	}
+	if got.Size() != 9 {
+		t.Errorf("want size 9; got %d", got.Size())
+	}
+}
+
+func TestCompareKeys(t *testing.T) {
+	entry := func(key []byte, val string) Entry {
+		return Entry{Key: key, Hash: HashOf([]byte(val))}
+	}
+	digp := append([]byte("digp"), make([]byte, 8)...)
+	old := &Manifest{Keys: []Entry{
+		entry([]byte("actorprefix12345678"), "pig"),
+		entry(digp, "a"),
+		entry([]byte("map_-1"), "map"),
+	}}
+	new := &Manifest{Keys: []Entry{
+		entry([]byte("actorprefix12345678"), "pig"),
+		entry([]byte("actorprefix87654321"), "cow"),
+		entry(digp, "b"),
+	}}
+	want := map[parse.KeyType]*KeyChanges{
+		parse.KeyTypeActorprefix: {Added: 1},
+		parse.KeyTypeDigp:        {Changed: 1},
+		parse.KeyTypeMap:         {Removed: 1},
+	}
+	if got := CompareKeys(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
@@ -0,0 +1,416 @@
+// Package backup keeps incremental backups of worlds in a
+// content-addressed store: every leveldb value and file is stored once
+// as a blob, under its hash, and each snapshot is a manifest mapping
+// keys and file paths to hashes. Chunks that don't change between
+// backups cost nothing after the first.
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/opt"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+const (
+	blobsDir     = "blobs"     // A leveldb of blobs, keyed by hash
+	snapshotsDir = "snapshots" // One manifest file per snapshot
+	manifestExt  = ".manifest"
+
+	// batchSize is the number of blobs or keys written per leveldb
+	// batch.
+	batchSize = 1000
+)
+
+// now is when Backup takes a snapshot. Tests replace it, since
+// snapshots are named to the second.
+var now = time.Now
+
+// Store is a backup store in a folder.
+type Store struct {
+	Dir   string
+	blobs *leveldb.DB
+}
+
+// Open opens the backup store in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, snapshotsDir), 0o755); err != nil {
+		return nil, err
+	}
+	blobs, err := leveldb.OpenFile(filepath.Join(dir, blobsDir), save.DBOptions(false))
+	if err != nil {
+		return nil, fmt.Errorf("error opening blob store: %w", err)
+	}
+	return &Store{Dir: dir, blobs: blobs}, nil
+}
+
+// IsStore reports whether dir looks like a backup store.
+func IsStore(dir string) bool {
+	for _, sub := range []string{blobsDir, snapshotsDir} {
+		if fi, err := os.Stat(filepath.Join(dir, sub)); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.blobs.Close()
+}
+
+// Stats describes what a backup added to the store.
+type Stats struct {
+	Keys     int   `json:"keys"`
+	Files    int   `json:"files"`
+	Bytes    int64 `json:"bytes"`     // Total size of the snapshot
+	NewBlobs int   `json:"new_blobs"` // Blobs the store didn't have yet
+	NewBytes int64 `json:"new_bytes"` // Their total size
+}
+
+// blobWriter adds blobs to the store in batches, skipping those it
+// already has.
+type blobWriter struct {
+	s     *Store
+	batch *leveldb.Batch
+	added map[Hash]bool
+	stats *Stats
+}
+
+// add stores data if it's new, and returns its entry.
+func (bw *blobWriter) add(key, data []byte) (Entry, error) {
+	h := HashOf(data)
+	e := Entry{Key: slices.Clone(key), Hash: h, Size: len(data)}
+	bw.stats.Bytes += int64(len(data))
+	if bw.added[h] {
+		return e, nil
+	}
+	has, err := bw.s.blobs.Has(h[:], nil)
+	if err != nil || has {
+		return e, err
+	}
+	bw.added[h] = true
+	bw.stats.NewBlobs++
+	bw.stats.NewBytes += int64(len(data))
+	bw.batch.Put(h[:], data)
+	if bw.batch.Len() >= batchSize {
+		return e, bw.flush()
+	}
+	return e, nil
+}
+
+func (bw *blobWriter) flush() error {
+	err := bw.s.blobs.Write(bw.batch, nil)
+	bw.batch.Reset()
+	return err
+}
+
+// Backup adds a snapshot of the world to the store, and returns its
+// manifest. The snapshot is named after the current time.
+func (s *Store) Backup(w *save.World) (*Manifest, Stats, error) {
+	var stats Stats
+	m := &Manifest{Created: now(), World: w.Name, Source: w.Source}
+	m.Name = m.Created.Format("20060102-150405")
+	manifestPath := s.manifestPath(m.Name)
+	if _, err := os.Stat(manifestPath); err == nil {
+		return nil, stats, fmt.Errorf("snapshot %q already exists", m.Name)
+	}
+	bw := &blobWriter{s: s, batch: new(leveldb.Batch), added: make(map[Hash]bool), stats: &stats}
+
+	err := filepath.WalkDir(w.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(w.Dir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel == "db" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		e, err := bw.add([]byte(filepath.ToSlash(rel)), data)
+		m.Files = append(m.Files, e)
+		return err
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+
+	iter := w.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		e, err := bw.add(iter.Key(), iter.Value())
+		if err != nil {
+			return nil, stats, err
+		}
+		m.Keys = append(m.Keys, e)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, stats, err
+	}
+	if err := bw.flush(); err != nil {
+		return nil, stats, err
+	}
+	stats.Keys, stats.Files = len(m.Keys), len(m.Files)
+
+	// The manifest goes last, so a snapshot never refers to blobs
+	// that aren't stored yet.
+	val, err := m.Encode()
+	if err != nil {
+		return nil, stats, err
+	}
+	if err := os.WriteFile(manifestPath, val, 0o644); err != nil {
+		return nil, stats, err
+	}
+	return m, stats, nil
+}
+
+// DBManifest returns a manifest of a world's leveldb keys, without
+// storing anything, to compare the world with its snapshots.
+func DBManifest(w *save.World) (*Manifest, error) {
+	m := &Manifest{World: w.Name, Source: w.Source}
+	iter := w.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		m.Keys = append(m.Keys, Entry{Key: slices.Clone(iter.Key()), Hash: HashOf(iter.Value()), Size: len(iter.Value())})
+	}
+	return m, iter.Error()
+}
+
+func (s *Store) manifestPath(name string) string {
+	return filepath.Join(s.Dir, snapshotsDir, name+manifestExt)
+}
+
+// Names returns the names of the snapshots in the store, oldest
+// first.
+func (s *Store) Names() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, snapshotsDir))
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), manifestExt); ok && !entry.IsDir() {
+			res = append(res, name)
+		}
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// Manifest reads the manifest of the named snapshot.
+func (s *Store) Manifest(name string) (*Manifest, error) {
+	f, err := os.Open(s.manifestPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no snapshot %q in %s", name, s.Dir)
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ParseManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %q: %w", name, err)
+	}
+	return m, nil
+}
+
+// SnapshotInfo describes one snapshot, and how it differs from the one
+// before it.
+type SnapshotInfo struct {
+	Name     string                 `json:"name"`
+	Created  time.Time              `json:"created"`
+	World    string                 `json:"world"`
+	Keys     int                    `json:"keys"`
+	Bytes    int64                  `json:"bytes"`     // Total size of the snapshot
+	NewBytes int64                  `json:"new_bytes"` // Size of the blobs the previous snapshot doesn't refer to
+	Changes  map[string]*KeyChanges `json:"changes"`   // Keys changed since the previous snapshot, by key type
+}
+
+// List describes the snapshots in the store, oldest first.
+func (s *Store) List() ([]SnapshotInfo, error) {
+	names, err := s.Names()
+	if err != nil {
+		return nil, err
+	}
+	res := []SnapshotInfo{}
+	var prev *Manifest
+	for _, name := range names {
+		m, err := s.Manifest(name)
+		if err != nil {
+			return nil, err
+		}
+		info := SnapshotInfo{
+			Name:    m.Name,
+			Created: m.Created,
+			World:   m.World,
+			Keys:    len(m.Keys),
+			Bytes:   m.Size(),
+			Changes: make(map[string]*KeyChanges),
+		}
+		var prevHashes map[Hash]bool
+		if prev != nil {
+			prevHashes = prev.hashes()
+		}
+		seen := make(map[Hash]bool)
+		for _, e := range slices.Concat(m.Files, m.Keys) {
+			if !prevHashes[e.Hash] && !seen[e.Hash] {
+				seen[e.Hash] = true
+				info.NewBytes += int64(e.Size)
+			}
+		}
+		for kt, c := range CompareKeys(prev, m) {
+			info.Changes[strings.TrimPrefix(kt.String(), "KeyType")] = c
+		}
+		res = append(res, info)
+		prev = m
+	}
+	return res, nil
+}
+
+// blob returns the blob with the given hash.
+func (s *Store) blob(h Hash) ([]byte, error) {
+	data, err := s.blobs.Get(h[:], nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("missing blob %s", h)
+	}
+	return data, err
+}
+
+// Restore writes the named snapshot to a new world folder dest, which
+// must not exist yet.
+func (s *Store) Restore(name, dest string) error {
+	m, err := s.Manifest(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	for _, e := range m.Files {
+		data, err := s.blob(e.Hash)
+		if err != nil {
+			return fmt.Errorf("file %s: %w", e.Key, err)
+		}
+		path := filepath.Join(dest, filepath.FromSlash(string(e.Key)))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	dbOpts := save.DBOptions(false)
+	dbOpts.ErrorIfExist = true
+	db, err := leveldb.OpenFile(filepath.Join(dest, "db"), dbOpts)
+	if err != nil {
+		return fmt.Errorf("error creating leveldb: %w", err)
+	}
+	defer db.Close()
+	batch := new(leveldb.Batch)
+	for _, e := range m.Keys {
+		data, err := s.blob(e.Hash)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", e.Key, err)
+		}
+		batch.Put(e.Key, data)
+		if batch.Len() >= batchSize {
+			if err := db.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return err
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		return fmt.Errorf("error compacting leveldb: %w", err)
+	}
+	return db.Close()
+}
+
+// Remove deletes the named snapshot's manifest. Its blobs stay in the
+// store until GC.
+func (s *Store) Remove(name string) error {
+	if _, err := s.Manifest(name); err != nil {
+		return err
+	}
+	return os.Remove(s.manifestPath(name))
+}
+
+// GC deletes the blobs no snapshot refers to, and returns how many it
+// deleted, and their total size.
+func (s *Store) GC() (int, int64, error) {
+	names, err := s.Names()
+	if err != nil {
+		return 0, 0, err
+	}
+	referenced := make(map[Hash]bool)
+	for _, name := range names {
+		m, err := s.Manifest(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		for h := range m.hashes() {
+			referenced[h] = true
+		}
+	}
+
+	var count int
+	var size int64
+	batch := new(leveldb.Batch)
+	iter := s.blobs.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		var h Hash
+		if len(iter.Key()) != len(h) {
+			continue
+		}
+		copy(h[:], iter.Key())
+		if referenced[h] {
+			continue
+		}
+		count++
+		size += int64(len(iter.Value()))
+		batch.Delete(bytes.Clone(iter.Key()))
+		if batch.Len() >= batchSize {
+			if err := s.blobs.Write(batch, nil); err != nil {
+				return count, size, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return count, size, err
+	}
+	if err := s.blobs.Write(batch, nil); err != nil {
+		return count, size, err
+	}
+	if count > 0 {
+		if err := s.blobs.CompactRange(util.Range{}); err != nil {
+			return count, size, fmt.Errorf("error compacting blob store: %w", err)
+		}
+	}
+	return count, size, nil
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// makeWorld creates a world folder with the given keys and a
+// levelname.txt, and opens it.
+func makeWorld(t *testing.T, dir string, keys map[string]string) *save.World {
+	t.Helper()
+	db, err := leveldb.OpenFile(filepath.Join(dir, "db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range keys {
+		if err := db.Put([]byte(k), []byte(v), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "levelname.txt"), []byte("Test World"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := save.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// readKeys returns every key and value in a world's leveldb.
+func readKeys(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	db, err := leveldb.OpenFile(filepath.Join(dir, "db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res := make(map[string]string)
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		res[string(iter.Key())] = string(iter.Value())
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestStore(t *testing.T) {
+	clock := time.Date(2024, 1, 31, 23, 59, 0, 0, time.Local)
+	defer func(old func() time.Time) { now = old }(now)
+	now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	tmp := t.TempDir()
+	keys := map[string]string{"~local_player": "player", "map_-1": "map", "same": "player"}
+	w := makeWorld(t, filepath.Join(tmp, "world"), keys)
+	defer w.Close()
+	s, err := Open(filepath.Join(tmp, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	first, stats, err := s.Backup(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two keys share a value, so three keys and a file need three blobs.
+	if stats.Keys != 3 || stats.Files != 1 || stats.NewBlobs != 3 {
+		t.Errorf("first backup: want 3 keys, 1 file and 3 new blobs; got %+v", stats)
+	}
+	second, stats, err := s.Backup(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NewBlobs != 0 || stats.NewBytes != 0 {
+		t.Errorf("backup of an unchanged world: want no new blobs; got %+v", stats)
+	}
+
+	if err := w.DB.Put([]byte("map_-1"), []byte("new map"), nil); err != nil {
+		t.Fatal(err)
+	}
+	third, stats, err := s.Backup(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.NewBlobs != 1 {
+		t.Errorf("backup after changing a key: want 1 new blob; got %+v", stats)
+	}
+
+	restored := filepath.Join(tmp, "restored")
+	if err := s.Restore(first.Name, restored); err != nil {
+		t.Fatal(err)
+	}
+	got := readKeys(t, restored)
+	if len(got) != len(keys) {
+		t.Errorf("restored keys: want %v; got %v", keys, got)
+	}
+	for k, v := range keys {
+		if got[k] != v {
+			t.Errorf("restored key %q: want %q; got %q", k, v, got[k])
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(restored, "levelname.txt")); err != nil || string(data) != "Test World" {
+		t.Errorf("restored levelname.txt: want %q; got %q, %v", "Test World", data, err)
+	}
+	if err := s.Restore(first.Name, restored); err == nil {
+		t.Error("want an error restoring over an existing folder")
+	}
+
+	// Only the first two snapshots refer to the old map.
+	if count, _, err := s.GC(); err != nil || count != 0 {
+		t.Errorf("GC with every snapshot: want 0 blobs deleted; got %d, %v", count, err)
+	}
+	for _, name := range []string{first.Name, second.Name} {
+		if err := s.Remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, size, err := s.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || size != int64(len("map")) {
+		t.Errorf("GC: want 1 blob of %d bytes deleted; got %d of %d", len("map"), count, size)
+	}
+	if names, err := s.Names(); err != nil || len(names) != 1 || names[0] != third.Name {
+		t.Errorf("want only snapshot %s left; got %v, %v", third.Name, names, err)
+	}
+
+	restored = filepath.Join(tmp, "restored-third")
+	if err := s.Restore(third.Name, restored); err != nil {
+		t.Fatalf("restoring after GC: %v", err)
+	}
+	got = readKeys(t, restored)
+	if got["map_-1"] != "new map" || got["same"] != "player" {
+		t.Errorf("want the new map and the shared value after GC; got %v", got)
+	}
+}
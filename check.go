@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+func runCheck(args []string) error {
+	f := newFlags("check")
+	warnings := f.Bool("warnings", true, "list warnings as well as errors")
+	f.Parse(args)
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	report, err := parse.CheckWorld(w.DB)
+	if err != nil {
+		return err
+	}
+	errorCount, warningCount := report.Count(parse.SeverityError), report.Count(parse.SeverityWarning)
+
+	if *f.json {
+		if err := writeJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range report.Problems {
+			if p.Severity == parse.SeverityWarning && !*warnings {
+				continue
+			}
+			where := ""
+			if p.Chunk != nil {
+				where = fmt.Sprintf(" %s %d,%d", p.Chunk.Dimension, p.Chunk.X, p.Chunk.Z)
+			}
+			fmt.Printf("%-7s %-14s%s %s: %s\n", p.Severity, p.Check, where, p.Key, p.Message)
+		}
+		fmt.Printf("Checked %d keys in %d chunks of %q: %d errors, %d warnings\n", report.Keys, report.Chunks, w.Name, errorCount, warningCount)
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("%q has %d errors", w.Name, errorCount)
+	}
+	return nil
+}
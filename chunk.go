@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// chunkColumn is the top block of one column of a chunk.
+type chunkColumn struct {
+	X     int            `json:"x"`
+	Z     int            `json:"z"`
+	Y     int32          `json:"y"`
+	Block map[string]any `json:"block"`
+}
+
+// chunkInfo is the output of the chunk command.
+type chunkInfo struct {
+	*parse.ChunkReport
+	TopBlocks []chunkColumn `json:"top_blocks,omitempty"`
+}
+
+// parseBlockPos parses a block position given as "x,z" or "x,y,z",
+// and returns the chunk it's in.
+func parseBlockPos(s string) (world.ChunkPos, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 2 || len(parts) == 3 {
+		x, errX := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 32)
+		z, errZ := strconv.ParseInt(strings.TrimSpace(parts[len(parts)-1]), 10, 32)
+		if errX == nil && errZ == nil {
+			return world.ChunkPos{int32(x) >> 4, int32(z) >> 4}, nil
+		}
+	}
+	return world.ChunkPos{}, usageErrorf("want block as x,z or x,y,z; got %q", s)
+}
+
+func runChunk(args []string) error {
+	f := newFlags("chunk")
+	f.addDim("overworld", false)
+	at := f.String("at", "", "chunk to show, as x,z in chunk coordinates")
+	blockString := f.String("block", "", "show the chunk holding this block, as x,z or x,y,z in block coordinates")
+	top := f.Bool("top", false, "also show the top block of each column")
+	layer := f.Int("layer", 0, "with -top, the storage layer to show the top blocks of")
+	f.Parse(args)
+
+	var chunkPos world.ChunkPos
+	var err error
+	switch {
+	case *at != "" && *blockString != "":
+		return usageErrorf("use -at or -block, not both")
+	case *at != "":
+		chunkPos, err = parseChunkPos(*at)
+	case *blockString != "":
+		chunkPos, err = parseBlockPos(*blockString)
+	default:
+		return usageErrorf("chunk needs -at or -block")
+	}
+	if err != nil {
+		return err
+	}
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	report, err := parse.ReportChunk(w.DB, chunkPos, dimension)
+	if err != nil {
+		return err
+	}
+	info := chunkInfo{ChunkReport: report}
+	if *top {
+		if info.TopBlocks, err = topBlocks(w.DB, chunkPos, dimension, *layer); err != nil {
+			return err
+		}
+	}
+
+	if *f.json {
+		return writeJSON(info)
+	}
+	printChunkReport(report)
+	if *top {
+		fmt.Printf("Top blocks (layer %d):\n", *layer)
+		for _, c := range info.TopBlocks {
+			fmt.Printf("  (%02d,%02d) y=%d: %v\n", c.X, c.Z, c.Y, c.Block)
+		}
+	}
+	return nil
+}
+
+func printChunkReport(r *parse.ChunkReport) {
+	fmt.Printf("Chunk (%d,%d) in %s (blocks %d,%d to %d,%d)\n", r.X, r.Z, r.Dimension, r.X*16, r.Z*16, r.X*16+15, r.Z*16+15)
+	if r.Version != nil {
+		fmt.Printf("Version: %d\n", *r.Version)
+	}
+	fmt.Printf("Keys:\n")
+	for _, k := range r.Keys {
+		if k.YIndex != nil {
+			fmt.Printf("  %s %d: %d bytes\n", k.Tag, *k.YIndex, k.Size)
+		} else {
+			fmt.Printf("  %s: %d bytes\n", k.Tag, k.Size)
+		}
+	}
+	fmt.Printf("Sub-chunks:\n")
+	for _, sc := range r.SubChunks {
+		fmt.Printf("  y=%d..%d: version %d, %d layers\n", sc.YIndex*16, sc.YIndex*16+15, sc.Version, len(sc.Layers))
+		for i, l := range sc.Layers {
+			fmt.Printf("    layer %d: %d bits per block, %d palette entries\n", i, l.BitsPerBlock, len(l.Palette))
+			for j, block := range l.Palette {
+				states, _ := block["states"].(map[string]any)
+				fmt.Printf("      %5d %v %s\n", l.Counts[j], block["name"], formatStates(states))
+			}
+		}
+	}
+	if r.HeightMap != nil {
+		fmt.Printf("Height map (rows are z, columns x; - for no blocks):\n")
+		for z := range 16 {
+			fmt.Printf(" ")
+			for x := range 16 {
+				if y := r.HeightMap.Get(x, z); y == parse.NoHeight {
+					fmt.Printf("    -")
+				} else {
+					fmt.Printf(" %4d", y)
+				}
+			}
+			fmt.Println()
+		}
+	}
+	if len(r.Biomes) > 0 {
+		fmt.Printf("Biomes:\n")
+		for _, b := range r.Biomes {
+			name := b.Name
+			if name == "" {
+				name = "unknown"
+			}
+			fmt.Printf("  %6d blocks: %s (%d)\n", b.Count, name, b.ID)
+		}
+	}
+	printObjects("Block entities", r.BlockEntities)
+	printObjects("Entities", r.Entities)
+	for _, p := range r.Problems {
+		fmt.Printf("Problem: %s\n", p)
+	}
+}
+
+func printObjects(title string, objects []parse.ObjectSummary) {
+	if len(objects) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, o := range objects {
+		fmt.Printf("  %s", o.ID)
+		if o.UniqueID != nil {
+			fmt.Printf(" #%d", *o.UniqueID)
+		}
+		if o.Pos != nil {
+			fmt.Printf(" at %v", o.Pos)
+		}
+		if o.Legacy {
+			fmt.Printf(" (legacy)")
+		}
+		fmt.Println()
+	}
+}
+
+// topBlocks returns the highest block of each column of a chunk, in
+// the given storage layer.
+func topBlocks(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension, layer int) ([]chunkColumn, error) {
+	chunk, err := parse.GetChunk(db, chunkPos, dimension)
+	if err != nil {
+		return nil, err
+	}
+	var res []chunkColumn
+	hm := chunk.GetHeightMap(layer)
+	for z := range 16 {
+		for x := range 16 {
+			y := hm.Get(x, z)
+			if y == parse.NoHeight {
+				continue
+			}
+			block, err := chunk.GetBlock(x, z, y, layer)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, chunkColumn{X: x, Z: z, Y: y, Block: block})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// chunkDiffEntry is one chunk in the output of the diff command, with
+// its changed blocks if -blocks is set.
+type chunkDiffEntry struct {
+	parse.ChunkDiff
+	Blocks []parse.BlockDiff `json:"blocks,omitempty"`
+}
+
+// openOtherWorld opens a second world read-only, found the same way as
+// -world, for subcommands that compare two worlds.
+func openOtherWorld(name string) (*save.World, error) {
+	worldPath, err := save.Find(name)
+	if err != nil {
+		return nil, err
+	}
+	return save.Open(worldPath, true)
+}
+
+// changedBlocks returns the blocks that differ in the sub-chunks of a
+// changed chunk.
+func changedBlocks(oldWorld, newWorld *save.World, c parse.ChunkDiff) ([]parse.BlockDiff, error) {
+	var res []parse.BlockDiff
+	for _, yIndex := range c.SubChunks() {
+		blocks, err := parse.DiffSubChunkBlocks(oldWorld.DB, newWorld.DB, c.Pos(), c.WorldDimension(), yIndex)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, blocks...)
+	}
+	return res, nil
+}
+
+// blockName returns a block's name without the "minecraft:" prefix.
+func blockName(block map[string]any) string {
+	name, _ := block["name"].(string)
+	return strings.TrimPrefix(name, "minecraft:")
+}
+
+func runDiff(args []string) error {
+	f := newFlags("diff")
+	f.addDim("all", true)
+	oldName := f.String("old", "", "the earlier world to compare -world with: a backup, snapshot folder, .mcworld file, or world name")
+	blocks := f.Bool("blocks", false, "list the blocks that changed in each changed chunk")
+	maxBlocks := f.Int("max-blocks", 20, "with -blocks, the most changed blocks to print per chunk (0 for all); -json always has all")
+	f.Parse(args)
+	if *oldName == "" {
+		return usageErrorf("-old is required")
+	}
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+
+	oldWorld, err := openOtherWorld(*oldName)
+	if err != nil {
+		return err
+	}
+	defer oldWorld.Close()
+	newWorld, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer newWorld.Close()
+
+	diff, err := parse.DiffWorlds(oldWorld.DB, newWorld.DB)
+	if err != nil {
+		return err
+	}
+	entries := []chunkDiffEntry{}
+	for _, c := range diff.Chunks {
+		if dimension != nil && c.WorldDimension() != dimension {
+			continue
+		}
+		e := chunkDiffEntry{ChunkDiff: c}
+		if *blocks && c.Change == parse.ChangeChanged {
+			if e.Blocks, err = changedBlocks(oldWorld, newWorld, c); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	if *f.json {
+		return writeJSON(map[string]any{
+			"old":     oldWorld.Name,
+			"new":     newWorld.Name,
+			"chunks":  entries,
+			"globals": diff.Globals,
+		})
+	}
+
+	fmt.Printf("Changes from %q to %q\n", oldWorld.Name, newWorld.Name)
+	for _, dim := range []world.Dimension{world.Overworld, world.Nether, world.End} {
+		if dimension != nil && dim != dimension {
+			continue
+		}
+		added, removed, changed := diff.Counts(dim)
+		fmt.Printf("%v: %d chunks added, %d removed, %d changed\n", dim, added, removed, changed)
+	}
+	for _, e := range entries {
+		fmt.Printf("  %s %v %d,%d: %s\n", e.Change, e.WorldDimension(), e.X, e.Z, e.Summary())
+		for i, b := range e.Blocks {
+			if *maxBlocks > 0 && i == *maxBlocks {
+				fmt.Printf("    ... and %d more blocks\n", len(e.Blocks)-i)
+				break
+			}
+			layer := ""
+			if b.Layer > 0 {
+				layer = fmt.Sprintf(" (layer %d)", b.Layer)
+			}
+			fmt.Printf("    %d,%d,%d%s: %s -> %s\n", b.X, b.Y, b.Z, layer, blockName(b.From), blockName(b.To))
+		}
+	}
+	if len(diff.Globals) > 0 {
+		fmt.Printf("Global records: %d\n", len(diff.Globals))
+		for _, g := range diff.Globals {
+			fmt.Printf("  %s %s (%s)\n", g.Change, g.Key, strings.TrimPrefix(g.Type, "KeyType"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package edit
+
+import (
+	"fmt"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// RemoveAirSubChunks returns a Plan that deletes every sub-chunk whose
+// layers hold nothing but air. Missing sub-chunks read as air, so this
+// changes no visible terrain, and heightmaps stay valid. Sub-chunks
+// that fail to decode are left alone and noted in the plan. If
+// dimension is nil, all dimensions are included.
+func RemoveAirSubChunks(db *leveldb.DB, dimension world.Dimension) (*Plan, error) {
+	description := "Remove all-air sub-chunks in all dimensions"
+	if dimension != nil {
+		description = fmt.Sprintf("Remove all-air sub-chunks in %v", dimension)
+	}
+	plan := NewPlan(description)
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := parse.NewKeyVal(iter.Key(), iter.Value())
+		keyInfo := kv.KeyTypeAndChunkLocation()
+		if !keyInfo.HasLocation || !keyInfo.KeyType.IsSubChunkPrefix() {
+			continue
+		}
+		if dimension != nil && keyInfo.Dimension != dimension {
+			continue
+		}
+		sc, err := parse.ParseSubChunk(kv)
+		if err != nil {
+			plan.Notef("skipped sub-chunk %d of chunk %v in %v: %v", int8(kv.Key[len(kv.Key)-1]), keyInfo.ChunkPos, keyInfo.Dimension, err)
+			continue
+		}
+		if sc.AllAir() {
+			plan.Delete(kv.Key, kv.Val)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,69 @@
+package edit
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+func TestRemoveAirSubChunks(t *testing.T) {
+	ow, nether := world.Overworld, world.Nether
+	pos := world.ChunkPos{0, 0}
+	encode := func(sc parse.SubChunk) string {
+		val, err := sc.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(val)
+	}
+	twoLayers := parse.NewSubChunk(1)
+	twoLayers.SetBlock(0, 0, 0, 1, parse.AirBlock())
+	water, _ := parse.NewBlock("water", "liquid_depth=0")
+	waterlogged := parse.NewSubChunk(3)
+	waterlogged.SetBlock(0, 0, 0, 1, water)
+
+	keys := map[string]string{
+		chunkKey(pos, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(pos, ow, 0):                       stoneSubChunk(t, 0),
+		subChunkKey(pos, ow, 1):                       encode(twoLayers),
+		subChunkKey(pos, ow, 2):                       stoneSubChunk(t, 2, cube.Pos{1, 2, 3}),
+		subChunkKey(pos, ow, 3):                       encode(waterlogged),
+		// A sub-chunk version ParseSubChunk doesn't know.
+		subChunkKey(pos, ow, 4):     "\x07\x01\x04",
+		subChunkKey(pos, nether, 0): stoneSubChunk(t, 0),
+	}
+	db := memDB(t, keys)
+
+	plan, err := RemoveAirSubChunks(db, ow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(plan.Notes, func(note string) bool { return strings.HasPrefix(note, "skipped sub-chunk 4 of chunk") }) {
+		t.Errorf("want a note skipping sub-chunk 4; got %q", plan.Notes)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{}
+	for k, v := range keys {
+		want[k] = v
+	}
+	delete(want, subChunkKey(pos, ow, 0))
+	delete(want, subChunkKey(pos, ow, 1))
+	checkKeys(t, dumpDB(t, db), want)
+
+	// With no dimension, the nether's all-air sub-chunk goes too.
+	plan, err = RemoveAirSubChunks(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, subChunkKey(pos, nether, 0))
+	checkKeys(t, dumpDB(t, db), want)
+}
@@ -0,0 +1,387 @@
+package edit
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+	"golang.org/x/exp/maps"
+)
+
+// chunkEdit holds the records of one chunk while it's being edited.
+type chunkEdit struct {
+	chunkPos  world.ChunkPos
+	dimension world.Dimension
+	records   map[parse.LevelChunkTag][]*parse.KeyVal
+	subChunks map[int32]*subChunkEdit
+	deleted   map[int32]bool // y indexes of sub-chunks to delete
+
+	// Block positions whose block entities should be removed, and
+	// block entities to add after removing them.
+	removeBlockEntities map[cube.Pos]bool
+	addBlockEntities    []map[string]any
+}
+
+type subChunkEdit struct {
+	kv      *parse.KeyVal // nil for a new sub-chunk
+	sc      parse.SubChunk
+	changed bool
+}
+
+// loadChunkEdit reads the records of a chunk. It returns nil if the
+// chunk has no records at all.
+func loadChunkEdit(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension) (*chunkEdit, error) {
+	kvs, err := parse.AllEntriesWithChunkCoordinatePrefix(db, chunkPos, dimension)
+	if err != nil {
+		return nil, err
+	}
+	ce := &chunkEdit{
+		chunkPos:            chunkPos,
+		dimension:           dimension,
+		records:             make(map[parse.LevelChunkTag][]*parse.KeyVal),
+		subChunks:           make(map[int32]*subChunkEdit),
+		deleted:             make(map[int32]bool),
+		removeBlockEntities: make(map[cube.Pos]bool),
+	}
+	found := false
+	for _, kv := range kvs {
+		kt := kv.KeyType()
+		if !kt.IsChunkDataForDimension(dimension) {
+			continue
+		}
+		found = true
+		lct := kt.LevelChunkTag()
+		ce.records[lct] = append(ce.records[lct], kv)
+	}
+	if !found {
+		return nil, nil
+	}
+	return ce, nil
+}
+
+// record returns the chunk's record with the given tag, or nil.
+func (ce *chunkEdit) record(tag parse.LevelChunkTag) *parse.KeyVal {
+	if kvs := ce.records[tag]; len(kvs) > 0 {
+		return kvs[0]
+	}
+	return nil
+}
+
+// findSubChunk returns the record of the sub-chunk with the given y
+// index, or nil.
+func (ce *chunkEdit) findSubChunk(yIndex int32) *parse.KeyVal {
+	for _, kv := range ce.records[parse.LevelChunkTagSubChunkPrefix] {
+		if int32(int8(kv.Key[len(kv.Key)-1])) == yIndex {
+			return kv
+		}
+	}
+	return nil
+}
+
+// subChunk returns the sub-chunk with the given y index, parsing it if
+// needed. If the chunk has no such sub-chunk, it returns a new one if
+// create is true, or nil otherwise.
+func (ce *chunkEdit) subChunk(yIndex int32, create bool) (*subChunkEdit, error) {
+	if sce, ok := ce.subChunks[yIndex]; ok {
+		return sce, nil
+	}
+	if kv := ce.findSubChunk(yIndex); kv != nil {
+		sc, err := parse.ParseSubChunk(kv)
+		if err != nil {
+			return nil, err
+		}
+		sce := &subChunkEdit{kv: kv, sc: sc}
+		ce.subChunks[yIndex] = sce
+		return sce, nil
+	}
+	if !create {
+		return nil, nil
+	}
+	sce := &subChunkEdit{sc: parse.NewSubChunk(yIndex)}
+	ce.subChunks[yIndex] = sce
+	return sce, nil
+}
+
+// deleteSubChunk marks the sub-chunk with the given y index for
+// deletion.
+func (ce *chunkEdit) deleteSubChunk(yIndex int32) {
+	ce.deleted[yIndex] = true
+}
+
+// removeSubChunkBlockEntities marks the block entities at every
+// position in the sub-chunk with the given y index for removal.
+func (ce *chunkEdit) removeSubChunkBlockEntities(yIndex int32) {
+	baseX, baseY, baseZ := int(ce.chunkPos.X())<<4, int(yIndex)<<4, int(ce.chunkPos.Z())<<4
+	for x := range 16 {
+		for y := range 16 {
+			for z := range 16 {
+				ce.removeBlockEntities[cube.Pos{baseX + x, baseY + y, baseZ + z}] = true
+			}
+		}
+	}
+}
+
+// subChunkYIndexes returns the y indexes of all the chunk's existing
+// sub-chunks, in increasing order.
+func (ce *chunkEdit) subChunkYIndexes() []int32 {
+	var res []int32
+	for _, kv := range ce.records[parse.LevelChunkTagSubChunkPrefix] {
+		res = append(res, int32(int8(kv.Key[len(kv.Key)-1])))
+	}
+	slices.Sort(res)
+	return res
+}
+
+// finish adds the chunk's changes to the plan, including an updated
+// heightmap if any sub-chunks changed.
+func (ce *chunkEdit) finish(plan *Plan) error {
+	changed := false
+	for _, yIndex := range ce.subChunkYIndexes() {
+		if ce.deleted[yIndex] {
+			kv := ce.findSubChunk(yIndex)
+			plan.Delete(kv.Key, kv.Val)
+			changed = true
+		}
+	}
+
+	yIndexes := maps.Keys(ce.subChunks)
+	slices.Sort(yIndexes)
+	for _, yIndex := range yIndexes {
+		sce := ce.subChunks[yIndex]
+		if !sce.changed || ce.deleted[yIndex] {
+			continue
+		}
+		changed = true
+		val, err := sce.sc.Encode()
+		if err != nil {
+			return fmt.Errorf("error encoding sub-chunk %d of chunk %v in %v: %w", yIndex, ce.chunkPos, ce.dimension, err)
+		}
+		if sce.kv == nil {
+			plan.Put(parse.MakeSubChunkKey(ce.chunkPos, ce.dimension, yIndex), nil, val)
+		} else {
+			plan.Put(sce.kv.Key, sce.kv.Val, val)
+		}
+	}
+
+	if len(ce.removeBlockEntities) > 0 || len(ce.addBlockEntities) > 0 {
+		if err := ce.updateBlockEntities(plan); err != nil {
+			return err
+		}
+	}
+	if changed {
+		return ce.updateHeightMap(plan)
+	}
+	return nil
+}
+
+// updateHeightMap recomputes the heightmap stored in the chunk's
+// Data3D (or older Data2D) record from its sub-chunks as they'll be
+// once the plan is applied.
+func (ce *chunkEdit) updateHeightMap(plan *Plan) error {
+	kv := ce.record(parse.LevelChunkTagData3D)
+	if kv == nil {
+		kv = ce.record(parse.LevelChunkTagData2D)
+	}
+	if kv == nil {
+		return nil
+	}
+
+	var subChunks []parse.SubChunk
+	for _, yIndex := range ce.subChunkYIndexes() {
+		if ce.deleted[yIndex] {
+			continue
+		}
+		sce, err := ce.subChunk(yIndex, false)
+		if err != nil {
+			return err
+		}
+		subChunks = append(subChunks, sce.sc)
+	}
+	for yIndex, sce := range ce.subChunks {
+		if sce.kv == nil && sce.changed && !ce.deleted[yIndex] {
+			subChunks = append(subChunks, sce.sc)
+		}
+	}
+
+	val, err := parse.SetStoredHeightMap(kv.Val, parse.ComputeHeightMap(subChunks, 0), ce.dimension)
+	if err != nil {
+		return fmt.Errorf("error updating heightmap of chunk %v in %v: %w", ce.chunkPos, ce.dimension, err)
+	}
+	if !bytes.Equal(val, kv.Val) {
+		plan.Put(kv.Key, kv.Val, val)
+	}
+	return nil
+}
+
+// updateBlockEntities removes the block entities at the positions in
+// removeBlockEntities, then adds those in addBlockEntities.
+func (ce *chunkEdit) updateBlockEntities(plan *Plan) error {
+	kv := ce.record(parse.LevelChunkTagBlockEntity)
+	var records []parse.NBTRecord
+	if kv != nil {
+		var err error
+		if records, err = parse.DecodeNBTRecords(kv.Val); err != nil {
+			return fmt.Errorf("error decoding block entities of chunk %v in %v: %w", ce.chunkPos, ce.dimension, err)
+		}
+	}
+	kept := slices.DeleteFunc(slices.Clone(records), func(rec parse.NBTRecord) bool {
+		pos, ok := parse.NBTBlockPos(rec.Data)
+		return ok && ce.removeBlockEntities[pos]
+	})
+	if len(kept) == len(records) && len(ce.addBlockEntities) == 0 {
+		return nil
+	}
+	for _, data := range ce.addBlockEntities {
+		kept = append(kept, parse.NBTRecord{Data: data})
+	}
+	if len(kept) == 0 {
+		plan.Delete(kv.Key, kv.Val)
+		return nil
+	}
+	val, err := parse.EncodeNBTRecords(kept)
+	if err != nil {
+		return err
+	}
+	if kv == nil {
+		plan.Put(parse.MakeChunkKey(ce.chunkPos, ce.dimension, parse.LevelChunkTagBlockEntity), nil, val)
+	} else {
+		plan.Put(kv.Key, kv.Val, val)
+	}
+	return nil
+}
+
+// blockEditFunc changes the block at (x, y, z) within a sub-chunk,
+// and reports whether it changed anything.
+type blockEditFunc func(sc *parse.SubChunk, x, y, z int) bool
+
+// editBlocks calls edit for every block in box. Sub-chunks that don't
+// exist yet are created if create is true, but only in chunks that
+// already exist. Sub-chunks for which skip returns true are left
+// alone. Block entities are removed wherever the name of the block in
+// layer 0 changes.
+func editBlocks(db *leveldb.DB, dimension world.Dimension, box parse.Box, description string, create bool, skip func(parse.SubChunk) bool, edit blockEditFunc) (*Plan, error) {
+	plan := NewPlan(description)
+	box, ok := box.Intersect(parse.DimensionBox(dimension))
+	if !ok {
+		plan.Notef("box is outside the height limits of %v", dimension)
+		return plan, nil
+	}
+
+	missing := 0
+	for _, chunkPos := range box.Chunks() {
+		ce, err := loadChunkEdit(db, chunkPos, dimension)
+		if err != nil {
+			return nil, err
+		}
+		if ce == nil {
+			missing++
+			continue
+		}
+
+		baseX, baseZ := int(chunkPos.X())<<4, int(chunkPos.Z())<<4
+		for yIndex := box.Min[1] >> 4; yIndex <= box.Max[1]>>4; yIndex++ {
+			sce, err := ce.subChunk(int32(yIndex), create)
+			if err != nil {
+				return nil, err
+			}
+			if sce == nil || (skip != nil && skip(sce.sc)) {
+				continue
+			}
+			scBox := parse.Box{
+				Min: cube.Pos{baseX, yIndex << 4, baseZ},
+				Max: cube.Pos{baseX + 15, yIndex<<4 + 15, baseZ + 15},
+			}
+			clip, _ := scBox.Intersect(box)
+			for x := clip.Min[0]; x <= clip.Max[0]; x++ {
+				for z := clip.Min[2]; z <= clip.Max[2]; z++ {
+					for y := clip.Min[1]; y <= clip.Max[1]; y++ {
+						oldName := sce.sc.Block(x&15, y&15, z&15, 0)["name"]
+						if !edit(&sce.sc, x&15, y&15, z&15) {
+							continue
+						}
+						sce.changed = true
+						if sce.sc.Block(x&15, y&15, z&15, 0)["name"] != oldName {
+							ce.removeBlockEntities[cube.Pos{x, y, z}] = true
+						}
+					}
+				}
+			}
+		}
+
+		if err := ce.finish(plan); err != nil {
+			return nil, err
+		}
+	}
+	if missing > 0 {
+		plan.Notef("skipped %d chunks with no data", missing)
+	}
+	return plan, nil
+}
+
+// paletteMatches reports whether any layer of the sub-chunk has a
+// palette entry matching q.
+func paletteMatches(sc parse.SubChunk, q parse.BlockQuery) bool {
+	for layer := 0; layer < sc.LayerCount(); layer++ {
+		if slices.ContainsFunc(sc.Layer(layer).Palette(), q.Matches) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceBlocks returns a Plan that replaces every block matching from
+// (in any layer) inside box with the block to.
+func ReplaceBlocks(db *leveldb.DB, dimension world.Dimension, box parse.Box, from parse.BlockQuery, to map[string]any) (*Plan, error) {
+	description := fmt.Sprintf("Replace %s with %v in %v, box %s", from, to["name"], dimension, box)
+	skip := func(sc parse.SubChunk) bool {
+		return !paletteMatches(sc, from)
+	}
+	return editBlocks(db, dimension, box, description, false, skip, func(sc *parse.SubChunk, x, y, z int) bool {
+		changed := false
+		for layer := 0; layer < sc.LayerCount(); layer++ {
+			if b := sc.Block(x, y, z, layer); from.Matches(b) && !parse.SameBlock(b, to) {
+				sc.SetBlock(x, y, z, layer, to)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// FillBlocks returns a Plan that sets every block inside box to the
+// given block, and clears any second (waterlogging) layer. Missing
+// sub-chunks inside existing chunks are created, unless the block is
+// air.
+func FillBlocks(db *leveldb.DB, dimension world.Dimension, box parse.Box, block map[string]any) (*Plan, error) {
+	description := fmt.Sprintf("Fill %v, box %s with %v", dimension, box, block["name"])
+	return fillBlocks(db, dimension, box, block, description)
+}
+
+// ClearBlocks returns a Plan that sets every block inside box to air.
+func ClearBlocks(db *leveldb.DB, dimension world.Dimension, box parse.Box) (*Plan, error) {
+	description := fmt.Sprintf("Clear %v, box %s", dimension, box)
+	return fillBlocks(db, dimension, box, parse.AirBlock(), description)
+}
+
+func fillBlocks(db *leveldb.DB, dimension world.Dimension, box parse.Box, block map[string]any, description string) (*Plan, error) {
+	air := parse.AirBlock()
+	create := !parse.SameBlock(block, air)
+	return editBlocks(db, dimension, box, description, create, nil, func(sc *parse.SubChunk, x, y, z int) bool {
+		changed := false
+		if !parse.SameBlock(sc.Block(x, y, z, 0), block) {
+			sc.SetBlock(x, y, z, 0, block)
+			changed = true
+		}
+		for layer := 1; layer < sc.LayerCount(); layer++ {
+			if !parse.SameBlock(sc.Block(x, y, z, layer), air) {
+				sc.SetBlock(x, y, z, layer, air)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
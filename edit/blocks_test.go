@@ -0,0 +1,166 @@
+package edit
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// blockAt returns the block at a world position in keys, a dump of a
+// world that must hold the sub-chunk it's in.
+func blockAt(t *testing.T, keys map[string]string, dimension world.Dimension, pos cube.Pos, layer int) map[string]any {
+	t.Helper()
+	chunkPos := world.ChunkPos{int32(pos[0] >> 4), int32(pos[2] >> 4)}
+	key := subChunkKey(chunkPos, dimension, int32(pos[1]>>4))
+	val, ok := keys[key]
+	if !ok {
+		t.Fatalf("no sub-chunk holding %v", pos)
+	}
+	sc, err := parse.ParseSubChunk(parse.NewKeyVal([]byte(key), []byte(val)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sc.Block(pos[0]&15, pos[1]&15, pos[2]&15, layer)
+}
+
+func TestReplaceBlocks(t *testing.T) {
+	ow := world.Overworld
+	pos := world.ChunkPos{0, 0}
+	chest2, _ := parse.NewBlock("chest", "facing_direction=2")
+	chest3, _ := parse.NewBlock("chest", "facing_direction=3")
+	barrel, _ := parse.NewBlock("barrel")
+	sc := parse.NewSubChunk(0)
+	sc.SetBlock(1, 2, 3, 0, chest2)
+	sc.SetBlock(4, 5, 6, 0, chest3)
+	sc.SetBlock(7, 8, 9, 1, chest2)
+	val, err := sc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memDB(t, map[string]string{
+		chunkKey(pos, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(pos, ow, 0):                       string(val),
+	})
+
+	from, _ := parse.NewBlockQuery("chest", "facing_direction=2")
+	box := parse.Box{Min: cube.Pos{0, 0, 0}, Max: cube.Pos{15, 15, 15}}
+	plan, err := ReplaceBlocks(db, ow, box, from, barrel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	got := dumpDB(t, db)
+	for _, tt := range []struct {
+		pos   cube.Pos
+		layer int
+		want  map[string]any
+	}{
+		{cube.Pos{1, 2, 3}, 0, barrel},
+		{cube.Pos{4, 5, 6}, 0, chest3},
+		{cube.Pos{7, 8, 9}, 1, barrel},
+		{cube.Pos{7, 8, 9}, 0, parse.AirBlock()},
+	} {
+		if b := blockAt(t, got, ow, tt.pos, tt.layer); !parse.SameBlock(b, tt.want) {
+			t.Errorf("block at %v, layer %d: want %v; got %v", tt.pos, tt.layer, tt.want, b)
+		}
+	}
+
+	// Nothing left to replace.
+	plan, err = ReplaceBlocks(db, ow, box, from, barrel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Len() != 0 {
+		t.Errorf("want an empty plan the second time; got %d changes", plan.Len())
+	}
+}
+
+func TestFillBlocks(t *testing.T) {
+	ow := world.Overworld
+	// Chunk a exists but has no sub-chunk 1; chunk b doesn't exist.
+	a, b := world.ChunkPos{0, 0}, world.ChunkPos{1, 0}
+	db := memDB(t, map[string]string{
+		chunkKey(a, ow, parse.LevelChunkTagVersion): "\x28",
+		chunkKey(a, ow, parse.LevelChunkTagData3D):  data3D(ow),
+		subChunkKey(a, ow, 0):                       stoneSubChunk(t, 0, cube.Pos{0, 0, 0}),
+	})
+
+	stone, _ := parse.NewBlock("stone")
+	box := parse.Box{Min: cube.Pos{14, 10, 2}, Max: cube.Pos{17, 20, 2}}
+	plan, err := FillBlocks(db, ow, box, stone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note := "skipped 1 chunks with no data"; !slices.Contains(plan.Notes, note) {
+		t.Errorf("want note %q; got %q", note, plan.Notes)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	got := dumpDB(t, db)
+	if _, ok := got[subChunkKey(a, ow, 1)]; !ok {
+		t.Fatal("want sub-chunk 1 created in chunk a")
+	}
+	for k := range got {
+		if kv := parse.NewKeyVal([]byte(k), nil); kv.KeyTypeAndChunkLocation().ChunkPos == b {
+			t.Errorf("want no records for chunk b; got key %q", k)
+		}
+	}
+	for _, pos := range []cube.Pos{{14, 10, 2}, {15, 15, 2}, {14, 16, 2}, {15, 20, 2}} {
+		if block := blockAt(t, got, ow, pos, 0); !parse.SameBlock(block, stone) {
+			t.Errorf("block at %v: want stone; got %v", pos, block)
+		}
+	}
+	if block := blockAt(t, got, ow, cube.Pos{14, 21, 2}, 0); !parse.SameBlock(block, parse.AirBlock()) {
+		t.Errorf("block above the box: want air; got %v", block)
+	}
+	checkHeightMap(t, got[chunkKey(a, ow, parse.LevelChunkTagData3D)], ow, map[[2]int]int32{
+		{0, 0}: 0, {14, 2}: 20, {15, 2}: 20,
+	})
+}
+
+func TestClearBlocks(t *testing.T) {
+	ow := world.Overworld
+	pos := world.ChunkPos{0, 0}
+	chest, _ := parse.NewBlock("chest")
+	sc := parse.NewSubChunk(0)
+	sc.SetBlock(1, 2, 3, 0, chest)
+	sc.SetBlock(1, 9, 3, 0, chest)
+	val, err := sc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := memDB(t, map[string]string{
+		chunkKey(pos, ow, parse.LevelChunkTagVersion): "\x28",
+		chunkKey(pos, ow, parse.LevelChunkTagData3D):  data3D(ow),
+		// The block entity at 5,5,5 is over air, so clearing leaves it.
+		chunkKey(pos, ow, parse.LevelChunkTagBlockEntity): blockEntities(t, cube.Pos{1, 2, 3}, cube.Pos{1, 9, 3}, cube.Pos{5, 5, 5}),
+		subChunkKey(pos, ow, 0):                           string(val),
+	})
+
+	plan, err := ClearBlocks(db, ow, parse.Box{Min: cube.Pos{0, 0, 0}, Max: cube.Pos{15, 5, 15}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	got := dumpDB(t, db)
+	if block := blockAt(t, got, ow, cube.Pos{1, 2, 3}, 0); !parse.SameBlock(block, parse.AirBlock()) {
+		t.Errorf("want the chest in the box cleared; got %v", block)
+	}
+	if block := blockAt(t, got, ow, cube.Pos{1, 9, 3}, 0); !parse.SameBlock(block, chest) {
+		t.Errorf("want the chest above the box kept; got %v", block)
+	}
+	positions := blockEntityPositions(t, got[chunkKey(pos, ow, parse.LevelChunkTagBlockEntity)])
+	if want := []cube.Pos{{1, 9, 3}, {5, 5, 5}}; !reflect.DeepEqual(positions, want) {
+		t.Errorf("want block entities at %v; got %v", want, positions)
+	}
+	checkHeightMap(t, got[chunkKey(pos, ow, parse.LevelChunkTagData3D)], ow, map[[2]int]int32{{1, 3}: 9})
+}
@@ -0,0 +1,88 @@
+package edit
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// FixCheckSums updates the CheckSums records of the chunks the plan
+// changes to match the chunks' records after the plan, since the game
+// may discard chunks with stale checksums. CheckSums records that can't
+// be decoded are deleted instead. Run calls it on every plan.
+func (p *Plan) FixCheckSums(db *leveldb.DB) error {
+	type chunkID struct {
+		pos       world.ChunkPos
+		dimension world.Dimension
+	}
+	var chunks []chunkID
+	changes := make(map[chunkID][]*change)
+	for _, sKey := range p.order {
+		c := p.changes[sKey]
+		info := parse.NewKeyVal(c.key, nil).KeyTypeAndChunkLocation()
+		if !info.HasLocation {
+			continue
+		}
+		id := chunkID{info.ChunkPos, info.Dimension}
+		if changes[id] == nil {
+			chunks = append(chunks, id)
+		}
+		changes[id] = append(changes[id], c)
+	}
+
+	var fixed, deleted int
+	for _, id := range chunks {
+		checksumKey := parse.MakeChunkKey(id.pos, id.dimension, parse.LevelChunkTagCheckSums)
+		current, err := parse.ChunkRecords(db, id.pos, id.dimension)
+		if err != nil {
+			return err
+		}
+		var oldVal []byte
+		records := make(map[string]*parse.KeyVal, len(current))
+		for _, kv := range current {
+			records[string(kv.Key)] = kv
+			if bytes.Equal(kv.Key, checksumKey) {
+				oldVal = kv.Val
+			}
+		}
+		for _, c := range changes[id] {
+			if c.delete {
+				delete(records, string(c.key))
+			} else {
+				records[string(c.key)] = &parse.KeyVal{Key: c.key, Val: c.val}
+			}
+		}
+		checksumsKV, ok := records[string(checksumKey)]
+		if !ok {
+			continue
+		}
+		delete(records, string(checksumKey))
+
+		checksums, err := parse.ParseCheckSums(checksumsKV.Val)
+		if err != nil {
+			p.Delete(checksumKey, oldVal)
+			deleted++
+			continue
+		}
+		after := make([]*parse.KeyVal, 0, len(records))
+		for _, kv := range records {
+			after = append(after, kv)
+		}
+		slices.SortFunc(after, func(a, b *parse.KeyVal) int { return bytes.Compare(a.Key, b.Key) })
+		val := parse.EncodeCheckSums(parse.ComputeCheckSums(after, checksums))
+		if !bytes.Equal(val, checksumsKV.Val) {
+			p.Put(checksumKey, oldVal, val)
+			fixed++
+		}
+	}
+	if fixed > 0 {
+		p.Notef("updated the checksums of %d chunks", fixed)
+	}
+	if deleted > 0 {
+		p.Notef("deleted the checksums of %d chunks, which couldn't be decoded", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package edit
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// ChunkKeyRange returns the smallest key range holding every chunk key
+// of the given dimension, and false if it has none. Chunk keys start
+// with the chunk's x coordinate, so the keys of the other dimensions
+// are interleaved with these, and the range covers some of them too.
+func ChunkKeyRange(db *leveldb.DB, dimension world.Dimension) (util.Range, bool, error) {
+	var first, last []byte
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &parse.KeyVal{Key: iter.Key()}
+		keyInfo := kv.KeyTypeAndChunkLocation()
+		if !keyInfo.HasLocation || keyInfo.Dimension != dimension {
+			continue
+		}
+		if first == nil {
+			first = slices.Clone(iter.Key())
+		}
+		last = append(last[:0], iter.Key()...)
+	}
+	if err := iter.Error(); err != nil {
+		return util.Range{}, false, err
+	}
+	if first == nil {
+		return util.Range{}, false, nil
+	}
+	// Limit is exclusive, and last+0x00 is the next possible key.
+	return util.Range{Start: first, Limit: append(last, 0)}, true, nil
+}
+
+// CompactDB compacts the chunk key range of the given dimension, or
+// the whole database if dimension is nil, so deleted keys and
+// overwritten values are dropped from the tables on disk.
+func CompactDB(db *leveldb.DB, dimension world.Dimension) error {
+	r := util.Range{}
+	if dimension != nil {
+		var ok bool
+		var err error
+		r, ok, err = ChunkKeyRange(db, dimension)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	if err := db.CompactRange(r); err != nil {
+		return fmt.Errorf("error compacting leveldb: %w", err)
+	}
+	return nil
+}
+
+// CompactWorld compacts the db of the world in worldDir (see
+// CompactDB), and reports the size of the db directory before and
+// after. It then reopens the db read-only and checks that the same
+// chunks are still there.
+func CompactWorld(worldDir string, dimension world.Dimension, out io.Writer) error {
+	dbDir := filepath.Join(worldDir, "db")
+	before, err := save.DirSize(dbDir)
+	if err != nil {
+		return err
+	}
+
+	db, err := leveldb.OpenFile(dbDir, save.DBOptions(false))
+	if err != nil {
+		return fmt.Errorf("error opening leveldb: %w", err)
+	}
+	chunksBefore := parse.GetOccupiedChunkCoordinates(db)
+	if dimension == nil {
+		fmt.Fprintf(out, "Compacting all of %s\n", dbDir)
+	} else {
+		fmt.Fprintf(out, "Compacting %v chunks in %s\n", dimension, dbDir)
+	}
+	err = CompactDB(db, dimension)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	after, err := save.DirSize(dbDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "  db size: %d -> %d bytes (%d saved)\n", before, after, before-after)
+
+	db, err = leveldb.OpenFile(dbDir, save.DBOptions(true))
+	if err != nil {
+		return fmt.Errorf("error reopening leveldb after compaction: %w", err)
+	}
+	defer db.Close()
+	chunksAfter := parse.GetOccupiedChunkCoordinates(db)
+	for _, dim := range []world.Dimension{world.Overworld, world.Nether, world.End} {
+		if len(chunksAfter[dim]) != len(chunksBefore[dim]) {
+			return fmt.Errorf("%v had %d chunks before compaction, but %d after", dim, len(chunksBefore[dim]), len(chunksAfter[dim]))
+		}
+		fmt.Fprintf(out, "  %v: %d chunks readable\n", dim, len(chunksAfter[dim]))
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package edit
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/storage"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// memDB returns an in-memory leveldb holding the given keys.
+func memDB(t *testing.T, keys map[string]string) *leveldb.DB {
+	t.Helper()
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for k, v := range keys {
+		if err := db.Put([]byte(k), []byte(v), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// dumpDB returns every key and value in db.
+func dumpDB(t *testing.T, db *leveldb.DB) map[string]string {
+	t.Helper()
+	res := make(map[string]string)
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		res[string(iter.Key())] = string(iter.Value())
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// checkKeys reports the keys that differ between got and want. Actors
+// and sub-chunks are compared decoded, since NBT doesn't fix the order
+// of a compound's fields.
+func checkKeys(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	for k, v := range want {
+		g, ok := got[k]
+		switch {
+		case !ok:
+			t.Errorf("key %q: want %q; got no key", k, v)
+		case g == v:
+		case parse.NewKeyVal([]byte(k), nil).KeyType() == parse.KeyTypeActorprefix:
+			var gotNBT, wantNBT map[string]any
+			nbt.UnmarshalEncoding([]byte(g), &gotNBT, nbt.LittleEndian)
+			nbt.UnmarshalEncoding([]byte(v), &wantNBT, nbt.LittleEndian)
+			if !reflect.DeepEqual(gotNBT, wantNBT) {
+				t.Errorf("actor %q: want %v; got %v", k, wantNBT, gotNBT)
+			}
+		case sameSubChunk(k, g, v):
+		default:
+			t.Errorf("key %q: want %q; got %q", k, v, g)
+		}
+	}
+	for k, v := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("key %q: want no key; got %q", k, v)
+		}
+	}
+}
+
+// sameSubChunk reports whether a and b are both sub-chunks with the
+// given key, holding the same blocks.
+func sameSubChunk(key, a, b string) bool {
+	if parse.NewKeyVal([]byte(key), nil).KeyType().LevelChunkTag() != parse.LevelChunkTagSubChunkPrefix {
+		return false
+	}
+	scA, errA := parse.ParseSubChunk(parse.NewKeyVal([]byte(key), []byte(a)))
+	scB, errB := parse.ParseSubChunk(parse.NewKeyVal([]byte(key), []byte(b)))
+	if errA != nil || errB != nil || scA.LayerCount() != scB.LayerCount() {
+		return false
+	}
+	for layer := range scA.LayerCount() {
+		for x := range 16 {
+			for y := range 16 {
+				for z := range 16 {
+					if !parse.SameBlock(scA.Block(x, y, z, layer), scB.Block(x, y, z, layer)) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// actorIDs returns a digest value listing the given actor IDs.
+func actorIDs(ids ...int64) string {
+	var res []byte
+	for _, id := range ids {
+		res = binary.LittleEndian.AppendUint64(res, uint64(id))
+	}
+	return string(res)
+}
+
+func actorKey(id int64) string {
+	return string(parse.MakeActorKey([]byte(actorIDs(id))))
+}
+
+func chunkKey(pos world.ChunkPos, dimension world.Dimension, tag parse.LevelChunkTag) string {
+	return string(parse.MakeChunkKey(pos, dimension, tag))
+}
+
+func subChunkKey(pos world.ChunkPos, dimension world.Dimension, yIndex int32) string {
+	return string(parse.MakeSubChunkKey(pos, dimension, yIndex))
+}
+
+func digpKey(pos world.ChunkPos, dimension world.Dimension) string {
+	return string(parse.MakeDigpKey(pos, dimension))
+}
+
+// stoneSubChunk returns an encoded sub-chunk with stone at the given
+// positions within it, and air everywhere else.
+func stoneSubChunk(t *testing.T, yIndex int32, positions ...cube.Pos) string {
+	t.Helper()
+	stone, _ := parse.NewBlock("stone")
+	sc := parse.NewSubChunk(yIndex)
+	for _, pos := range positions {
+		sc.SetBlock(pos[0], pos[1], pos[2], 0, stone)
+	}
+	val, err := sc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(val)
+}
+
+// data3D returns a Data3D value for dimension with an empty heightmap,
+// and every biome section all plains.
+func data3D(dimension world.Dimension) string {
+	res := make([]byte, 512)
+	r := dimension.Range()
+	for range (r.Max() - r.Min() + 1) >> 4 {
+		res = append(res, 0<<1|1)
+		res = binary.LittleEndian.AppendUint32(res, 1)
+	}
+	return string(res)
+}
+
+// checkHeightMap checks the heightmap stored in a Data3D value against
+// want, which gives the heights of the columns that have blocks.
+func checkHeightMap(t *testing.T, val string, dimension world.Dimension, want map[[2]int]int32) {
+	t.Helper()
+	hm, err := parse.StoredHeightMap([]byte(val), dimension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for z := range 16 {
+		for x := range 16 {
+			w, ok := want[[2]int{x, z}]
+			if !ok {
+				w = parse.NoHeight
+			}
+			if got := hm.Get(x, z); got != w {
+				t.Errorf("heightmap at %d,%d: want %d; got %d", x, z, w, got)
+			}
+		}
+	}
+}
+
+// blockEntities returns a BlockEntity value holding a chest at each
+// of the given positions.
+func blockEntities(t *testing.T, positions ...cube.Pos) string {
+	t.Helper()
+	var records []parse.NBTRecord
+	for _, pos := range positions {
+		records = append(records, parse.NBTRecord{Data: map[string]any{
+			"id": "Chest", "x": int32(pos[0]), "y": int32(pos[1]), "z": int32(pos[2]),
+		}})
+	}
+	val, err := parse.EncodeNBTRecords(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(val)
+}
+
+// blockEntityPositions returns the positions of the block entities in
+// a BlockEntity value.
+func blockEntityPositions(t *testing.T, val string) []cube.Pos {
+	t.Helper()
+	records, err := parse.DecodeNBTRecords([]byte(val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []cube.Pos
+	for _, rec := range records {
+		pos, _ := parse.NBTBlockPos(rec.Data)
+		res = append(res, pos)
+	}
+	return res
+}
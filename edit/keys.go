@@ -0,0 +1,30 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// SetNBTFromJSON returns a Plan that replaces the value of an existing
+// NBT key with edited JSON, as written by the keys command (see
+// parse.EncodeEditedNBT).
+func SetNBTFromJSON(db *leveldb.DB, key []byte, edited []byte) (*Plan, error) {
+	val, err := db.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("no key %q", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	kv := parse.NewKeyVal(key, val)
+	newVal, err := parse.EncodeEditedNBT(kv.KeyType(), val, edited)
+	if err != nil {
+		return nil, err
+	}
+	plan := NewPlan(fmt.Sprintf("Set %s %q from JSON", kv.KeyType(), key))
+	plan.Put(kv.Key, val, newVal)
+	return plan, nil
+}
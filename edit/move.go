@@ -0,0 +1,313 @@
+package edit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// actorIDFields are the fields of entity NBT that hold the UniqueID of
+// an actor: the entity's own, and those of actors it links to.
+var actorIDFields = []string{"UniqueID", "entityID", "LeasherID", "TargetID", "OwnerNew"}
+
+// movedChunk holds everything MoveChunks reads from a source chunk
+// before it starts changing anything.
+type movedChunk struct {
+	pos      world.ChunkPos
+	records  []*parse.KeyVal
+	actorIDs [][]byte
+	actors   []map[string]any // Parallel to actorIDs
+}
+
+// readMovedChunk reads a chunk's records and the actors in its digest.
+// Actors listed in the digest that have no record are left out.
+func readMovedChunk(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension) (movedChunk, error) {
+	mc := movedChunk{pos: chunkPos}
+	kvs, err := parse.AllEntriesWithChunkCoordinatePrefix(db, chunkPos, dimension)
+	if err != nil {
+		return mc, err
+	}
+	for _, kv := range kvs {
+		if kv.KeyType().IsChunkDataForDimension(dimension) {
+			mc.records = append(mc.records, kv)
+		}
+	}
+
+	digp, err := db.Get(parse.MakeDigpKey(chunkPos, dimension), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return mc, nil
+	} else if err != nil {
+		return mc, err
+	}
+	ids, err := parse.ParseDigp(digp)
+	if err != nil {
+		return mc, fmt.Errorf("chunk %v in %v: %w", chunkPos, dimension, err)
+	}
+	for _, id := range ids {
+		val, err := db.Get(parse.MakeActorKey(id), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return mc, err
+		}
+		var data map[string]any
+		if err := nbt.UnmarshalEncoding(val, &data, nbt.LittleEndian); err != nil {
+			return mc, fmt.Errorf("unable to decode actor %x in chunk %v in %v: %w", id, chunkPos, dimension, err)
+		}
+		mc.actorIDs = append(mc.actorIDs, id)
+		mc.actors = append(mc.actors, data)
+	}
+	return mc, nil
+}
+
+// deleteChunk adds the deletion of all of a chunk's records, its actor
+// digest, and the actors in it, to the plan.
+func deleteChunk(db *leveldb.DB, plan *Plan, chunkPos world.ChunkPos, dimension world.Dimension) error {
+	kvs, err := parse.AllEntriesWithChunkCoordinatePrefix(db, chunkPos, dimension)
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if kv.KeyType().IsChunkDataForDimension(dimension) {
+			plan.Delete(kv.Key, kv.Val)
+		}
+	}
+
+	digpKey := parse.MakeDigpKey(chunkPos, dimension)
+	digp, err := db.Get(digpKey, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	plan.Delete(digpKey, digp)
+	ids, err := parse.ParseDigp(digp)
+	if err != nil {
+		return fmt.Errorf("chunk %v in %v: %w", chunkPos, dimension, err)
+	}
+	for _, id := range ids {
+		key := parse.MakeActorKey(id)
+		val, err := db.Get(key, nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		plan.Delete(key, val)
+	}
+	return nil
+}
+
+// remapActorIDs replaces the actor IDs in decoded entity NBT that are
+// keys of ids with their values, in place, including in nested lists
+// and compounds.
+func remapActorIDs(data any, ids map[int64]int64) {
+	switch v := data.(type) {
+	case map[string]any:
+		for _, field := range actorIDFields {
+			if id, ok := v[field].(int64); ok {
+				if newID, ok := ids[id]; ok {
+					v[field] = newID
+				}
+			}
+		}
+		for _, child := range v {
+			remapActorIDs(child, ids)
+		}
+	case []any:
+		for _, child := range v {
+			remapActorIDs(child, ids)
+		}
+	}
+}
+
+// MoveChunks returns a Plan that moves the chunks of one dimension in
+// area by dx and dz chunks, into the same dimension or another one, or
+// copies them there if keepSource is true. Every record of each chunk
+// is rewritten under its new key, with the positions in its block
+// entities, entities, tick lists and hardcoded spawners moved to
+// match, and whatever was at the destination is replaced. Actors keep
+// their IDs when moved, and get new ones when copied. Moving to a
+// dimension with different height limits drops the sub-chunks outside
+// them, and rebuilds the biomes and heightmap for the new range.
+//
+// CheckSums records are copied along with the other records, and
+// Plan.FixCheckSums updates them. Villages, ticking areas and maps that
+// refer to the chunks are left alone.
+func MoveChunks(db *leveldb.DB, from world.Dimension, area parse.ChunkArea, to world.Dimension, dx, dz int32, keepSource bool) (*Plan, error) {
+	if from == to && dx == 0 && dz == 0 {
+		return nil, fmt.Errorf("chunks %s of %v would be moved onto themselves", area, from)
+	}
+	verb := "Move"
+	if keepSource {
+		verb = "Copy"
+	}
+	plan := NewPlan(fmt.Sprintf("%s chunks %s of %v by %d,%d chunks to %v", verb, area, from, dx, dz, to))
+
+	var chunks []movedChunk
+	for _, chunkPos := range sortedChunks(parse.GetOccupiedChunkCoordinates(db)[from]) {
+		if !area.Contains(chunkPos) {
+			continue
+		}
+		mc, err := readMovedChunk(db, chunkPos, from)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, mc)
+	}
+	if len(chunks) == 0 {
+		plan.Notef("no chunks with data in %s of %v", area, from)
+		return plan, nil
+	}
+
+	// The source and destination can overlap, so clear both before
+	// writing anything: with a Plan, the last change to a key wins.
+	for _, mc := range chunks {
+		if err := deleteChunk(db, plan, world.ChunkPos{mc.pos.X() + dx, mc.pos.Z() + dz}, to); err != nil {
+			return nil, err
+		}
+		if !keepSource {
+			if err := deleteChunk(db, plan, mc.pos, from); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var newIDs map[int64]int64
+	if keepSource {
+		var oldIDs []int64
+		for _, mc := range chunks {
+			for _, id := range mc.actorIDs {
+				oldIDs = append(oldIDs, int64(binary.LittleEndian.Uint64(id)))
+			}
+		}
+		ids, err := NewActorIDs(db, len(oldIDs))
+		if err != nil {
+			return nil, err
+		}
+		newIDs = make(map[int64]int64, len(oldIDs))
+		for i, id := range oldIDs {
+			newIDs[id] = ids[i]
+		}
+	}
+
+	var dropped int
+	for _, mc := range chunks {
+		d, err := writeMovedChunk(plan, mc, from, to, dx, dz, newIDs)
+		if err != nil {
+			return nil, err
+		}
+		dropped += d
+	}
+	if dropped > 0 {
+		plan.Notef("dropped %d sub-chunks outside the height limits of %v", dropped, to)
+	}
+	return plan, nil
+}
+
+// writeMovedChunk adds the records and actors of a chunk read by
+// readMovedChunk to the plan, at their new position. Actor IDs are
+// replaced using newIDs if it isn't nil. It returns the number of
+// sub-chunks it dropped. CheckSums records are copied as they are, for
+// FixCheckSums to update.
+func writeMovedChunk(plan *Plan, mc movedChunk, from, to world.Dimension, dx, dz int32, newIDs map[int64]int64) (int, error) {
+	target := world.ChunkPos{mc.pos.X() + dx, mc.pos.Z() + dz}
+	prefix := parse.MakeChunkPrefix(target, to)
+	oldPrefixLen := len(parse.MakeChunkPrefix(mc.pos, from))
+	bx, bz := int(dx)<<4, int(dz)<<4
+	r := to.Range()
+	minIndex, maxIndex := int32(r.Min()>>4), int32(r.Max()>>4)
+
+	var dropped int
+	var subChunks []parse.SubChunk
+	var heightMapKV *parse.KeyVal
+	for _, kv := range mc.records {
+		key := append(slices.Clone(prefix), kv.Key[oldPrefixLen:]...)
+		val := kv.Val
+		switch tag := kv.KeyType().LevelChunkTag(); tag {
+		case parse.LevelChunkTagSubChunkPrefix:
+			if from != to {
+				yIndex := int32(int8(kv.Key[len(kv.Key)-1]))
+				if yIndex < minIndex || yIndex > maxIndex {
+					dropped++
+					continue
+				}
+				sc, err := parse.ParseSubChunk(kv)
+				if err != nil {
+					return 0, fmt.Errorf("chunk %v in %v: %w", mc.pos, from, err)
+				}
+				subChunks = append(subChunks, sc)
+			}
+		case parse.LevelChunkTagData3D, parse.LevelChunkTagData2D:
+			if from != to {
+				if tag == parse.LevelChunkTagData3D {
+					var err error
+					if val, err = parse.RebaseData3D(val, from, to); err != nil {
+						return 0, fmt.Errorf("biomes of chunk %v in %v: %w", mc.pos, from, err)
+					}
+				}
+				// The heightmap is filled in once all the sub-chunks
+				// have been seen.
+				heightMapKV = parse.NewKeyVal(key, val)
+				continue
+			}
+		case parse.LevelChunkTagBlockEntity, parse.LevelChunkTagEntity, parse.LevelChunkTagPendingTicks, parse.LevelChunkTagRandomTicks:
+			records, err := parse.DecodeNBTRecords(val)
+			if err != nil {
+				return 0, fmt.Errorf("%v of chunk %v in %v: %w", tag, mc.pos, from, err)
+			}
+			for i := range records {
+				if parse.ShiftNBTPositions(records[i].Data, bx, bz) > 0 {
+					records[i].Raw = nil
+				}
+				if newIDs != nil && tag == parse.LevelChunkTagEntity {
+					remapActorIDs(records[i].Data, newIDs)
+					records[i].Raw = nil
+				}
+			}
+			if val, err = parse.EncodeNBTRecords(records); err != nil {
+				return 0, err
+			}
+		case parse.LevelChunkTagHardcodedSpawners:
+			var err error
+			if val, err = parse.ShiftHardcodedSpawners(val, bx, bz); err != nil {
+				return 0, fmt.Errorf("chunk %v in %v: %w", mc.pos, from, err)
+			}
+		}
+		plan.Put(key, nil, val)
+	}
+
+	if heightMapKV != nil {
+		val, err := parse.SetStoredHeightMap(heightMapKV.Val, parse.ComputeHeightMap(subChunks, 0), to)
+		if err != nil {
+			return 0, fmt.Errorf("heightmap of chunk %v in %v: %w", target, to, err)
+		}
+		plan.Put(heightMapKV.Key, nil, val)
+	}
+
+	var digp []byte
+	for i, id := range mc.actorIDs {
+		data := mc.actors[i]
+		parse.ShiftNBTPositions(data, bx, bz)
+		if newIDs != nil {
+			remapActorIDs(data, newIDs)
+			id = binary.LittleEndian.AppendUint64(nil, uint64(newIDs[int64(binary.LittleEndian.Uint64(id))]))
+		}
+		val, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+		if err != nil {
+			return 0, fmt.Errorf("unable to encode actor %x of chunk %v in %v: %w", id, mc.pos, from, err)
+		}
+		plan.Put(parse.MakeActorKey(id), nil, val)
+		digp = append(digp, id...)
+	}
+	if len(digp) > 0 {
+		plan.Put(parse.MakeDigpKey(target, to), nil, digp)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,183 @@
+package edit
+
+import (
+	"encoding/binary"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// actor returns the encoded NBT of an actor at the given block x and z.
+func actor(t *testing.T, id int64, x, z float32, links map[string]int64) string {
+	t.Helper()
+	data := map[string]any{
+		"identifier": "minecraft:cow",
+		"UniqueID":   id,
+		"Pos":        []any{x, float32(64), z},
+	}
+	for field, id := range links {
+		data[field] = id
+	}
+	val, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(val)
+}
+
+func TestMoveChunksOverlapping(t *testing.T) {
+	ow := world.Overworld
+	c0, c1, c2 := world.ChunkPos{0, 0}, world.ChunkPos{1, 0}, world.ChunkPos{2, 0}
+	db := memDB(t, map[string]string{
+		chunkKey(c0, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(c0, ow, 0):                       "c0",
+		digpKey(c0, ow):                              actorIDs(5),
+		actorKey(5):                                  actor(t, 5, 1.5, 2.5, nil),
+		chunkKey(c1, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(c1, ow, 0):                       "c1",
+		subChunkKey(c1, ow, 1):                       "c1, y=1",
+		digpKey(c1, ow):                              actorIDs(7),
+		actorKey(7):                                  actor(t, 7, 17.5, 2.5, nil),
+		chunkKey(c2, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(c2, ow, 0):                       "c2",
+		digpKey(c2, ow):                              actorIDs(6),
+		actorKey(6):                                  actor(t, 6, 33.5, 2.5, nil),
+	})
+
+	// Chunk 1 is both a source and a destination: it's deleted as
+	// chunk 0's destination and as a source, and then written with
+	// chunk 0's records. Chunk 2 is replaced by chunk 1.
+	plan, err := MoveChunks(db, ow, parse.ChunkArea{Min: c0, Max: c1}, ow, 1, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		chunkKey(c1, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(c1, ow, 0):                       "c0",
+		digpKey(c1, ow):                              actorIDs(5),
+		actorKey(5):                                  actor(t, 5, 17.5, 2.5, nil),
+		chunkKey(c2, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(c2, ow, 0):                       "c1",
+		subChunkKey(c2, ow, 1):                       "c1, y=1",
+		digpKey(c2, ow):                              actorIDs(7),
+		actorKey(7):                                  actor(t, 7, 33.5, 2.5, nil),
+	}
+	checkKeys(t, dumpDB(t, db), want)
+}
+
+func TestMoveChunksCopyRenumbersActors(t *testing.T) {
+	ow := world.Overworld
+	src, dst := world.ChunkPos{0, 0}, world.ChunkPos{0, 3}
+	keys := map[string]string{
+		chunkKey(src, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(src, ow, 0):                       "src",
+		digpKey(src, ow):                              actorIDs(1, 2),
+		actorKey(1):                                   actor(t, 1, 1.5, 2.5, nil),
+		// Actor 2 is leashed to actor 1, and targets actor 9, which
+		// isn't being copied.
+		actorKey(2): actor(t, 2, 3.5, 4.5, map[string]int64{"LeasherID": 1, "TargetID": 9}),
+	}
+	db := memDB(t, keys)
+
+	plan, err := MoveChunks(db, ow, parse.ChunkArea{Min: src, Max: src}, ow, 0, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	id1, id2 := firstNewActorID, firstNewActorID+1
+	want := map[string]string{
+		chunkKey(dst, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(dst, ow, 0):                       "src",
+		digpKey(dst, ow):                              actorIDs(id1, id2),
+		actorKey(id1):                                 actor(t, id1, 1.5, 50.5, nil),
+		actorKey(id2):                                 actor(t, id2, 3.5, 52.5, map[string]int64{"LeasherID": id1, "TargetID": 9}),
+	}
+	for k, v := range keys {
+		want[k] = v
+	}
+	checkKeys(t, dumpDB(t, db), want)
+}
+
+func TestMoveChunksAcrossDimensions(t *testing.T) {
+	ow, nether := world.Overworld, world.Nether
+	pos := world.ChunkPos{0, 0}
+	stone, _ := parse.NewBlock("stone")
+	subChunk := func(yIndex int32, y int) string {
+		sc := parse.NewSubChunk(yIndex)
+		sc.SetBlock(1, y, 2, 0, stone)
+		val, err := sc.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(val)
+	}
+	// An overworld Data3D: a heightmap, then sections -4 to 19, each
+	// all one biome: its index.
+	data3D := make([]byte, 512)
+	for i := range 24 {
+		data3D = append(data3D, 0<<1|1)
+		data3D = binary.LittleEndian.AppendUint32(data3D, uint32(i))
+	}
+	kept := subChunk(3, 5)
+	db := memDB(t, map[string]string{
+		chunkKey(pos, ow, parse.LevelChunkTagVersion): "\x28",
+		chunkKey(pos, ow, parse.LevelChunkTagData3D):  string(data3D),
+		subChunkKey(pos, ow, -4):                      subChunk(-4, 0),
+		subChunkKey(pos, ow, 3):                       kept,
+		subChunkKey(pos, ow, 9):                       subChunk(9, 0),
+	})
+
+	plan, err := MoveChunks(db, ow, parse.ChunkArea{Min: pos, Max: pos}, nether, 0, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note := "dropped 2 sub-chunks outside the height limits of Nether"; !slices.Contains(plan.Notes, note) {
+		t.Errorf("want note %q; got %q", note, plan.Notes)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	got := dumpDB(t, db)
+	val, ok := got[chunkKey(pos, nether, parse.LevelChunkTagData3D)]
+	if !ok {
+		t.Fatal("no Data3D in the nether")
+	}
+	delete(got, chunkKey(pos, nether, parse.LevelChunkTagData3D))
+	checkKeys(t, got, map[string]string{
+		chunkKey(pos, nether, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(pos, nether, 3):                       kept,
+	})
+
+	// The nether's sections 0 to 7 are the overworld's 4th to 11th.
+	palettes, _, err := parse.ParseBiomes([]byte(val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]int32{{4}, {5}, {6}, {7}, {8}, {9}, {10}, {11}}; !reflect.DeepEqual(palettes, want) {
+		t.Errorf("want biome palettes %v; got %v", want, palettes)
+	}
+	hm, err := parse.StoredHeightMap([]byte(val), nether)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for z := range 16 {
+		for x := range 16 {
+			want := int32(parse.NoHeight)
+			if x == 1 && z == 2 {
+				want = 3<<4 + 5
+			}
+			if got := hm.Get(x, z); got != want {
+				t.Errorf("heightmap at %d,%d: want %d; got %d", x, z, want, got)
+			}
+		}
+	}
+}
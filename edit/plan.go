@@ -0,0 +1,202 @@
+// Package edit holds operations that change a world's leveldb. Each
+// operation builds a Plan, which can be printed as a dry run, or
+// applied after taking a snapshot of the world.
+package edit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+	"golang.org/x/exp/maps"
+)
+
+// Plan is a set of changes to the keys of a world's leveldb.
+type Plan struct {
+	Description string
+	Notes       []string
+	changes     map[string]*change
+	order       []string
+}
+
+type change struct {
+	key     []byte
+	oldSize int // -1 if the key is new
+	val     []byte
+	delete  bool
+}
+
+// NewPlan returns a new, empty Plan.
+func NewPlan(description string) *Plan {
+	return &Plan{
+		Description: description,
+		changes:     make(map[string]*change),
+	}
+}
+
+// Put records that key should be set to val. oldVal is the current
+// value, or nil if the key is new, and is only used for reporting.
+func (p *Plan) Put(key, oldVal, val []byte) {
+	p.add(key, oldVal, val, false)
+}
+
+// Delete records that key, currently holding oldVal, should be
+// deleted.
+func (p *Plan) Delete(key, oldVal []byte) {
+	p.add(key, oldVal, nil, true)
+}
+
+func (p *Plan) add(key, oldVal, val []byte, delete bool) {
+	sKey := string(key)
+	if c, ok := p.changes[sKey]; ok {
+		// Keep the size from before any of this plan's changes.
+		c.val = slices.Clone(val)
+		c.delete = delete
+		return
+	}
+	oldSize := -1
+	if oldVal != nil {
+		oldSize = len(oldVal)
+	}
+	p.changes[sKey] = &change{
+		key:     slices.Clone(key),
+		oldSize: oldSize,
+		val:     slices.Clone(val),
+		delete:  delete,
+	}
+	p.order = append(p.order, sKey)
+}
+
+// Notef adds a note to the plan, for things worth reporting that
+// aren't changes, like chunks that were skipped.
+func (p *Plan) Notef(format string, args ...any) {
+	p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+}
+
+// Len returns the number of keys the plan changes.
+func (p *Plan) Len() int {
+	return len(p.order)
+}
+
+// Merge adds all the changes and notes from other to p.
+func (p *Plan) Merge(other *Plan) {
+	for _, sKey := range other.order {
+		c := other.changes[sKey]
+		if _, ok := p.changes[sKey]; !ok {
+			p.changes[sKey] = &change{key: c.key, oldSize: c.oldSize}
+			p.order = append(p.order, sKey)
+		}
+		p.changes[sKey].val = c.val
+		p.changes[sKey].delete = c.delete
+	}
+	p.Notes = append(p.Notes, other.Notes...)
+}
+
+// Stats summarizes the changes a plan makes to keys of one KeyType.
+type Stats struct {
+	Added     int `json:"added"`
+	Changed   int `json:"changed"`
+	Deleted   int `json:"deleted"`
+	OldBytes  int `json:"old_bytes"`  // Total size of the affected values before the plan
+	NewBytes  int `json:"new_bytes"`  // Total size of the affected values after the plan
+	SavedSize int `json:"saved_size"` // OldBytes - NewBytes
+}
+
+// Stats returns the plan's changes summarized by KeyType.
+func (p *Plan) Stats() map[parse.KeyType]*Stats {
+	res := make(map[parse.KeyType]*Stats)
+	for _, sKey := range p.order {
+		c := p.changes[sKey]
+		kt := parse.NewKeyVal(c.key, nil).KeyType()
+		st := res[kt]
+		if st == nil {
+			st = &Stats{}
+			res[kt] = st
+		}
+		switch {
+		case c.delete:
+			st.Deleted++
+		case c.oldSize < 0:
+			st.Added++
+		default:
+			st.Changed++
+		}
+		st.OldBytes += max(c.oldSize, 0)
+		if !c.delete {
+			st.NewBytes += len(c.val)
+		}
+		st.SavedSize = st.OldBytes - st.NewBytes
+	}
+	return res
+}
+
+// Print writes a human-readable summary of the plan to w.
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "%s\n", p.Description)
+	for _, note := range p.Notes {
+		fmt.Fprintf(w, "  note: %s\n", note)
+	}
+	if p.Len() == 0 {
+		fmt.Fprintf(w, "  no changes\n")
+		return
+	}
+
+	stats := p.Stats()
+	keyTypes := maps.Keys(stats)
+	slices.Sort(keyTypes)
+	longest := 0
+	for _, kt := range keyTypes {
+		longest = max(longest, len(kt.String()))
+	}
+	var total Stats
+	for _, kt := range keyTypes {
+		st := stats[kt]
+		fmt.Fprintf(w, "  %s:%s%d added, %d changed, %d deleted (%d -> %d bytes)\n",
+			kt, strings.Repeat(" ", longest+2-len(kt.String())), st.Added, st.Changed, st.Deleted, st.OldBytes, st.NewBytes)
+		total.OldBytes += st.OldBytes
+		total.NewBytes += st.NewBytes
+	}
+	fmt.Fprintf(w, "  total: %d keys, %d -> %d bytes\n", p.Len(), total.OldBytes, total.NewBytes)
+}
+
+// Summary is a Plan summarized for JSON output.
+type Summary struct {
+	Description string            `json:"description"`
+	Notes       []string          `json:"notes,omitempty"`
+	Keys        int               `json:"keys"`
+	Stats       map[string]*Stats `json:"stats"` // By KeyType name
+}
+
+// Summary returns a summary of the plan for JSON output.
+func (p *Plan) Summary() Summary {
+	res := Summary{
+		Description: p.Description,
+		Notes:       p.Notes,
+		Keys:        p.Len(),
+		Stats:       make(map[string]*Stats),
+	}
+	for kt, st := range p.Stats() {
+		res.Stats[kt.String()] = st
+	}
+	return res
+}
+
+// Apply writes all the plan's changes to db in a single batch.
+func (p *Plan) Apply(db *leveldb.DB) error {
+	batch := new(leveldb.Batch)
+	for _, sKey := range p.order {
+		c := p.changes[sKey]
+		if c.delete {
+			batch.Delete(c.key)
+		} else {
+			batch.Put(c.key, c.val)
+		}
+	}
+	if err := db.Write(batch, nil); err != nil {
+		return fmt.Errorf("error applying %q: %w", p.Description, err)
+	}
+	return nil
+}
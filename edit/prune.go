@@ -0,0 +1,32 @@
+package edit
+
+import (
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// PruneChunks returns a Plan that deletes, in place, every chunk keep
+// rejects, along with the actor digests, actors and villages that go
+// with them (see save.PruneFilter). save.PruneToFolder does the same
+// into a new world instead.
+func PruneChunks(db *leveldb.DB, keep save.KeepFunc, opts save.PruneOptions, description string) (*Plan, error) {
+	filter, err := save.NewPruneFilter(db, keep, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan := NewPlan(description)
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &parse.KeyVal{Key: iter.Key(), Val: iter.Value()}
+		if !filter.Keep(kv) {
+			plan.Delete(kv.Key, kv.Val)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,131 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// liveActorIDs returns the IDs of the actors listed in the digests of
+// the world's chunks, other than the given chunks of one dimension.
+func liveActorIDs(db *leveldb.DB, dimension world.Dimension, except map[world.ChunkPos]bool) (map[string]bool, error) {
+	res := make(map[string]bool)
+	iter := db.NewIterator(util.BytesPrefix([]byte("digp")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		chunkPos, dim, err := parse.ParseDigpKey(iter.Key())
+		if err != nil || (dim == dimension && except[chunkPos]) {
+			continue
+		}
+		ids, err := parse.ParseDigp(iter.Value())
+		if err != nil {
+			continue
+		}
+		for _, id := range ids {
+			res[string(id)] = true
+		}
+	}
+	return res, iter.Error()
+}
+
+// RestoreChunks returns a Plan that replaces the selected chunks of a
+// dimension with their contents in backup: every record of each
+// chunk, its actor digest, and the actors it lists. Chunks that have
+// no data in the backup are deleted. A selection must be given.
+//
+// An actor from the backup is skipped if its ID is in the digest of a
+// chunk that isn't being restored, since it has moved there since the
+// backup, and restoring it would leave two chunks listing it.
+func RestoreChunks(db, backup *leveldb.DB, dimension world.Dimension, selection parse.ChunkSelection) (*Plan, error) {
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("no chunks selected to restore")
+	}
+	plan := NewPlan(fmt.Sprintf("Restore %s of %v from backup", selection, dimension))
+
+	restored := make(map[world.ChunkPos]bool)
+	for _, occupied := range []map[world.ChunkPos]bool{
+		parse.GetOccupiedChunkCoordinates(db)[dimension],
+		parse.GetOccupiedChunkCoordinates(backup)[dimension],
+	} {
+		for chunkPos := range occupied {
+			if selection.Contains(chunkPos) {
+				restored[chunkPos] = true
+			}
+		}
+	}
+	if len(restored) == 0 {
+		plan.Notef("no data in %s of %v in either world", selection, dimension)
+		return plan, nil
+	}
+	live, err := liveActorIDs(db, dimension, restored)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := sortedChunks(restored)
+	for _, chunkPos := range chunks {
+		if err := deleteChunk(db, plan, chunkPos, dimension); err != nil {
+			return nil, err
+		}
+	}
+
+	missing, skipped := 0, 0
+	for _, chunkPos := range chunks {
+		kvs, err := parse.AllEntriesWithChunkCoordinatePrefix(backup, chunkPos, dimension)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, kv := range kvs {
+			if kv.KeyType().IsChunkDataForDimension(dimension) {
+				plan.Put(kv.Key, nil, kv.Val)
+				found = true
+			}
+		}
+		if !found {
+			missing++
+		}
+
+		digpKey := parse.MakeDigpKey(chunkPos, dimension)
+		digp, err := backup.Get(digpKey, nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ids, err := parse.ParseDigp(digp)
+		if err != nil {
+			return nil, fmt.Errorf("backup chunk %v in %v: %w", chunkPos, dimension, err)
+		}
+		var kept []byte
+		for _, id := range ids {
+			if live[string(id)] {
+				skipped++
+				continue
+			}
+			key := parse.MakeActorKey(id)
+			val, err := backup.Get(key, nil)
+			if errors.Is(err, leveldb.ErrNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			plan.Put(key, nil, val)
+			kept = append(kept, id...)
+		}
+		if len(kept) > 0 {
+			plan.Put(digpKey, nil, kept)
+		}
+	}
+	if missing > 0 {
+		plan.Notef("deleted %d chunks that have no data in the backup", missing)
+	}
+	if skipped > 0 {
+		plan.Notef("skipped %d actors from the backup that are now in chunks not being restored", skipped)
+	}
+	return plan, nil
+}
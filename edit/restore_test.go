@@ -0,0 +1,83 @@
+package edit
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+func TestRestoreChunks(t *testing.T) {
+	ow := world.Overworld
+	// a is in the backup, b isn't, and c and d aren't selected. Actor
+	// 2 has moved from a to c since the backup; actor 10 is new in a.
+	a, b, c, d := world.ChunkPos{0, 0}, world.ChunkPos{1, 0}, world.ChunkPos{5, 5}, world.ChunkPos{2, 0}
+	unchanged := map[string]string{
+		chunkKey(c, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(c, ow, 0):                       "c0",
+		digpKey(c, ow):                              actorIDs(2),
+		actorKey(2):                                 "pig, now",
+		chunkKey(d, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(d, ow, 0):                       "d0",
+		"~local_player":                             "player",
+	}
+	current := map[string]string{
+		chunkKey(a, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(a, ow, 0):                       "a0, now",
+		subChunkKey(a, ow, 2):                       "a2, now",
+		digpKey(a, ow):                              actorIDs(10),
+		actorKey(10):                                "zombie, now",
+		chunkKey(b, ow, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(b, ow, 0):                       "b0, now",
+	}
+	backupKeys := map[string]string{
+		chunkKey(a, ow, parse.LevelChunkTagVersion): "\x27",
+		chunkKey(a, ow, parse.LevelChunkTagData2D):  "a heightmap",
+		subChunkKey(a, ow, 0):                       "a0, then",
+		subChunkKey(a, ow, 1):                       "a1, then",
+		digpKey(a, ow):                              actorIDs(1, 2),
+		actorKey(1):                                 "cow, then",
+		actorKey(2):                                 "pig, then",
+		subChunkKey(c, ow, 0):                       "c0, then",
+		"~local_player":                             "player, then",
+	}
+	for k, v := range unchanged {
+		current[k] = v
+	}
+	db, backup := memDB(t, current), memDB(t, backupKeys)
+
+	selection := parse.ChunkSelection{{Min: a, Max: b}}
+	plan, err := RestoreChunks(db, backup, ow, selection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		chunkKey(a, ow, parse.LevelChunkTagVersion): "\x27",
+		chunkKey(a, ow, parse.LevelChunkTagData2D):  "a heightmap",
+		subChunkKey(a, ow, 0):                       "a0, then",
+		subChunkKey(a, ow, 1):                       "a1, then",
+		digpKey(a, ow):                              actorIDs(1),
+		actorKey(1):                                 "cow, then",
+	}
+	for k, v := range unchanged {
+		want[k] = v
+	}
+	checkKeys(t, dumpDB(t, db), want)
+	for _, note := range []string{
+		"deleted 1 chunks that have no data in the backup",
+		"skipped 1 actors from the backup that are now in chunks not being restored",
+	} {
+		if !slices.Contains(plan.Notes, note) {
+			t.Errorf("want note %q; got %q", note, plan.Notes)
+		}
+	}
+
+	if _, err := RestoreChunks(db, backup, ow, nil); err == nil {
+		t.Error("want an error restoring with no selection")
+	}
+}
@@ -0,0 +1,104 @@
+package edit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// SnapshotDir returns the directory that holds snapshots of the world
+// in worldDir: a sibling directory with ".snapshots" appended, so
+// snapshots don't end up inside the world itself.
+func SnapshotDir(worldDir string) string {
+	return filepath.Clean(worldDir) + ".snapshots"
+}
+
+// Snapshot copies the whole world folder (level.dat, db, and
+// everything else) into a new timestamped directory under
+// SnapshotDir(worldDir), and returns the new directory's path. The
+// world's leveldb must not be open for writing while this runs.
+func Snapshot(worldDir string) (string, error) {
+	dest := filepath.Join(SnapshotDir(worldDir), time.Now().Format("20060102-150405"))
+	if _, err := os.Stat(dest); err == nil {
+		return "", fmt.Errorf("snapshot %q already exists", dest)
+	}
+	if err := save.CopyDir(worldDir, dest); err != nil {
+		return "", fmt.Errorf("error snapshotting %q: %w", worldDir, err)
+	}
+	return dest, nil
+}
+
+// Options controls how Run builds and applies a Plan.
+type Options struct {
+	DryRun     bool      // Only print the plan; don't snapshot or change anything
+	NoSnapshot bool      // Don't snapshot the world before applying the plan
+	Compact    bool      // Compact the leveldb after applying the plan, so deleted data leaves the disk
+	Out        io.Writer // Where to print progress and the plan; defaults to os.Stdout
+}
+
+// Run is the standard way to run an operation on the world in
+// worldDir. Unless this is a dry run, it snapshots the world first.
+// It then opens the world's leveldb, calls build to make the Plan,
+// updates the checksums of the chunks it changes (see FixCheckSums),
+// prints it, and applies it unless this is a dry run. With
+// opts.Compact, it then compacts the leveldb.
+func Run(worldDir string, opts Options, build func(db *leveldb.DB) (*Plan, error)) (*Plan, error) {
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if !opts.DryRun && !opts.NoSnapshot {
+		snapshot, err := Snapshot(worldDir)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "Snapshot saved to %s\n", snapshot)
+	}
+
+	db, err := leveldb.OpenFile(filepath.Join(worldDir, "db"), save.DBOptions(opts.DryRun))
+	if err != nil {
+		return nil, fmt.Errorf("error opening leveldb: %w", err)
+	}
+	defer db.Close()
+
+	plan, err := build(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.FixCheckSums(db); err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		fmt.Fprintf(out, "Dry run: ")
+	}
+	plan.Print(out)
+	if opts.DryRun || plan.Len() == 0 {
+		return plan, nil
+	}
+	if err := plan.Apply(db); err != nil {
+		return plan, err
+	}
+	fmt.Fprintf(out, "Applied %d changes\n", plan.Len())
+	if opts.Compact {
+		dbDir := filepath.Join(worldDir, "db")
+		before, err := save.DirSize(dbDir)
+		if err != nil {
+			return plan, err
+		}
+		if err := CompactDB(db, nil); err != nil {
+			return plan, err
+		}
+		after, err := save.DirSize(dbDir)
+		if err != nil {
+			return plan, err
+		}
+		fmt.Fprintf(out, "Compacted leveldb: %d -> %d bytes\n", before, after)
+	}
+	return plan, nil
+}
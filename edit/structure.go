@@ -0,0 +1,193 @@
+package edit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// firstNewActorID is where NewActorIDs starts counting. The game gives
+// actors IDs counting up from the bottom of a negative 2^32 block that
+// changes each time the world loads, so IDs up here won't clash with
+// ones it hands out later.
+const firstNewActorID = int64(1) << 62
+
+// NewActorIDs returns n actor IDs not used by any actor in the world.
+// Like parse.DecodeValue, it takes the 8 bytes of an actorprefix key
+// to be the actor's UniqueID, little-endian.
+func NewActorIDs(db *leveldb.DB, n int) ([]int64, error) {
+	next := firstNewActorID
+	iter := db.NewIterator(util.BytesPrefix(parse.MakeActorKey(nil)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if id, ok := parse.ActorID(iter.Key()); ok {
+			next = max(next, int64(binary.LittleEndian.Uint64(id))+1)
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	res := make([]int64, n)
+	for i := range res {
+		res[i] = next + int64(i)
+	}
+	return res, nil
+}
+
+// PlaceStructure returns a Plan that places a structure with its
+// minimum corner at origin. Void blocks are left alone; every other
+// block replaces both layers of the world's block, and any block
+// entity it had. The structure's block entities and entities are
+// moved to their new positions, and the entities are given new IDs
+// and added to their chunks' actor digests. Nothing is placed in
+// chunks with no data, since there's nothing there to load.
+func PlaceStructure(db *leveldb.DB, s *parse.Structure, dimension world.Dimension, origin cube.Pos) (*Plan, error) {
+	box := s.Box(origin)
+	plan := NewPlan(fmt.Sprintf("Place %dx%dx%d structure in %v, box %s", s.Size[0], s.Size[1], s.Size[2], dimension, box))
+	if clip, ok := box.Intersect(parse.DimensionBox(dimension)); !ok || clip != box {
+		return nil, fmt.Errorf("structure at %v, box %s, doesn't fit in the height limits of %v", origin, box, dimension)
+	}
+	offset := origin.Sub(s.Origin)
+	air := parse.AirBlock()
+
+	blockEntities := make(map[world.ChunkPos][]map[string]any)
+	for index, be := range s.BlockEntities {
+		if s.Indices[0][index] == parse.StructureVoid {
+			continue
+		}
+		pos := origin.Add(s.Pos(index))
+		be = maps.Clone(be)
+		be["x"], be["y"], be["z"] = int32(pos[0]), int32(pos[1]), int32(pos[2])
+		chunkPos := world.ChunkPos{int32(pos[0] >> 4), int32(pos[2] >> 4)}
+		blockEntities[chunkPos] = append(blockEntities[chunkPos], be)
+	}
+
+	present := make(map[world.ChunkPos]bool)
+	missing := 0
+	for _, chunkPos := range box.Chunks() {
+		ce, err := loadChunkEdit(db, chunkPos, dimension)
+		if err != nil {
+			return nil, err
+		}
+		if ce == nil {
+			missing++
+			continue
+		}
+		present[chunkPos] = true
+
+		baseX, baseZ := int(chunkPos.X())<<4, int(chunkPos.Z())<<4
+		chunkBox := parse.Box{
+			Min: cube.Pos{baseX, box.Min[1], baseZ},
+			Max: cube.Pos{baseX + 15, box.Max[1], baseZ + 15},
+		}
+		clip, _ := chunkBox.Intersect(box)
+		for x := clip.Min[0]; x <= clip.Max[0]; x++ {
+			for z := clip.Min[2]; z <= clip.Max[2]; z++ {
+				for y := clip.Min[1]; y <= clip.Max[1]; y++ {
+					i := s.Index(x-origin[0], y-origin[1], z-origin[2])
+					if s.Indices[0][i] == parse.StructureVoid {
+						continue
+					}
+					ce.removeBlockEntities[cube.Pos{x, y, z}] = true
+					block := s.Palette[s.Indices[0][i]]
+					second := air
+					if index := s.Indices[1][i]; index != parse.StructureVoid {
+						second = s.Palette[index]
+					}
+
+					sce, err := ce.subChunk(int32(y>>4), true)
+					if err != nil {
+						return nil, err
+					}
+					sc := &sce.sc
+					if !parse.SameBlock(sc.Block(x&15, y&15, z&15, 0), block) {
+						sc.SetBlock(x&15, y&15, z&15, 0, block)
+						sce.changed = true
+					}
+					if (sc.LayerCount() > 1 || !parse.IsAirBlock(second)) && !parse.SameBlock(sc.Block(x&15, y&15, z&15, 1), second) {
+						sc.SetBlock(x&15, y&15, z&15, 1, second)
+						sce.changed = true
+					}
+				}
+			}
+		}
+		ce.addBlockEntities = blockEntities[chunkPos]
+		if err := ce.finish(plan); err != nil {
+			return nil, err
+		}
+	}
+	if missing > 0 {
+		plan.Notef("skipped %d chunks with no data", missing)
+	}
+
+	if err := placeEntities(db, plan, s.Entities, dimension, offset, present); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// placeEntities adds the entities to the plan, moved by offset, with
+// new IDs, in the chunks that are present.
+func placeEntities(db *leveldb.DB, plan *Plan, entities []map[string]any, dimension world.Dimension, offset cube.Pos, present map[world.ChunkPos]bool) error {
+	ids, err := NewActorIDs(db, len(entities))
+	if err != nil {
+		return err
+	}
+	digests := make(map[world.ChunkPos][]byte)
+	var order []world.ChunkPos
+	skipped := 0
+	for i, data := range entities {
+		data = maps.Clone(data)
+		pos, ok := data["Pos"].([]any)
+		if !ok || len(pos) != 3 {
+			skipped++
+			continue
+		}
+		newPos := make([]any, 3)
+		for j := range pos {
+			f, _ := pos[j].(float32)
+			newPos[j] = f + float32(offset[j])
+		}
+		data["Pos"] = newPos
+		data["UniqueID"] = ids[i]
+		blockPos, _ := parse.EntityBlockPos(data)
+		chunkPos := world.ChunkPos{int32(blockPos[0] >> 4), int32(blockPos[2] >> 4)}
+		if !present[chunkPos] {
+			skipped++
+			continue
+		}
+
+		val, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+		if err != nil {
+			return fmt.Errorf("unable to encode entity %d: %w", i, err)
+		}
+		id := binary.LittleEndian.AppendUint64(nil, uint64(ids[i]))
+		plan.Put(parse.MakeActorKey(id), nil, val)
+		if _, ok := digests[chunkPos]; !ok {
+			order = append(order, chunkPos)
+		}
+		digests[chunkPos] = append(digests[chunkPos], id...)
+	}
+	if skipped > 0 {
+		plan.Notef("skipped %d entities with no position, or in chunks with no data", skipped)
+	}
+
+	for _, chunkPos := range order {
+		key := parse.MakeDigpKey(chunkPos, dimension)
+		old, err := db.Get(key, nil)
+		if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+			return err
+		}
+		plan.Put(key, old, append(slices.Clone(old), digests[chunkPos]...))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package edit
+
+import (
+	"fmt"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/google/uuid"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// findTickingArea returns the ticking area with the given name or
+// UUID, and its current value. It's an error for none or several to
+// match.
+func findTickingArea(db *leveldb.DB, name string) (parse.TickingArea, []byte, error) {
+	areas, errs := parse.ReadTickingAreas(db)
+	var found []parse.TickingArea
+	for _, t := range areas {
+		if t.Matches(name) {
+			found = append(found, t)
+		}
+	}
+	switch len(found) {
+	case 0:
+		if len(errs) > 0 {
+			return parse.TickingArea{}, nil, fmt.Errorf("no ticking area %q (and %d couldn't be read: %v)", name, len(errs), errs[0])
+		}
+		return parse.TickingArea{}, nil, fmt.Errorf("no ticking area %q", name)
+	case 1:
+	default:
+		return parse.TickingArea{}, nil, fmt.Errorf("%d ticking areas are named %q; use the UUID instead", len(found), name)
+	}
+	val, err := db.Get([]byte(found[0].Key), nil)
+	if err != nil {
+		return parse.TickingArea{}, nil, err
+	}
+	return found[0], val, nil
+}
+
+// AddTickingArea returns a Plan that adds a ticking area, with a new
+// UUID if it has none. Names must be unique within a dimension, as
+// they are in-game.
+func AddTickingArea(db *leveldb.DB, t parse.TickingArea) (*Plan, error) {
+	areas, _ := parse.ReadTickingAreas(db)
+	for _, other := range areas {
+		if other.Name == t.Name && other.Dimension == t.Dimension {
+			return nil, fmt.Errorf("there is already a ticking area named %q in the %s", t.Name, t.Dimension)
+		}
+	}
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+		t.Key = string(parse.MakeTickingAreaKey(t.ID))
+	}
+	val, err := t.Encode()
+	if err != nil {
+		return nil, err
+	}
+	plan := NewPlan(fmt.Sprintf("Add ticking area %q in the %s, chunks %s", t.Name, t.Dimension, t.Area()))
+	plan.Put([]byte(t.Key), nil, val)
+	return plan, nil
+}
+
+// RemoveTickingArea returns a Plan that removes the ticking area with
+// the given name or UUID.
+func RemoveTickingArea(db *leveldb.DB, name string) (*Plan, error) {
+	t, val, err := findTickingArea(db, name)
+	if err != nil {
+		return nil, err
+	}
+	plan := NewPlan(fmt.Sprintf("Remove ticking area %q in the %s (%d chunks)", t.Name, t.Dimension, t.ChunkCount()))
+	plan.Delete([]byte(t.Key), val)
+	return plan, nil
+}
+
+// ResizeTickingArea returns a Plan that changes the chunks the ticking
+// area with the given name or UUID covers, and whether it's a circle.
+func ResizeTickingArea(db *leveldb.DB, name string, area parse.ChunkArea, isCircle bool) (*Plan, error) {
+	t, val, err := findTickingArea(db, name)
+	if err != nil {
+		return nil, err
+	}
+	before := t.ChunkCount()
+	t.SetArea(area)
+	t.IsCircle = isCircle
+	newVal, err := t.Encode()
+	if err != nil {
+		return nil, err
+	}
+	plan := NewPlan(fmt.Sprintf("Resize ticking area %q in the %s to chunks %s (%d -> %d chunks)", t.Name, t.Dimension, area, before, t.ChunkCount()))
+	plan.Put([]byte(t.Key), val, newVal)
+	return plan, nil
+}
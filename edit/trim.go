@@ -0,0 +1,96 @@
+package edit
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/parse"
+	"golang.org/x/exp/maps"
+)
+
+// TrimMode says what TrimSubChunks does with the sub-chunks it trims.
+type TrimMode int
+
+const (
+	TrimDelete TrimMode = iota // Delete the sub-chunk keys
+	TrimClear                  // Replace the sub-chunks with air
+)
+
+// String returns the name of the TrimMode.
+func (m TrimMode) String() string {
+	switch m {
+	case TrimDelete:
+		return "delete"
+	case TrimClear:
+		return "clear"
+	}
+	return fmt.Sprintf("TrimMode(%d)", int(m))
+}
+
+// TrimSubChunks returns a Plan that trims every sub-chunk of the
+// selected chunks that lies entirely outside the y range keepMinY to
+// keepMaxY (inclusive), so a sub-chunk that holds any block in the
+// range is kept. The range must be within the height limits of the
+// dimension. Sub-chunks outside those limits are always trimmed.
+// Block entities in trimmed sub-chunks are removed, and chunk
+// heightmaps are updated to match.
+func TrimSubChunks(db *leveldb.DB, dimension world.Dimension, selection parse.ChunkSelection, keepMinY, keepMaxY int, mode TrimMode) (*Plan, error) {
+	r := dimension.Range()
+	if keepMinY > keepMaxY {
+		return nil, fmt.Errorf("trim range is empty: %d > %d", keepMinY, keepMaxY)
+	}
+	if keepMinY < r.Min() || keepMaxY > r.Max() {
+		return nil, fmt.Errorf("trim range %d to %d is outside the height limits of %v (%d to %d)", keepMinY, keepMaxY, dimension, r.Min(), r.Max())
+	}
+
+	description := fmt.Sprintf("Trim (%s) sub-chunks outside y=%d to %d in %v, %s", mode, keepMinY, keepMaxY, dimension, selection)
+	plan := NewPlan(description)
+	keepMinIndex, keepMaxIndex := int32(keepMinY>>4), int32(keepMaxY>>4)
+	dimMinIndex, dimMaxIndex := int32(r.Min()>>4), int32(r.Max()>>4)
+
+	for _, chunkPos := range sortedChunks(parse.GetOccupiedChunkCoordinates(db)[dimension]) {
+		if !selection.Contains(chunkPos) {
+			continue
+		}
+		ce, err := loadChunkEdit(db, chunkPos, dimension)
+		if err != nil {
+			return nil, err
+		}
+		if ce == nil {
+			continue
+		}
+		for _, yIndex := range ce.subChunkYIndexes() {
+			if yIndex >= keepMinIndex && yIndex <= keepMaxIndex {
+				continue
+			}
+			ce.removeSubChunkBlockEntities(yIndex)
+			if mode == TrimDelete || yIndex < dimMinIndex || yIndex > dimMaxIndex {
+				ce.deleteSubChunk(yIndex)
+				continue
+			}
+			ce.subChunks[yIndex] = &subChunkEdit{
+				kv:      ce.findSubChunk(yIndex),
+				sc:      parse.NewSubChunk(yIndex),
+				changed: true,
+			}
+		}
+		if err := ce.finish(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// sortedChunks returns the chunk positions in a set, sorted by x then
+// z, so plans come out in a predictable order.
+func sortedChunks(chunks map[world.ChunkPos]bool) []world.ChunkPos {
+	res := maps.Keys(chunks)
+	slices.SortFunc(res, func(a, b world.ChunkPos) int {
+		return cmp.Or(cmp.Compare(a.X(), b.X()), cmp.Compare(a.Z(), b.Z()))
+	})
+	return res
+}
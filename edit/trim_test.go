@@ -0,0 +1,110 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+func TestTrimSubChunks(t *testing.T) {
+	ow := world.Overworld
+	// Chunk pos starts at block x=16, z=32; other isn't selected.
+	pos, other := world.ChunkPos{1, 2}, world.ChunkPos{5, 5}
+	// Keeping y=5 to 40 keeps sub-chunks 0 to 2: each holds a block in
+	// the range.
+	keys := map[string]string{
+		chunkKey(pos, ow, parse.LevelChunkTagVersion):     "\x28",
+		chunkKey(pos, ow, parse.LevelChunkTagData3D):      data3D(ow),
+		chunkKey(pos, ow, parse.LevelChunkTagBlockEntity): blockEntities(t, cube.Pos{19, -10, 36}, cube.Pos{19, 20, 36}, cube.Pos{19, 50, 36}),
+		subChunkKey(pos, ow, -4):                          stoneSubChunk(t, -4, cube.Pos{0, 0, 0}),
+		subChunkKey(pos, ow, -1):                          stoneSubChunk(t, -1, cube.Pos{3, 6, 4}),
+		subChunkKey(pos, ow, 0):                           stoneSubChunk(t, 0, cube.Pos{0, 1, 0}),
+		subChunkKey(pos, ow, 2):                           stoneSubChunk(t, 2, cube.Pos{3, 7, 4}),
+		subChunkKey(pos, ow, 3):                           stoneSubChunk(t, 3, cube.Pos{3, 2, 4}),
+		subChunkKey(pos, ow, 9):                           stoneSubChunk(t, 9, cube.Pos{3, 0, 4}),
+		chunkKey(other, ow, parse.LevelChunkTagVersion):   "\x28",
+		subChunkKey(other, ow, 9):                         stoneSubChunk(t, 9),
+	}
+	selection := parse.ChunkSelection{{Min: pos, Max: pos}}
+
+	for _, mode := range []TrimMode{TrimDelete, TrimClear} {
+		t.Run(mode.String(), func(t *testing.T) {
+			db := memDB(t, keys)
+			plan, err := TrimSubChunks(db, ow, selection, 5, 40, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := plan.Apply(db); err != nil {
+				t.Fatal(err)
+			}
+			got := dumpDB(t, db)
+
+			// Only the block entity in a kept sub-chunk's range is left.
+			beKey := chunkKey(pos, ow, parse.LevelChunkTagBlockEntity)
+			if want := []cube.Pos{{19, 20, 36}}; !reflect.DeepEqual(blockEntityPositions(t, got[beKey]), want) {
+				t.Errorf("want block entities at %v; got %v", want, blockEntityPositions(t, got[beKey]))
+			}
+			d3Key := chunkKey(pos, ow, parse.LevelChunkTagData3D)
+			checkHeightMap(t, got[d3Key], ow, map[[2]int]int32{{0, 0}: 1, {3, 4}: 39})
+			delete(got, beKey)
+			delete(got, d3Key)
+
+			want := map[string]string{
+				chunkKey(pos, ow, parse.LevelChunkTagVersion):   "\x28",
+				subChunkKey(pos, ow, 0):                         keys[subChunkKey(pos, ow, 0)],
+				subChunkKey(pos, ow, 2):                         keys[subChunkKey(pos, ow, 2)],
+				chunkKey(other, ow, parse.LevelChunkTagVersion): "\x28",
+				subChunkKey(other, ow, 9):                       keys[subChunkKey(other, ow, 9)],
+			}
+			if mode == TrimClear {
+				for _, yIndex := range []int32{-4, -1, 3, 9} {
+					want[subChunkKey(pos, ow, yIndex)] = stoneSubChunk(t, yIndex)
+				}
+			}
+			checkKeys(t, got, want)
+		})
+	}
+}
+
+func TestTrimSubChunksNether(t *testing.T) {
+	nether := world.Nether
+	pos := world.ChunkPos{0, 0}
+	db := memDB(t, map[string]string{
+		chunkKey(pos, nether, parse.LevelChunkTagVersion): "\x28",
+		chunkKey(pos, nether, parse.LevelChunkTagData3D):  data3D(nether),
+		subChunkKey(pos, nether, -1):                      stoneSubChunk(t, -1, cube.Pos{1, 15, 1}),
+		subChunkKey(pos, nether, 0):                       stoneSubChunk(t, 0, cube.Pos{1, 3, 1}),
+		subChunkKey(pos, nether, 7):                       stoneSubChunk(t, 7, cube.Pos{2, 15, 2}),
+		subChunkKey(pos, nether, 8):                       stoneSubChunk(t, 8, cube.Pos{2, 0, 2}),
+	})
+	selection := parse.ChunkSelection{{Min: pos, Max: pos}}
+
+	if _, err := TrimSubChunks(db, nether, selection, 0, 128, TrimClear); err == nil {
+		t.Error("want an error keeping y=128 in the nether")
+	}
+	if _, err := TrimSubChunks(db, nether, selection, 40, 39, TrimClear); err == nil {
+		t.Error("want an error for an empty range")
+	}
+
+	// Sub-chunks outside the nether's 0 to 127 are deleted even when
+	// clearing.
+	plan, err := TrimSubChunks(db, nether, selection, 0, 127, TrimClear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(db); err != nil {
+		t.Fatal(err)
+	}
+	got := dumpDB(t, db)
+	d3Key := chunkKey(pos, nether, parse.LevelChunkTagData3D)
+	checkHeightMap(t, got[d3Key], nether, map[[2]int]int32{{1, 1}: 3, {2, 2}: 127})
+	delete(got, d3Key)
+	checkKeys(t, got, map[string]string{
+		chunkKey(pos, nether, parse.LevelChunkTagVersion): "\x28",
+		subChunkKey(pos, nether, 0):                       stoneSubChunk(t, 0, cube.Pos{1, 3, 1}),
+		subChunkKey(pos, nether, 7):                       stoneSubChunk(t, 7, cube.Pos{2, 15, 2}),
+	})
+}
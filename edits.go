@@ -0,0 +1,328 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/edit"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// editFlags is flags plus the flags shared by the subcommands that
+// change a world in place.
+type editFlags struct {
+	*flags
+	dryRun     *bool
+	noSnapshot *bool
+	compact    *bool
+}
+
+func newEditFlags(name string) *editFlags {
+	f := newFlags(name)
+	return &editFlags{
+		flags:      f,
+		dryRun:     f.Bool("dry-run", false, "print what would change, but don't change anything"),
+		noSnapshot: f.Bool("no-snapshot", false, "don't snapshot the world before changing it"),
+		compact:    f.Bool("compact", false, "compact the leveldb afterwards, so disk usage actually drops"),
+	}
+}
+
+// run runs an edit on the world given by -world, using edit.Run. With
+// -json, the plan's summary is written to stdout.
+func (f *editFlags) run(build func(db *leveldb.DB) (*edit.Plan, error)) error {
+	worldDir, err := f.worldDir()
+	if err != nil {
+		return err
+	}
+	plan, err := edit.Run(worldDir, edit.Options{
+		DryRun:     *f.dryRun,
+		NoSnapshot: *f.noSnapshot,
+		Compact:    *f.compact,
+		Out:        f.out(),
+	}, build)
+	if err != nil {
+		return err
+	}
+	if *f.json {
+		return writeJSON(plan.Summary())
+	}
+	return nil
+}
+
+// boxFlags is editFlags plus -dim and -box, for the block commands.
+type boxFlags struct {
+	*editFlags
+	boxString *string
+}
+
+func newBoxFlags(name string) *boxFlags {
+	f := newEditFlags(name)
+	f.addDim("overworld", false)
+	return &boxFlags{
+		editFlags: f,
+		boxString: f.String("box", "", "box to edit, as x1,y1,z1,x2,y2,z2 (inclusive)"),
+	}
+}
+
+// box returns the box given by -box.
+func (f *boxFlags) box() (parse.Box, error) {
+	if *f.boxString == "" {
+		return parse.Box{}, usageErrorf("%s needs a -box", f.Name())
+	}
+	box, err := parse.ParseBox(*f.boxString)
+	if err != nil {
+		return parse.Box{}, usageError{err}
+	}
+	return box, nil
+}
+
+func runReplace(args []string) error {
+	f := newBoxFlags("replace")
+	var fromStates, toStates stateFlags
+	from := f.String("from", "", "name of the block to replace")
+	to := f.String("to", "", "name of the block to replace it with")
+	f.Var(&fromStates, "from-state", "block state the replaced blocks must have, as name=value (repeatable)")
+	f.Var(&toStates, "to-state", "block state of the new block, as name=value (repeatable)")
+	f.Parse(args)
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	box, err := f.box()
+	if err != nil {
+		return err
+	}
+	q, err := parse.NewBlockQuery(*from, fromStates...)
+	if err != nil {
+		return usageError{err}
+	}
+	block, err := parse.NewBlock(*to, toStates...)
+	if err != nil {
+		return usageError{err}
+	}
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.ReplaceBlocks(db, dimension, box, q, block)
+	})
+}
+
+func runFill(args []string) error {
+	f := newBoxFlags("fill")
+	var states stateFlags
+	name := f.String("block", "", "name of the block to fill with")
+	f.Var(&states, "state", "block state of the block, as name=value (repeatable)")
+	f.Parse(args)
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	box, err := f.box()
+	if err != nil {
+		return err
+	}
+	block, err := parse.NewBlock(*name, states...)
+	if err != nil {
+		return usageError{err}
+	}
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.FillBlocks(db, dimension, box, block)
+	})
+}
+
+func runClear(args []string) error {
+	f := newBoxFlags("clear")
+	f.Parse(args)
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	box, err := f.box()
+	if err != nil {
+		return err
+	}
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.ClearBlocks(db, dimension, box)
+	})
+}
+
+func runTrim(args []string) error {
+	f := newEditFlags("trim")
+	f.addDim("overworld", false)
+	var selection parse.ChunkSelection
+	f.Var(&selection, "chunks", "chunks to trim, as x1,z1,x2,z2 in chunk coordinates (repeatable; default all chunks)")
+	minY := f.Int("min-y", 0, "lowest y coordinate to keep")
+	maxY := f.Int("max-y", 0, "highest y coordinate to keep")
+	modeName := f.String("mode", "delete", "what to do with trimmed sub-chunks: delete or clear")
+	f.Parse(args)
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	var mode edit.TrimMode
+	switch *modeName {
+	case "delete":
+		mode = edit.TrimDelete
+	case "clear":
+		mode = edit.TrimClear
+	default:
+		return usageErrorf("unknown trim mode %q; want delete or clear", *modeName)
+	}
+	keepMinY, keepMaxY := *minY, *maxY
+	setFlags := map[string]bool{}
+	f.Visit(func(fl *flag.Flag) { setFlags[fl.Name] = true })
+	if !setFlags["min-y"] {
+		keepMinY = dimension.Range().Min()
+	}
+	if !setFlags["max-y"] {
+		keepMaxY = dimension.Range().Max()
+	}
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.TrimSubChunks(db, dimension, selection, keepMinY, keepMaxY, mode)
+	})
+}
+
+func runMove(args []string) error {
+	f := newEditFlags("move")
+	f.addDim("overworld", false)
+	areaString := f.String("area", "", "chunks to move, as x1,z1,x2,z2 in chunk coordinates")
+	toString := f.String("to", "", "where the area's minimum chunk goes, as x,z in chunk coordinates (default: where it is)")
+	toDimName := f.String("to-dim", "", "dimension to move the chunks to (default: -dim)")
+	keepSource := f.Bool("copy", false, "copy the chunks, giving their entities new IDs, instead of moving them")
+	f.Parse(args)
+	if *areaString == "" {
+		return usageErrorf("-area is required")
+	}
+	area, err := parse.ParseChunkArea(*areaString)
+	if err != nil {
+		return usageError{err}
+	}
+	from, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	to := from
+	if *toDimName != "" {
+		if to, err = parse.DimensionByName(*toDimName); err != nil {
+			return usageError{err}
+		}
+	}
+	dest := area.Min
+	if *toString != "" {
+		if dest, err = parseChunkPos(*toString); err != nil {
+			return err
+		}
+	}
+	dx, dz := dest.X()-area.Min.X(), dest.Z()-area.Min.Z()
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.MoveChunks(db, from, area, to, dx, dz, *keepSource)
+	})
+}
+
+func runRestore(args []string) error {
+	f := newEditFlags("restore")
+	f.addDim("overworld", false)
+	from := f.String("from", "", "the backup or snapshot to restore from: a world folder, .mcworld file, or world name")
+	var selection parse.ChunkSelection
+	f.Var(&selection, "chunks", "chunks to restore, as x1,z1,x2,z2 in chunk coordinates (repeatable)")
+	f.Parse(args)
+	if *from == "" || len(selection) == 0 {
+		return usageErrorf("restore needs -from and -chunks")
+	}
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	backup, err := openOtherWorld(*from)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.RestoreChunks(db, backup.DB, dimension, selection)
+	})
+}
+
+func runStripAir(args []string) error {
+	f := newEditFlags("strip-air")
+	f.addDim("all", true)
+	f.Parse(args)
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+		return edit.RemoveAirSubChunks(db, dimension)
+	})
+}
+
+func runCompact(args []string) error {
+	f := newFlags("compact")
+	f.addDim("all", true)
+	f.Parse(args)
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	worldDir, err := f.worldDir()
+	if err != nil {
+		return err
+	}
+	return edit.CompactWorld(worldDir, dimension, f.out())
+}
+
+func runExport(args []string) error {
+	f := newFlags("export")
+	f.addDim("overworld", false)
+	var keep parse.ChunkSelection
+	out := f.String("o", "", "the .mcworld file to write")
+	f.Var(&keep, "keep", "keep only these chunks of -dim, as x1,z1,x2,z2 in chunk coordinates (repeatable)")
+	dropMaps := f.Bool("drop-maps", false, "with -keep, also drop map items centred in dropped chunks")
+	f.Parse(args)
+	if *out == "" {
+		return usageErrorf("export needs an output (-o)")
+	}
+	keepFunc, err := f.keepFunc(keep)
+	if err != nil {
+		return err
+	}
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	stats, err := save.Export(w, *out, keepFunc, save.PruneOptions{DropMaps: *dropMaps})
+	if err != nil {
+		return err
+	}
+	if *f.json {
+		res := map[string]any{"world": w.Name, "file": *out}
+		if keepFunc != nil {
+			res["pruned"] = stats
+		}
+		return writeJSON(res)
+	}
+	if keepFunc != nil {
+		fmt.Printf("Pruned on export: %v\n", stats)
+	}
+	fmt.Printf("Exported %q to %s\n", w.Name, *out)
+	return nil
+}
+
+func runImport(args []string) error {
+	f := newFlags("import")
+	root := f.String("to", "./worlds", "folder to import the world into")
+	f.Parse(args)
+	if f.NArg() != 1 {
+		return usageErrorf("import needs exactly one .mcworld file")
+	}
+	dir, err := save.Import(f.Arg(0), *root)
+	if err != nil {
+		return err
+	}
+	if *f.json {
+		return writeJSON(map[string]string{"file": f.Arg(0), "dir": dir})
+	}
+	fmt.Printf("Imported %s to %s\n", f.Arg(0), dir)
+	return nil
+}
@@ -7,6 +7,7 @@ require (
 	github.com/chewxy/math32 v1.10.1
 	github.com/df-mc/dragonfly v0.9.12
 	github.com/df-mc/goleveldb v1.1.9
+	github.com/google/uuid v1.3.0
 	github.com/sandertv/gophertunnel v1.34.0
 	golang.org/x/exp v0.0.0-20230206171751-46f607a40771
 )
@@ -22,7 +23,6 @@ require (
 	github.com/go-gl/mathgl v1.0.0 // indirect
 	github.com/go-text/typesetting v0.0.0-20230803102845-24e03d8b5372 // indirect
 	github.com/golang/snappy v0.0.4 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/klauspost/compress v1.15.15 // indirect
 	github.com/segmentio/fasthash v1.0.3 // indirect
 	github.com/sirupsen/logrus v1.9.0 // indirect
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// worldInfo is the output of the info command.
+type worldInfo struct {
+	Name                  string         `json:"name"`
+	Source                string         `json:"source"`
+	Seed                  int64          `json:"seed"`
+	Spawn                 cube.Pos       `json:"spawn"`
+	LastPlayed            time.Time      `json:"last_played"`
+	LastOpenedWithVersion string         `json:"last_opened_with_version"`
+	GameType              int32          `json:"game_type"`
+	Difficulty            int32          `json:"difficulty"`
+	StorageVersion        int32          `json:"storage_version"`
+	Chunks                map[string]int `json:"chunks"` // By dimension
+	Warning               string         `json:"warning,omitempty"`
+}
+
+func listWorlds(f *flags, roots []string) error {
+	all := make(map[string][]save.WorldInfo)
+	for _, root := range roots {
+		worlds, err := save.Discover(root)
+		if err != nil {
+			return err
+		}
+		all[root] = worlds
+		if *f.json {
+			continue
+		}
+		fmt.Printf("%s:\n", root)
+		for _, w := range worlds {
+			fmt.Printf("  %-30s %10d bytes  %s  %s\n", w.Name, w.Size, w.LastPlayed.Format(time.DateTime), w.Dir)
+		}
+	}
+	if *f.json {
+		return writeJSON(all)
+	}
+	return nil
+}
+
+func runInfo(args []string) error {
+	f := newFlags("info")
+	list := f.Bool("list", false, "list the worlds in the folders given as arguments (default ./worlds)")
+	levelDat := f.Bool("level-dat", false, "write every level.dat field as JSON")
+	f.Parse(args)
+
+	if *list {
+		roots := f.Args()
+		if len(roots) == 0 {
+			roots = save.DefaultRoots()
+		}
+		return listWorlds(f, roots)
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	l := w.LevelDat
+	if l == nil {
+		return fmt.Errorf("%s has no level.dat", w.Source)
+	}
+	if *levelDat {
+		return writeJSON(l.Data)
+	}
+
+	info := worldInfo{
+		Name:                  w.Name,
+		Source:                w.Source,
+		Seed:                  l.Seed,
+		Spawn:                 l.Spawn,
+		LastPlayed:            l.LastPlayed,
+		LastOpenedWithVersion: l.LastOpenedWithVersion.String(),
+		GameType:              l.GameType,
+		Difficulty:            l.Difficulty,
+		StorageVersion:        l.StorageVersion,
+		Chunks:                make(map[string]int),
+	}
+	for _, dim := range []world.Dimension{world.Overworld, world.Nether, world.End} {
+		info.Chunks[fmt.Sprint(dim)] = len(w.OccupiedChunks(dim))
+	}
+	if err := l.CheckSupported(); err != nil {
+		info.Warning = err.Error()
+	}
+	if *f.json {
+		return writeJSON(info)
+	}
+
+	fmt.Printf("Name:              %s\n", info.Name)
+	fmt.Printf("Folder:            %s\n", info.Source)
+	fmt.Printf("Seed:              %d\n", info.Seed)
+	fmt.Printf("Spawn:             %v\n", info.Spawn)
+	fmt.Printf("Last played:       %s\n", info.LastPlayed.Format(time.DateTime))
+	fmt.Printf("Last opened with:  %s\n", info.LastOpenedWithVersion)
+	fmt.Printf("Game type:         %d\n", info.GameType)
+	fmt.Printf("Difficulty:        %d\n", info.Difficulty)
+	fmt.Printf("Storage version:   %d (header %d)\n", info.StorageVersion, l.HeaderVersion)
+	for _, dim := range []world.Dimension{world.Overworld, world.Nether, world.End} {
+		fmt.Printf("%-19s%d chunks\n", fmt.Sprint(dim)+":", info.Chunks[fmt.Sprint(dim)])
+	}
+	if info.Warning != "" {
+		fmt.Printf("Warning:           %s\n", info.Warning)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/edit"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// keyEntry is one key in the output of the keys command.
+type keyEntry struct {
+	Key       string `json:"key"` // Quoted Go string
+	Hex       string `json:"hex"`
+	Type      string `json:"type"`
+	Dimension string `json:"dimension,omitempty"`
+	ChunkX    *int32 `json:"chunk_x,omitempty"`
+	ChunkZ    *int32 `json:"chunk_z,omitempty"`
+	Size      int    `json:"size"`
+}
+
+// parsePrefix parses a key prefix given as a plain string, or as hex
+// after "0x".
+func parsePrefix(s string) ([]byte, error) {
+	if h, ok := strings.CutPrefix(s, "0x"); ok {
+		b, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, usageErrorf("bad hex prefix %q: %v", s, err)
+		}
+		return b, nil
+	}
+	return []byte(s), nil
+}
+
+// parseChunkPos parses a chunk position given as "x,z" in chunk
+// coordinates.
+func parseChunkPos(s string) (world.ChunkPos, error) {
+	x, z, ok := strings.Cut(s, ",")
+	if ok {
+		cx, errX := strconv.ParseInt(strings.TrimSpace(x), 10, 32)
+		cz, errZ := strconv.ParseInt(strings.TrimSpace(z), 10, 32)
+		if errX == nil && errZ == nil {
+			return world.ChunkPos{int32(cx), int32(cz)}, nil
+		}
+	}
+	return world.ChunkPos{}, usageErrorf("want chunk as x,z in chunk coordinates; got %q", s)
+}
+
+func runKeys(args []string) error {
+	f := newEditFlags("keys")
+	f.addDim("overworld", false)
+	typeName := f.String("type", "", `only list keys of this type, eg. "digp" or "SubChunkPrefix"`)
+	prefixString := f.String("prefix", "", "only list keys with this prefix (a string, or hex after 0x)")
+	chunkString := f.String("chunk", "", "only list keys of this chunk in -dim, as x,z in chunk coordinates")
+	limit := f.Int("limit", 0, "list at most this many keys (0 for no limit)")
+	getKey := f.String("get", "", "decode the value of this key (a string, or hex after 0x) instead of listing keys")
+	snbt := f.Bool("snbt", false, "with -get, write NBT values as SNBT instead of JSON")
+	typed := f.Bool("typed", false, "with -get, decode global records (scoreboard, mobevents, schedulerWT, ...) into named fields instead of raw NBT")
+	globals := f.Bool("globals", false, "decode every global record (scoreboard, mobevents, schedulerWT, ...) into named fields, as JSON")
+	setKey := f.String("set", "", "replace the value of this NBT key (a string, or hex after 0x) with the edited JSON in -from")
+	from := f.String("from", "", "with -set, the file of edited JSON, as written by -get")
+	f.Parse(args)
+
+	switch {
+	case *getKey != "" && *setKey != "":
+		return usageErrorf("use -get or -set, not both")
+	case *typed && *snbt:
+		return usageErrorf("use -typed or -snbt, not both")
+	case *globals:
+		return getGlobals(f.flags)
+	case *getKey != "":
+		key, err := parsePrefix(*getKey)
+		if err != nil {
+			return err
+		}
+		return getValue(f.flags, key, *snbt, *typed)
+	case *setKey != "":
+		key, err := parsePrefix(*setKey)
+		if err != nil {
+			return err
+		}
+		if *from == "" {
+			return usageErrorf("-set needs -from")
+		}
+		edited, err := os.ReadFile(*from)
+		if err != nil {
+			return err
+		}
+		return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+			return edit.SetNBTFromJSON(db, key, edited)
+		})
+	}
+
+	filter := parse.KeyFilter{Limit: *limit}
+	if *typeName != "" {
+		var err error
+		if filter.Types, err = parse.ParseKeyTypes(*typeName); err != nil {
+			return usageError{err}
+		}
+	}
+	prefix, err := parsePrefix(*prefixString)
+	if err != nil {
+		return err
+	}
+	filter.Prefix = prefix
+	if *chunkString != "" {
+		if len(prefix) > 0 {
+			return usageErrorf("use -prefix or -chunk, not both")
+		}
+		chunkPos, err := parseChunkPos(*chunkString)
+		if err != nil {
+			return err
+		}
+		if filter.Dimension, err = f.dimension(); err != nil {
+			return err
+		}
+		filter.Chunk = &chunkPos
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	kvs, err := parse.ListKeys(w.DB, filter)
+	if err != nil {
+		return err
+	}
+	entries := []keyEntry{}
+	for _, kv := range kvs {
+		entries = append(entries, newKeyEntry(kv))
+	}
+
+	if *f.json {
+		return writeJSON(entries)
+	}
+	for _, e := range entries {
+		where := ""
+		if e.ChunkX != nil {
+			where = fmt.Sprintf(" %s (%d,%d)", e.Dimension, *e.ChunkX, *e.ChunkZ)
+		}
+		fmt.Printf("%-50s %s%s, %d bytes\n", e.Key, e.Type, where, e.Size)
+	}
+	return nil
+}
+
+func newKeyEntry(kv *parse.KeyVal) keyEntry {
+	keyInfo := kv.KeyTypeAndChunkLocation()
+	entry := keyEntry{
+		Key:  strconv.Quote(string(kv.Key)),
+		Hex:  hex.EncodeToString(kv.Key),
+		Type: keyInfo.KeyType.String(),
+		Size: len(kv.Val),
+	}
+	if keyInfo.HasLocation {
+		x, z := keyInfo.ChunkPos.X(), keyInfo.ChunkPos.Z()
+		entry.Dimension = fmt.Sprint(keyInfo.Dimension)
+		entry.ChunkX, entry.ChunkZ = &x, &z
+	}
+	return entry
+}
+
+// getValue writes the decoded value of a key to stdout: as SNBT if
+// asked for and the value is NBT, otherwise as JSON. If typed is set,
+// global records are decoded into named fields by parse.DecodeGlobal.
+// Without -json, the key's details are written to stderr first.
+func getValue(f *flags, key []byte, asSNBT, typed bool) error {
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	val, err := w.DB.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return fmt.Errorf("no key %q", key)
+	}
+	if err != nil {
+		return err
+	}
+	kv := parse.NewKeyVal(key, val)
+	decode := parse.DecodeValue
+	if typed {
+		decode = parse.DecodeGlobal
+	}
+	decoded, err := decode(kv)
+	if typed && err != nil {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: can't decode %s value, so showing hex: %v\n", kv.KeyType(), err)
+	}
+	if !*f.json {
+		e := newKeyEntry(kv)
+		fmt.Fprintf(os.Stderr, "%s %s, %d bytes\n", e.Key, e.Type, e.Size)
+	}
+	if asSNBT && err == nil && kv.KeyType().IsNBT() {
+		fmt.Println(parse.FormatSNBT(decoded))
+		return nil
+	}
+	return writeJSON(decoded)
+}
+
+// getGlobals writes every global record, decoded into named fields, to
+// stdout as JSON.
+func getGlobals(f *flags) error {
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	records, errs := parse.GlobalRecords(w.DB)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return writeJSON(records)
+}
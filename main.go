@@ -1,533 +1,216 @@
+// Command bedrockprune inspects, edits and prunes Minecraft Bedrock
+// worlds. Run it without arguments for a list of subcommands.
+//
+// Every subcommand picks its world with -world (a folder, a .mcworld
+// file, or the name of a world in ./worlds), and most take -dim and
+// -json. The exit status is 0 on success, 1 on failure, and 2 for bad
+// usage.
 package main
 
 import (
-	"bytes"
-	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
-	"slices"
-	"strings"
 
 	"github.com/df-mc/dragonfly/server/world"
-	"github.com/df-mc/dragonfly/server/world/mcdb"
-	"github.com/df-mc/goleveldb/leveldb"
-	"github.com/sandertv/gophertunnel/minecraft/nbt"
 	"github.com/zellyn/bedrockprune/parse"
-	"github.com/zellyn/bedrockprune/resources"
-	"golang.org/x/exp/maps"
+	"github.com/zellyn/bedrockprune/save"
 )
 
-var asciiRe = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
-var asciiPrefix = regexp.MustCompile("^[a-zA-Z_-]{3,}")
-var hexSuffix = regexp.MustCompile("_[a-f]{1,3}$")
-
-var knownPrefixes = []string{
-	"AutonomousEntities",
-	"BiomeData",
-	"LevelChunkMetaDataDictionary",
-	"Nether",
-	"Overworld",
-	"TheEnd",
-	"mobevents",
-	"portals",
-	"schedulerWT",
-	"scoreboard",
-
-	"VILLAGE_",
-	"VILLAGE_Overworld_",
-	"map_-",
-	"player_server_",
-	"player_",
-
-	"actorprefix",
-	"digp",
-
-	// Not in my data, but from https://minecraft.wiki/w/Bedrock_Edition_level_format
-	"~local_player",
-	"game_flatworldlayers",
-	"structuretemplate",
-	"tickingarea",
+// command is a bedrockprune subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
 }
 
-func run() error {
-	db, err := mcdb.Open("./worlds/survivalone")
-	if err != nil {
-		return err
-	}
-
-	ch, err := db.LoadColumn(world.ChunkPos{0, 0}, world.Overworld)
-	if err != nil {
-		return err
+// commands is filled in by init, to avoid an initialization cycle with
+// usage.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"info", "show world metadata, or list worlds", runInfo},
+		{"keys", "list leveldb keys, or decode or edit one key's value", runKeys},
+		{"stats", "count keys and bytes by key type", runStats},
+		{"chunk", "describe everything stored for a chunk", runChunk},
+		{"players", "list players, with positions and inventories", runPlayers},
+		{"maps", "list map items, write them as PNG files, or check them against prune areas", runMaps},
+		{"tickingareas", "list ticking areas, or add, remove or resize one", runTickingAreas},
+		{"structure", "export a box as a .mcstructure file or import one, or list and extract saved structures", runStructure},
+		{"check", "check a world's leveldb for structural problems; exits with status 1 if it finds errors", runCheck},
+		{"diff", "compare a world with an earlier backup or snapshot, chunk by chunk", runDiff},
+		{"search", "find blocks by name and state", runSearch},
+		{"render", "render a map of an area to a PNG file", runRender},
+		{"prune", "delete chunks outside the areas to keep, in place or into a copy", runPrune},
+		{"snapshot", "snapshot a world, or list its snapshots", runSnapshot},
+		{"backup", "back up a world into an incremental backup store, or list, restore or remove its snapshots", runBackup},
+		{"assets", "show or download the game assets used for textures", runAssets},
+		{"view", "open the map viewer", runView},
+		{"replace", "replace one kind of block with another inside a box", runReplace},
+		{"fill", "fill a box with a block", runFill},
+		{"clear", "clear a box to air", runClear},
+		{"trim", "delete or clear sub-chunks above or below a y range", runTrim},
+		{"move", "move or copy chunks to another position or dimension", runMove},
+		{"restore", "replace chunks with their contents in a backup or snapshot", runRestore},
+		{"strip-air", "delete sub-chunks that hold nothing but air", runStripAir},
+		{"compact", "compact the leveldb so deleted data leaves the disk", runCompact},
+		{"export", "export a world, or a pruned copy of it, to a .mcworld file", runExport},
+		{"import", "extract a .mcworld file into a new world folder", runImport},
 	}
-
-	for x := uint8(0); x < 16; x++ {
-		for z := uint8(0); z < 16; z++ {
-			y := ch.HighestBlock(x, z)
-			rid := ch.Block(x, y, z, 0)
-			b, _ := world.BlockByRuntimeID(rid)
-
-			fmt.Printf(" %d:%d:%T", y, rid, b)
-		}
-		fmt.Printf("\n")
-	}
-
-	return nil
 }
 
-func run2() error {
-	db, err := leveldb.OpenFile("./worlds/survivalone/db", nil)
-	if err != nil {
-		return fmt.Errorf("error opening leveldb: %w", err)
-	}
-
-	iter := db.NewIterator(nil, nil)
-	defer iter.Release()
-	count := 0
-	counts := make([]int, 64)
-	prefixCounts := make(map[string]int)
-
-	for iter.Next() {
-		key := iter.Key()
-		sKey := string(key)
-		lk := len(key)
-		count++
-		counts[lk]++
-
-		if match := asciiPrefix.Find(key); match != nil {
-			prefix := string(match)
-			if suffix := hexSuffix.FindString(prefix); suffix != "" {
-				prefix = prefix[:len(prefix)-len(suffix)+1]
-			}
-			if strings.HasPrefix(prefix, "digp") && prefix != "digp" {
-				prefix = prefix[:len(prefix)-1]
-			}
-			if !slices.Contains(knownPrefixes, prefix) {
-				fmt.Printf("Key with unhandled prefix: %q (prefix=%q)\n", key, prefix)
-				os.Exit(1)
-			}
-			prefixCounts[prefix]++
-		}
-
-		switch lk {
-		case 9, 10, 13, 14, 19:
-			if !asciiRe.Match(key) {
-				if asciiPrefix.Match(key) && !strings.HasPrefix(sKey, "actorprefix") {
-					fmt.Printf("%v %q\n", key, key)
-					os.Exit(1)
-				}
-				// chunk-related
-				continue
-			}
-		}
-
-		switch sKey {
-		case "AutonomousEntities":
-			continue
-		case "BiomeData":
-			continue
-		case "LevelChunkMetaDataDictionary":
-			continue
-		case "Nether":
-			continue
-		case "Overworld":
-			continue
-		case "TheEnd":
-			continue
-		case "mobevents":
-			continue
-		case "portals":
-			continue
-		case "schedulerWT":
-			continue
-		case "scoreboard":
-			continue
-		}
-
-		prefix, _, hasPrefix := strings.Cut(sKey, "_")
-
-		if strings.HasPrefix(sKey, "digp") {
-			continue
-		}
-
-		if !hasPrefix {
-			fmt.Printf("Unhandled key: %q (length %d)\n", key, lk)
-			os.Exit(1)
-		}
-
-		switch prefix {
-		case "VILLAGE", "map", "player":
-			fmt.Printf("%q\n", key)
-			continue
-		default:
-			fmt.Printf("Unhandled prefix: %q\n", prefix)
-			os.Exit(1)
-		}
-	}
-
-	fmt.Printf("Total count: %d\n", count)
-	for i, c := range counts {
-		if c == 0 {
-			continue
-		}
-		fmt.Printf(" Length %2d: %d\n", i, c)
-	}
-
-	fmt.Printf("Prefix counts:\n")
-	for prefix, count := range prefixCounts {
-		fmt.Printf("%s: %d\n", prefix, count)
-	}
-
-	return nil
+// usageError is an error in how bedrockprune was invoked. It makes the
+// program exit with status 2 instead of 1.
+type usageError struct {
+	error
 }
 
-func run4() error {
-	keyTypeCounts := map[parse.KeyType]int{
-		parse.KeyTypePlayerServer:                 0,
-		parse.KeyTypePlayer:                       0,
-		parse.KeyTypeMap:                          0,
-		parse.KeyTypeVillageDwellers:              0,
-		parse.KeyTypeVillageInfo:                  0,
-		parse.KeyTypeVillagePlayers:               0,
-		parse.KeyTypeVillagePOI:                   0,
-		parse.KeyTypeVillageOverworldDwellers:     0,
-		parse.KeyTypeVillageOverworldInfo:         0,
-		parse.KeyTypeVillageOverworldPlayers:      0,
-		parse.KeyTypeVillageOverworldPOI:          0,
-		parse.KeyTypeAutonomousEntities:           0,
-		parse.KeyTypeBiomeData:                    0,
-		parse.KeyTypeLevelChunkMetaDataDictionary: 0,
-		parse.KeyTypeNether:                       0,
-		parse.KeyTypeOverworld:                    0,
-		parse.KeyTypeTheEnd:                       0,
-		parse.KeyTypeMobevents:                    0,
-		parse.KeyTypePortals:                      0,
-		parse.KeyTypeSchedulerWT:                  0,
-		parse.KeyTypeScoreboard:                   0,
-		parse.KeyTypeActorprefix:                  0,
-		parse.KeyTypeDigp:                         0,
-
-		// parse.KeyTypeLocalPlayer         // Not seen in my data
-		// parse.KeyTypeGameFlatworldLayers // Not seen in my data
-		// parse.KeyTypeStructureTemplate   // Not seen in my data
-		// parse.KeyTypeTickingArea         // Not seen in my data
-
-		parse.KeyTypeOverworldData3D:  0,
-		parse.KeyTypeOverworldVersion: 0,
-		parse.KeyTypeOverworldData2D:  0,
-		// parse.KeyTypeOverworldData2DLegacy:                       0,
-		parse.KeyTypeOverworldSubChunkPrefix: 0,
-		parse.KeyTypeOverworldLegacyTerrain:  0,
-		parse.KeyTypeOverworldBlockEntity:    0,
-		parse.KeyTypeOverworldEntity:         0,
-		parse.KeyTypeOverworldPendingTicks:   0,
-		// parse.KeyTypeOverworldLegacyBlockExtraData:               0,
-		parse.KeyTypeOverworldBiomeState:                         0,
-		parse.KeyTypeOverworldFinalizedState:                     0,
-		parse.KeyTypeOverworldConversionData:                     0,
-		parse.KeyTypeOverworldBorderBlocks:                       0,
-		parse.KeyTypeOverworldHardcodedSpawners:                  0,
-		parse.KeyTypeOverworldRandomTicks:                        0,
-		parse.KeyTypeOverworldCheckSums:                          0,
-		parse.KeyTypeOverworldGenerationSeed:                     0,
-		parse.KeyTypeOverworldGeneratedPreCavesAndCliffsBlending: 0,
-		parse.KeyTypeOverworldBlendingBiomeHeight:                0,
-		parse.KeyTypeOverworldMetaDataHash:                       0,
-		parse.KeyTypeOverworldBlendingData:                       0,
-		parse.KeyTypeOverworldActorDigestVersion:                 0,
-		parse.KeyTypeOverworldLegacyVersion:                      0,
-
-		parse.KeyTypeNetherData3D:  0,
-		parse.KeyTypeNetherVersion: 0,
-		parse.KeyTypeNetherData2D:  0,
-		// parse.KeyTypeNetherData2DLegacy:                       0,
-		parse.KeyTypeNetherSubChunkPrefix: 0,
-		parse.KeyTypeNetherLegacyTerrain:  0,
-		parse.KeyTypeNetherBlockEntity:    0,
-		parse.KeyTypeNetherEntity:         0,
-		parse.KeyTypeNetherPendingTicks:   0,
-		// parse.KeyTypeNetherLegacyBlockExtraData:               0,
-		parse.KeyTypeNetherBiomeState:                         0,
-		parse.KeyTypeNetherFinalizedState:                     0,
-		parse.KeyTypeNetherConversionData:                     0,
-		parse.KeyTypeNetherBorderBlocks:                       0,
-		parse.KeyTypeNetherHardcodedSpawners:                  0,
-		parse.KeyTypeNetherRandomTicks:                        0,
-		parse.KeyTypeNetherCheckSums:                          0,
-		parse.KeyTypeNetherGenerationSeed:                     0,
-		parse.KeyTypeNetherGeneratedPreCavesAndCliffsBlending: 0,
-		parse.KeyTypeNetherBlendingBiomeHeight:                0,
-		parse.KeyTypeNetherMetaDataHash:                       0,
-		parse.KeyTypeNetherBlendingData:                       0,
-		parse.KeyTypeNetherActorDigestVersion:                 0,
-		parse.KeyTypeNetherLegacyVersion:                      0,
-
-		parse.KeyTypeEndData3D:  0,
-		parse.KeyTypeEndVersion: 0,
-		parse.KeyTypeEndData2D:  0,
-		// parse.KeyTypeEndData2DLegacy:                       0,
-		parse.KeyTypeEndSubChunkPrefix: 0,
-		parse.KeyTypeEndLegacyTerrain:  0,
-		parse.KeyTypeEndBlockEntity:    0,
-		parse.KeyTypeEndEntity:         0,
-		parse.KeyTypeEndPendingTicks:   0,
-		// parse.KeyTypeEndLegacyBlockExtraData:               0,
-		parse.KeyTypeEndBiomeState:                         0,
-		parse.KeyTypeEndFinalizedState:                     0,
-		parse.KeyTypeEndConversionData:                     0,
-		parse.KeyTypeEndBorderBlocks:                       0,
-		parse.KeyTypeEndHardcodedSpawners:                  0,
-		parse.KeyTypeEndRandomTicks:                        0,
-		parse.KeyTypeEndCheckSums:                          0,
-		parse.KeyTypeEndGenerationSeed:                     0,
-		parse.KeyTypeEndGeneratedPreCavesAndCliffsBlending: 0,
-		parse.KeyTypeEndBlendingBiomeHeight:                0,
-		parse.KeyTypeEndMetaDataHash:                       0,
-		parse.KeyTypeEndBlendingData:                       0,
-		parse.KeyTypeEndActorDigestVersion:                 0,
-		parse.KeyTypeEndLegacyVersion:                      0,
-	}
-
-	db, err := leveldb.OpenFile("./worlds/survivalone/db", nil)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	count := 0
-
-	iter := db.NewIterator(nil, nil)
-	defer iter.Release()
-	for iter.Next() {
-		count++
-		kv := parse.NewKeyVal(iter.Key(), iter.Value())
-		keyType := kv.KeyType()
-		_, ok := keyTypeCounts[keyType]
-		if !ok {
-			fmt.Printf("Unexpected KeyType: %s for key %v (%d)\n", keyType, kv.Key, len(kv.Key))
-		}
-		keyTypeCounts[keyType]++
-
-		if keyType.IsSubChunkPrefix() {
-			_, err := parse.ParseSubChunk(kv)
-			if err != nil {
-				fmt.Printf("Error: %v\n", err)
-				os.Exit(1)
-			}
-		}
-	}
-
-	fmt.Printf("Saw %d keys and values\n", count)
+func usageErrorf(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
 
-	keys := maps.Keys(keyTypeCounts)
-	slices.Sort(keys)
-	longest := 0
-	for _, key := range keys {
-		if l := len(key.String()); l > longest {
-			longest = l
-		}
-	}
+// flags is a subcommand's flag set, with the flags every subcommand
+// shares.
+type flags struct {
+	*flag.FlagSet
+	worldName *string
+	dimName   *string
+	json      *bool
+}
 
-	for _, k := range keys {
-		fmt.Printf("%s:%s%d\n", k, strings.Repeat(" ", longest+3-len(k.String())), keyTypeCounts[k])
+// newFlags returns the flag set for a subcommand, with -world and
+// -json. Subcommands that work on one dimension add -dim with addDim.
+func newFlags(name string) *flags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return &flags{
+		FlagSet:   fs,
+		worldName: fs.String("world", "", "world: a folder, a .mcworld file, or the name of a world in ./worlds (default: the only world found there)"),
+		json:      fs.Bool("json", false, "write JSON to stdout"),
 	}
-
-	return nil
 }
 
-func printBlockNBTTypes(nbt map[string]any, indent int) {
-	for key, value := range nbt {
-		if val, ok := value.(map[string]any); ok {
-			printBlockNBTTypes(val, indent+4)
-		} else {
-			fmt.Printf("%s%s: %v (%T)\n", strings.Repeat(" ", indent), key, value, value)
-		}
+// addDim adds the -dim flag, with the given default. If allowAll is
+// true, "all" is accepted too, and dimension returns nil for it.
+func (f *flags) addDim(def string, allowAll bool) {
+	usage := "dimension: overworld, nether, or end"
+	if allowAll {
+		usage = "dimension: overworld, nether, end, or all"
 	}
+	f.dimName = f.String("dim", def, usage)
 }
 
-func runShowZeroZero() error {
-	db, err := leveldb.OpenFile("./worlds/survivalone/db", nil)
-	if err != nil {
-		return err
+// dimension returns the dimension given by -dim, or nil for "all".
+func (f *flags) dimension() (world.Dimension, error) {
+	if *f.dimName == "all" {
+		return nil, nil
 	}
-
-	chunk, err := parse.GetChunk(db, world.ChunkPos{15, 2}, world.Overworld)
+	dimension, err := parse.DimensionByName(*f.dimName)
 	if err != nil {
-		return err
+		return nil, usageError{err}
 	}
-
-	for layer := 0; layer < chunk.MaxLayer; layer++ {
-		fmt.Printf("layer %d\n", layer)
-		hm := chunk.GetHeightMap(layer)
-		for z := 0; z < 16; z++ {
-			for x := 0; x < 16; x++ {
-				y := hm.Get(x, z)
-				if y == parse.NoHeight {
-					fmt.Printf("(%02d,%02d,--)\n", x, z)
-					continue
-				}
-				block, err := chunk.GetBlock(x, z, y, layer)
-				if err != nil {
-					return err
-				}
-				fmt.Printf("(%02d,%02d,%02d): %v\n", x, z, y, block)
-				// printBlockNBTTypes(block, 0)
-
-				if true {
-					continue
-				}
-				// h := hm[z][x]
-				// if h == parse.NoHeight {
-				// 	fmt.Printf(" none")
-				// } else {
-				// 	fmt.Printf(" %4d", hm[z][x])
-				// }
-				name, ok := block["name"].(string)
-				if !ok {
-					name = "unknown"
-				} else {
-					if strings.HasPrefix(name, "minecraft:") {
-						name = name[10:]
-					}
-				}
-				fmt.Printf(" %d:%-11s", y, name)
-			}
-			fmt.Printf("\n")
-		}
-	}
-	return nil
+	return dimension, nil
 }
 
-func run6() error {
-	bb := []byte{
-		10, 0, 0, 8, 4, 0, 110, 97, 109, 101, 17, 0, 109, 105, 110, 101,
-		99, 114, 97, 102, 116, 58, 98, 101, 100, 114, 111, 99, 107, 10, 6, 0, 115, 116, 97, 116,
-		101, 115, 1, 14, 0, 105, 110, 102, 105, 110, 105, 98, 117, 114, 110, 95, 98, 105, 116, 0,
-		0, 3, 7, 0, 118, 101, 114, 115, 105, 111, 110, 1, 10, 18, 1, 0, 10, 0, 0, 8,
-		4, 0, 110, 97, 109, 101, 20, 0, 109, 105, 110, 101, 99, 114, 97, 102, 116, 58, 110, 101,
-		116, 104, 101, 114, 114, 97, 99, 107, 10, 6, 0, 115, 116, 97, 116, 101, 115, 0, 3, 7,
-		0, 118, 101, 114, 115, 105, 111, 110, 1, 10, 18, 1, 0, 10, 0, 0, 8, 4, 0, 110,
-		97, 109, 101, 20, 0, 109, 105, 110, 101, 99, 114, 97, 102, 116, 58, 113, 117, 97, 114, 116,
-		122, 95, 111, 114, 101, 10, 6, 0, 115, 116, 97, 116, 101, 115, 0, 3, 7, 0, 118, 101,
-		114, 115, 105, 111, 110, 1, 10, 18, 1, 0, 10, 0, 0, 8, 4, 0, 110, 97, 109, 101,
-		24, 0, 109, 105, 110, 101, 99, 114, 97, 102, 116, 58, 97, 110, 99, 105, 101, 110, 116, 95,
-		100, 101, 98, 114, 105, 115, 10, 6, 0, 115, 116, 97, 116, 101, 115, 0, 3, 7, 0, 118,
-		101, 114, 115, 105, 111, 110, 1, 10, 18, 1, 0, 10, 0, 0, 8, 4, 0, 110, 97, 109,
-		101, 25, 0, 109, 105, 110, 101, 99, 114, 97, 102, 116, 58, 110, 101, 116, 104, 101, 114, 95,
-		103, 111, 108, 100, 95, 111, 114, 101, 10, 6, 0, 115, 116, 97, 116, 101, 115, 0, 3, 7,
-		0, 118, 101, 114, 115, 105, 111, 110, 1, 10, 18, 1, 0, 10, 0, 0, 8, 4, 0, 110,
-		97, 109, 101, 16, 0, 109, 105, 110, 101, 99, 114, 97, 102, 116, 58, 103, 114, 97, 118, 101,
-		108, 10, 6, 0, 115, 116, 97, 116, 101, 115, 0, 3, 7, 0, 118, 101, 114, 115, 105, 111,
-		110, 1, 10, 18, 1, 0, 10, 0, 0, 8, 4, 0, 110, 97, 109, 101, 20, 0, 109, 105,
-		110, 101, 99, 114, 97, 102, 116, 58, 98, 108, 97, 99, 107, 115, 116, 111, 110, 101, 10, 6,
-		0, 115, 116, 97, 116, 101, 115, 0, 3, 7, 0, 118, 101, 114, 115, 105, 111, 110, 1, 10,
-		18, 1, 0,
+// findWorld returns the folder or .mcworld file of the world given by
+// -world. Without -world, it's the only world in the default roots, if
+// there's exactly one.
+func (f *flags) findWorld() (string, error) {
+	if *f.worldName != "" {
+		return save.Find(*f.worldName)
 	}
-
-	buf := bytes.NewBuffer(bb)
-	d := nbt.NewDecoderWithEncoding(buf, nbt.LittleEndian)
-	for i := 0; i < 7; i++ {
-		fmt.Printf("decoding nbt %d starting at %v\n", i, []byte(buf.String()[:10]))
-		var m map[string]any
-		err := d.Decode(&m)
+	var found []string
+	for _, root := range save.DefaultRoots() {
+		worlds, err := save.Discover(root)
 		if err != nil {
-			return err
+			continue
+		}
+		for _, w := range worlds {
+			found = append(found, w.Dir)
 		}
 	}
-	return nil
+	switch len(found) {
+	case 0:
+		return "", usageErrorf("-world is required")
+	case 1:
+		return found[0], nil
+	}
+	return "", usageErrorf("-world is required: found %d worlds (see info -list)", len(found))
 }
 
-func runScanEntireWorld() error {
-	db, err := leveldb.OpenFile("./worlds/survivalone/db", nil)
+// openWorld opens the world given by -world.
+func (f *flags) openWorld(readOnly bool) (*save.World, error) {
+	worldPath, err := f.findWorld()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer db.Close()
-
-	occupiedChunks := parse.GetOccupiedChunkCoordinates(db)
-
-	for dim, chunkMap := range occupiedChunks {
-		fmt.Printf("%v: %d occupied chunks\n", dim, len(chunkMap))
-		i := 0
-		for pos := range chunkMap {
-			i++
-			if i%10000 == 0 {
-				fmt.Printf(" %d\n", i)
-			}
-			chunk, err := parse.GetChunk(db, pos, dim)
-			if err != nil {
-				return err
-			}
-			_ = chunk
-		}
-	}
-
-	return nil
+	return save.Open(worldPath, readOnly)
 }
 
-func runFetch() error {
-	// clientURL, err := resources.GetLatestReleaseClientURL(context.Background())
-	// if err != nil {
-	// 	return err
-	// }
-	// fmt.Printf("Client URL: %s\n", clientURL)
-
-	zip, err := resources.LatestJavaReleaseClientZip(context.Background())
+// worldDir returns the folder of the world given by -world, for
+// subcommands that change the world in place.
+func (f *flags) worldDir() (string, error) {
+	dir, err := f.findWorld()
 	if err != nil {
-		return err
+		return "", err
 	}
-
-	file, err := zip.Open("assets/minecraft")
-	if err != nil {
-		return err
+	if save.IsMCWorld(dir) {
+		return "", fmt.Errorf("%s is a .mcworld file; %s needs a world folder (see import)", dir, f.Name())
 	}
-
-	fmt.Printf("%T\n", file)
-
-	return nil
+	return dir, nil
 }
 
-func runFetchBedrock() error {
-	// zip, err := resources.LatestBedrockReleaseClientZip(context.Background())
-	zip, err := resources.LatestCachedZip("bedrock-", "apk")
-	if err != nil {
-		return err
-	}
-
-	file, err := zip.Open("assets/assets")
-	if err != nil {
-		return err
+// out returns where human-readable output goes: stdout, unless -json
+// is set, in which case stdout is kept for the JSON.
+func (f *flags) out() io.Writer {
+	if *f.json {
+		return os.Stderr
 	}
+	return os.Stdout
+}
 
-	fmt.Printf("%T\n", file)
+// writeJSON writes v to stdout as indented JSON.
+func writeJSON(v any) error {
+	return writeJSONTo(os.Stdout, v)
+}
 
-	return nil
+// writeJSONTo writes v to w as indented JSON.
+func writeJSONTo(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
 }
 
-func runGetGrass() error {
-	ts, err := resources.NewTextureSource(context.Background(), resources.UseOnlyCached)
-	if err != nil {
-		return err
-	}
-	img, err := ts.Get(map[string]any{
-		"name": "minecraft:grass",
-	})
-	if err != nil {
-		return err
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: bedrockprune <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s  %s\n", c.name, c.summary)
 	}
-	_ = img
-	fmt.Printf("got grass image!\n")
-	return nil
+	fmt.Fprintf(os.Stderr, "\nRun bedrockprune <command> -h for the flags of each command.\n")
 }
 
 func main() {
-	// err := runGetGrass()
-	// err := runShowZeroZero()
-	// err := runFetchBedrock()
-	err := runShowZeroZero()
-	// if err := runLoadImage()
-	if err != nil {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		err := c.run(os.Args[2:])
+		if err == nil {
+			return
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.As(err, &usageError{}) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
+	usage()
+	os.Exit(2)
 }
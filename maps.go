@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// mapEntry is one map in the output of the maps command.
+type mapEntry struct {
+	parse.MapItem
+	MinX    int    `json:"min_x"`
+	MinZ    int    `json:"min_z"`
+	MaxX    int    `json:"max_x"`
+	MaxZ    int    `json:"max_z"`
+	File    string `json:"file,omitempty"`
+	Dropped *bool  `json:"dropped,omitempty"` // With -keep: whether prune -drop-maps would drop it
+}
+
+func runMaps(args []string) error {
+	f := newFlags("maps")
+	f.addDim("overworld", false)
+	pngDir := f.String("png", "", "write each map as map_<id>.png into this folder")
+	var keep parse.ChunkSelection
+	f.Var(&keep, "keep", "check which maps prune -drop-maps would drop if keeping these chunks of -dim, as x1,z1,x2,z2 in chunk coordinates (repeatable)")
+	f.Parse(args)
+
+	keepFunc, err := f.keepFunc(keep)
+	if err != nil {
+		return err
+	}
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	maps, errs := parse.ReadMaps(w.DB)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if *pngDir != "" {
+		if err := os.MkdirAll(*pngDir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	entries := []mapEntry{}
+	for _, m := range maps {
+		bounds := m.Bounds()
+		e := mapEntry{MapItem: m, MinX: bounds.Min.X, MinZ: bounds.Min.Y, MaxX: bounds.Max.X - 1, MaxZ: bounds.Max.Y - 1}
+		if keepFunc != nil {
+			dropped := !keepFunc(m.WorldDimension(), m.CenterChunk())
+			e.Dropped = &dropped
+		}
+		if *pngDir != "" {
+			e.File = filepath.Join(*pngDir, fmt.Sprintf("map_%d.png", m.ID))
+			if err := writePNG(e.File, m); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	if *f.json {
+		return writeJSON(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No maps in %q\n", w.Name)
+	}
+	for _, e := range entries {
+		fmt.Printf("map %d: %s, scale %d, centre %d,%d, covers %d,%d to %d,%d", e.ID, e.Dimension, e.Scale, e.XCenter, e.ZCenter, e.MinX, e.MinZ, e.MaxX, e.MaxZ)
+		if e.Dropped != nil && *e.Dropped {
+			fmt.Printf(" (centre is pruned)")
+		}
+		if e.File != "" {
+			fmt.Printf(" -> %s", e.File)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func writePNG(filename string, m parse.MapItem) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, m.Image()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,109 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+const (
+	digpPrefix        = "digp"
+	actorprefixPrefix = "actorprefix"
+	villagePrefix     = "VILLAGE_"
+
+	// actorIDSize is the size of the actor IDs listed in digp values,
+	// and appended to "actorprefix" to make actor keys.
+	actorIDSize = 8
+)
+
+// MakeDigpKey makes the key of the actor digest of a chunk: the list
+// of the actors stored in it.
+func MakeDigpKey(chunkPos world.ChunkPos, dimension world.Dimension) []byte {
+	return append([]byte(digpPrefix), MakeChunkPrefix(chunkPos, dimension)...)
+}
+
+// ParseDigpKey returns the chunk position and dimension of a digp key.
+func ParseDigpKey(key []byte) (world.ChunkPos, world.Dimension, error) {
+	rest, ok := bytes.CutPrefix(key, []byte(digpPrefix))
+	if !ok || (len(rest) != 8 && len(rest) != 12) {
+		return world.ChunkPos{}, nil, fmt.Errorf("not a digp key: %q", key)
+	}
+	return ParseSaneChunkPrefix(rest)
+}
+
+// ParseDigp splits a digp value into the IDs of the actors it lists.
+func ParseDigp(val []byte) ([][]byte, error) {
+	if len(val)%actorIDSize != 0 {
+		return nil, fmt.Errorf("digp value length %d is not a multiple of %d", len(val), actorIDSize)
+	}
+	var res [][]byte
+	for i := 0; i < len(val); i += actorIDSize {
+		res = append(res, val[i:i+actorIDSize:i+actorIDSize])
+	}
+	return res, nil
+}
+
+// MakeActorKey makes the key an actor with the given ID is stored
+// under.
+func MakeActorKey(id []byte) []byte {
+	return append([]byte(actorprefixPrefix), id...)
+}
+
+// ActorID returns the actor ID from an actorprefix key.
+func ActorID(key []byte) ([]byte, bool) {
+	id, ok := bytes.CutPrefix(key, []byte(actorprefixPrefix))
+	return id, ok && len(id) == actorIDSize
+}
+
+// VillageKey is a parsed village record key, like
+// "VILLAGE_Overworld_<uuid>_INFO".
+type VillageKey struct {
+	Dimension world.Dimension
+	ID        string // The village's UUID
+	Record    string // DWELLERS, INFO, PLAYERS, or POI
+}
+
+// ParseVillageKey parses a village record key. Keys without a
+// dimension, from older versions, are in the overworld.
+func ParseVillageKey(key []byte) (VillageKey, error) {
+	rest, ok := strings.CutPrefix(string(key), villagePrefix)
+	if !ok {
+		return VillageKey{}, fmt.Errorf("not a village key: %q", key)
+	}
+	parts := strings.Split(rest, "_")
+	var res VillageKey
+	switch len(parts) {
+	case 2:
+		res = VillageKey{Dimension: world.Overworld, ID: parts[0], Record: parts[1]}
+	case 3:
+		dimension, err := DimensionByName(parts[0])
+		if err != nil {
+			return VillageKey{}, fmt.Errorf("bad village key %q: %w", key, err)
+		}
+		res = VillageKey{Dimension: dimension, ID: parts[1], Record: parts[2]}
+	default:
+		return VillageKey{}, fmt.Errorf("bad village key %q", key)
+	}
+	return res, nil
+}
+
+// VillageBounds returns the bounds stored in a village's INFO record.
+func VillageBounds(val []byte) (Box, error) {
+	var info map[string]any
+	if err := nbt.UnmarshalEncoding(val, &info, nbt.LittleEndian); err != nil {
+		return Box{}, fmt.Errorf("unable to decode village info: %w", err)
+	}
+	var coords [6]int
+	for i, name := range []string{"X0", "Y0", "Z0", "X1", "Y1", "Z1"} {
+		c, ok := info[name].(int32)
+		if !ok {
+			return Box{}, fmt.Errorf("village info has no int %s field", name)
+		}
+		coords[i] = int(c)
+	}
+	return NewBox(cube.Pos{coords[0], coords[1], coords[2]}, cube.Pos{coords[3], coords[4], coords[5]}), nil
+}
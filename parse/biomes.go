@@ -0,0 +1,148 @@
+package parse
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	_ "github.com/df-mc/dragonfly/server/world/biome" // Registers biomes, for BiomeByID
+)
+
+// BiomeCount is how many blocks of a chunk are in one biome.
+type BiomeCount struct {
+	ID    int32  `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// biomeSections splits the biomes of a Data3D record into one raw
+// paletted storage per 16-block section, bottom first. A section that
+// says to repeat the previous one is returned as a copy of it.
+func biomeSections(val []byte) ([][]byte, error) {
+	if len(val) < storedHeightMapSize {
+		return nil, fmt.Errorf("want at least %d bytes for heightmap; got %d", storedHeightMapSize, len(val))
+	}
+	var res [][]byte
+	rest := val[storedHeightMapSize:]
+	for len(rest) > 0 {
+		bitsPerBlock := int(rest[0]) >> 1
+		if bitsPerBlock == 0x7f {
+			if len(res) == 0 {
+				return nil, fmt.Errorf("first biome section refers to a previous one")
+			}
+			res = append(res, res[len(res)-1])
+			rest = rest[1:]
+			continue
+		}
+		wordCount, ok := wordCountForBitsPerBlock[bitsPerBlock]
+		if !ok {
+			return nil, fmt.Errorf("unimplemented bits-per-block %d in biome section %d", bitsPerBlock, len(res))
+		}
+		size := 1 + wordCount*4
+		paletteCount := 1
+		if bitsPerBlock > 0 {
+			if len(rest) < size+4 {
+				return nil, fmt.Errorf("ran out of bytes for biome section %d", len(res))
+			}
+			paletteCount = int(binary.LittleEndian.Uint32(rest[size:]))
+			size += 4
+		}
+		size += paletteCount * 4
+		if len(rest) < size {
+			return nil, fmt.Errorf("ran out of bytes for biome section %d", len(res))
+		}
+		res = append(res, rest[:size:size])
+		rest = rest[size:]
+	}
+	return res, nil
+}
+
+// ParseBiomes decodes the biomes of a Data3D record: after the
+// heightmap, one paletted storage of biome IDs per 16-block section,
+// bottom first. A storage may instead say to repeat the previous one.
+// It returns the palette and per-block indices of each section.
+func ParseBiomes(val []byte) ([][]int32, []*subChunkIndices, error) {
+	sections, err := biomeSections(val)
+	if err != nil {
+		return nil, nil, err
+	}
+	var palettes [][]int32
+	var indices []*subChunkIndices
+	for i, section := range sections {
+		bitsPerBlock := int(section[0]) >> 1
+		buf := bytes.NewBuffer(section[1:])
+		wordBytes := buf.Next(wordCountForBitsPerBlock[bitsPerBlock] * 4)
+		paletteCount := 1
+		if bitsPerBlock > 0 {
+			if paletteCount, err = readUint32AsInt(buf); err != nil {
+				return nil, nil, fmt.Errorf("unable to read palette size of biome section %d: %w", i, err)
+			}
+		}
+		palette := make([]int32, paletteCount)
+		if err := binary.Read(buf, binary.LittleEndian, palette); err != nil {
+			return nil, nil, fmt.Errorf("unable to read palette of biome section %d: %w", i, err)
+		}
+		entries := readBlockEntries(wordBytes, bitsPerBlock)
+		for _, index := range entries {
+			if index >= paletteCount {
+				return nil, nil, fmt.Errorf("biome section %d has palette index %d, which is >= %d", i, index, paletteCount)
+			}
+		}
+		palettes = append(palettes, palette)
+		indices = append(indices, entries)
+	}
+	return palettes, indices, nil
+}
+
+// RebaseData3D returns a Data3D record from one dimension rewritten
+// for another with a different height range: the heightmap is kept
+// as it is, and there's one biome section for each section of the new
+// dimension, taken from the section at the same height, or the
+// nearest one.
+func RebaseData3D(val []byte, from, to world.Dimension) ([]byte, error) {
+	sections, err := biomeSections(val)
+	if err != nil {
+		return nil, err
+	}
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("Data3D record has no biome sections")
+	}
+	fromMin := from.Range().Min() >> 4
+	toMin, toMax := to.Range().Min()>>4, to.Range().Max()>>4
+	res := slices.Clone(val[:storedHeightMapSize])
+	for yIndex := toMin; yIndex <= toMax; yIndex++ {
+		i := min(max(yIndex-fromMin, 0), len(sections)-1)
+		res = append(res, sections[i]...)
+	}
+	return res, nil
+}
+
+// BiomeSummary counts the blocks of each biome in a Data3D record,
+// most common first.
+func BiomeSummary(val []byte) ([]BiomeCount, error) {
+	palettes, indices, err := ParseBiomes(val)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int32]int)
+	for i, palette := range palettes {
+		for _, index := range indices[i] {
+			counts[palette[index]]++
+		}
+	}
+	var res []BiomeCount
+	for id, count := range counts {
+		bc := BiomeCount{ID: id, Count: count}
+		if b, ok := world.BiomeByID(int(id)); ok {
+			bc.Name = b.String()
+		}
+		res = append(res, bc)
+	}
+	slices.SortFunc(res, func(a, b BiomeCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.ID, b.ID))
+	})
+	return res, nil
+}
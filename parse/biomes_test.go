@@ -0,0 +1,37 @@
+package parse
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestBiomeSummary(t *testing.T) {
+	val := make([]byte, storedHeightMapSize)
+	// Section 0: all plains (1), with 0 bits per block.
+	val = append(val, 0<<1|1)
+	val = binary.LittleEndian.AppendUint32(val, 1)
+	// Section 1: same as section 0.
+	val = append(val, 0x7f<<1|1)
+	// Section 2: 1 bit per block, the first 32 blocks desert (2), the
+	// rest ocean (0).
+	val = append(val, 1<<1|1)
+	val = binary.LittleEndian.AppendUint32(val, 0xFFFFFFFF)
+	val = append(val, make([]byte, 127*4)...)
+	val = binary.LittleEndian.AppendUint32(val, 2)
+	val = binary.LittleEndian.AppendUint32(val, 0)
+	val = binary.LittleEndian.AppendUint32(val, 2)
+
+	got, err := BiomeSummary(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BiomeCount{
+		{ID: 1, Name: "plains", Count: 8192},
+		{ID: 0, Name: "ocean", Count: 4064},
+		{ID: 2, Name: "desert", Count: 32},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
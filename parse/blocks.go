@@ -1,8 +1,11 @@
 package parse
 
 import (
+	"cmp"
+	"encoding/binary"
 	"fmt"
 	"math"
+	"slices"
 	"sort"
 
 	"github.com/df-mc/dragonfly/server/world"
@@ -112,24 +115,111 @@ func (ch *Chunk) GetHeightMap(layer int) *HeightMap {
 		return ch.HeightMaps[layer]
 	}
 
+	hm := ComputeHeightMap(ch.SubChunks, layer)
+
+	for len(ch.HeightMaps) <= layer {
+		ch.HeightMaps = append(ch.HeightMaps, nil)
+	}
+
+	ch.HeightMaps[layer] = hm
+	return hm
+}
+
+// ComputeHeightMap returns the y coordinate of the highest non-air
+// block in each column of the given layer of the sub-chunks, or
+// NoHeight for columns with no blocks at all.
+func ComputeHeightMap(subChunks []SubChunk, layer int) *HeightMap {
+	sorted := slices.Clone(subChunks)
+	slices.SortFunc(sorted, func(a, b SubChunk) int {
+		return cmp.Compare(b.yIndex, a.yIndex)
+	})
+
 	var hm HeightMap
 	for z := range 16 {
 		for x := range 16 {
 			hm[z][x] = NoHeight
-			for y := ch.MaxY(); y >= ch.MinY(); y-- {
-				b, err := ch.GetBlock(x, z, y, layer)
-				if err == nil && b != nil && b["name"] != "minecraft:air" {
-					hm[z][x] = y
-					break
+		}
+	}
+	remaining := 256
+	for _, sc := range sorted {
+		if remaining == 0 {
+			break
+		}
+		if len(sc.layers) <= layer {
+			continue
+		}
+		l := sc.layers[layer]
+		isAir := make([]bool, len(l.palettes))
+		allAir := true
+		for i, block := range l.palettes {
+			isAir[i] = block["name"] == "minecraft:air"
+			allAir = allAir && isAir[i]
+		}
+		if allAir {
+			continue
+		}
+		for z := range 16 {
+			for x := range 16 {
+				if hm[z][x] != NoHeight {
+					continue
+				}
+				for y := 15; y >= 0; y-- {
+					if !isAir[l.blockEntries.get(x, z, y)] {
+						hm[z][x] = sc.yIndex<<4 + int32(y)
+						remaining--
+						break
+					}
 				}
 			}
 		}
 	}
+	return &hm
+}
 
-	for len(ch.HeightMaps) <= layer {
-		ch.HeightMaps = append(ch.HeightMaps, nil)
+// storedHeightMapSize is the size of the heightmap at the start of
+// Data3D and Data2D records: 256 little-endian int16 values.
+const storedHeightMapSize = 512
+
+// StoredHeightMap decodes the heightmap at the start of a Data3D or
+// Data2D record. Bedrock stores, for each column, the height above the
+// bottom of the dimension of the first air block above the highest
+// block, which this converts back to the y coordinate of that highest
+// block (or NoHeight).
+func StoredHeightMap(val []byte, dimension world.Dimension) (*HeightMap, error) {
+	if len(val) < storedHeightMapSize {
+		return nil, fmt.Errorf("want at least %d bytes for heightmap; got %d", storedHeightMapSize, len(val))
 	}
+	minY := int32(dimension.Range().Min())
+	var hm HeightMap
+	for x := range 16 {
+		for z := range 16 {
+			stored := int32(int16(binary.LittleEndian.Uint16(val[(x<<4+z)*2:])))
+			if stored <= 0 {
+				hm[z][x] = NoHeight
+			} else {
+				hm[z][x] = stored - 1 + minY
+			}
+		}
+	}
+	return &hm, nil
+}
 
-	ch.HeightMaps[layer] = &hm
-	return &hm
+// SetStoredHeightMap returns a copy of a Data3D or Data2D record with
+// its heightmap replaced by hm. It is the inverse of StoredHeightMap.
+func SetStoredHeightMap(val []byte, hm *HeightMap, dimension world.Dimension) ([]byte, error) {
+	if len(val) < storedHeightMapSize {
+		return nil, fmt.Errorf("want at least %d bytes for heightmap; got %d", storedHeightMapSize, len(val))
+	}
+	minY := int32(dimension.Range().Min())
+	res := slices.Clone(val)
+	for x := range 16 {
+		for z := range 16 {
+			stored := int32(0)
+			if y := hm[z][x]; y != NoHeight {
+				stored = y + 1 - minY
+			}
+			binary.LittleEndian.PutUint16(res[(x<<4+z)*2:], uint16(int16(stored)))
+		}
+	}
+	return res, nil
 }
@@ -0,0 +1,186 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+// Box is an axis-aligned box of block positions. Both Min and Max are
+// inclusive.
+type Box struct {
+	Min cube.Pos
+	Max cube.Pos
+}
+
+// NewBox returns the Box spanning the two given corners, in any order.
+func NewBox(a, b cube.Pos) Box {
+	return Box{
+		Min: cube.Pos{min(a[0], b[0]), min(a[1], b[1]), min(a[2], b[2])},
+		Max: cube.Pos{max(a[0], b[0]), max(a[1], b[1]), max(a[2], b[2])},
+	}
+}
+
+// ParseBox parses a box given as six comma-separated block
+// coordinates: "x1,y1,z1,x2,y2,z2".
+func ParseBox(s string) (Box, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 6 {
+		return Box{}, fmt.Errorf("want box as x1,y1,z1,x2,y2,z2; got %q", s)
+	}
+	var coords [6]int
+	for i, part := range parts {
+		c, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return Box{}, fmt.Errorf("bad coordinate %q in box %q: %w", part, s, err)
+		}
+		coords[i] = c
+	}
+	return NewBox(cube.Pos{coords[0], coords[1], coords[2]}, cube.Pos{coords[3], coords[4], coords[5]}), nil
+}
+
+// DimensionBox returns the Box covering the whole height of the given
+// Dimension, out to SaneChunkLimit horizontally.
+func DimensionBox(dimension world.Dimension) Box {
+	r := dimension.Range()
+	limit := SaneChunkLimit*16 + 15
+	return Box{
+		Min: cube.Pos{-limit, r.Min(), -limit},
+		Max: cube.Pos{limit, r.Max(), limit},
+	}
+}
+
+// Contains reports whether pos is inside the box.
+func (b Box) Contains(pos cube.Pos) bool {
+	for i := range pos {
+		if pos[i] < b.Min[i] || pos[i] > b.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersect returns the intersection of two boxes, and false if they
+// don't overlap.
+func (b Box) Intersect(other Box) (Box, bool) {
+	var res Box
+	for i := range res.Min {
+		res.Min[i] = max(b.Min[i], other.Min[i])
+		res.Max[i] = min(b.Max[i], other.Max[i])
+		if res.Min[i] > res.Max[i] {
+			return Box{}, false
+		}
+	}
+	return res, true
+}
+
+// ContainsChunk reports whether any column of the given chunk is
+// inside the box.
+func (b Box) ContainsChunk(chunkPos world.ChunkPos) bool {
+	x, z := int(chunkPos.X())<<4, int(chunkPos.Z())<<4
+	return x+15 >= b.Min[0] && x <= b.Max[0] && z+15 >= b.Min[2] && z <= b.Max[2]
+}
+
+// ContainsSubChunk reports whether any block of the sub-chunk with the
+// given y index in the given chunk is inside the box.
+func (b Box) ContainsSubChunk(chunkPos world.ChunkPos, yIndex int32) bool {
+	y := int(yIndex) << 4
+	return b.ContainsChunk(chunkPos) && y+15 >= b.Min[1] && y <= b.Max[1]
+}
+
+// Chunks returns the positions of all chunks that overlap the box.
+func (b Box) Chunks() []world.ChunkPos {
+	var res []world.ChunkPos
+	for x := b.Min[0] >> 4; x <= b.Max[0]>>4; x++ {
+		for z := b.Min[2] >> 4; z <= b.Max[2]>>4; z++ {
+			res = append(res, world.ChunkPos{int32(x), int32(z)})
+		}
+	}
+	return res
+}
+
+// String returns the box in the format ParseBox accepts.
+func (b Box) String() string {
+	return fmt.Sprintf("%d,%d,%d,%d,%d,%d", b.Min[0], b.Min[1], b.Min[2], b.Max[0], b.Max[1], b.Max[2])
+}
+
+// ChunkArea is a rectangle of chunk positions. Both Min and Max are
+// inclusive.
+type ChunkArea struct {
+	Min world.ChunkPos
+	Max world.ChunkPos
+}
+
+// ParseChunkArea parses a chunk area given as four comma-separated
+// chunk coordinates: "x1,z1,x2,z2".
+func ParseChunkArea(s string) (ChunkArea, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return ChunkArea{}, fmt.Errorf("want chunk area as x1,z1,x2,z2; got %q", s)
+	}
+	var coords [4]int32
+	for i, part := range parts {
+		c, err := strconv.ParseInt(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return ChunkArea{}, fmt.Errorf("bad coordinate %q in chunk area %q: %w", part, s, err)
+		}
+		coords[i] = int32(c)
+	}
+	return ChunkArea{
+		Min: world.ChunkPos{min(coords[0], coords[2]), min(coords[1], coords[3])},
+		Max: world.ChunkPos{max(coords[0], coords[2]), max(coords[1], coords[3])},
+	}, nil
+}
+
+// Contains reports whether the chunk position is inside the area.
+func (a ChunkArea) Contains(pos world.ChunkPos) bool {
+	return pos.X() >= a.Min.X() && pos.X() <= a.Max.X() && pos.Z() >= a.Min.Z() && pos.Z() <= a.Max.Z()
+}
+
+// String returns the area in the format ParseChunkArea accepts.
+func (a ChunkArea) String() string {
+	return fmt.Sprintf("%d,%d,%d,%d", a.Min.X(), a.Min.Z(), a.Max.X(), a.Max.Z())
+}
+
+// ChunkSelection is a union of chunk areas. An empty selection
+// selects every chunk.
+type ChunkSelection []ChunkArea
+
+// Contains reports whether the chunk position is selected.
+func (s ChunkSelection) Contains(pos world.ChunkPos) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, a := range s {
+		if a.Contains(pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the selection as a list of areas, or "all chunks".
+func (s ChunkSelection) String() string {
+	if len(s) == 0 {
+		return "all chunks"
+	}
+	var areas []string
+	for _, a := range s {
+		areas = append(areas, a.String())
+	}
+	return "chunks " + strings.Join(areas, " ")
+}
+
+// Set parses and adds a chunk area, so a ChunkSelection can be used
+// as a repeatable flag.
+func (s *ChunkSelection) Set(value string) error {
+	a, err := ParseChunkArea(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, a)
+	return nil
+}
@@ -0,0 +1,323 @@
+package parse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+)
+
+// Severity says how bad a Problem is.
+type Severity string
+
+const (
+	// SeverityError is for data the game is likely to reject or
+	// misread.
+	SeverityError Severity = "error"
+	// SeverityWarning is for data that looks odd, or that we can't
+	// check, but that isn't necessarily wrong.
+	SeverityWarning Severity = "warning"
+)
+
+// Problem is one thing CheckWorld found wrong with a world.
+type Problem struct {
+	Severity Severity     `json:"severity"`
+	Check    string       `json:"check"` // Which check found it, like "palette" or "digest"
+	Key      string       `json:"key"`   // Quoted Go string
+	Chunk    *ProblemSite `json:"chunk,omitempty"`
+	Message  string       `json:"message"`
+}
+
+// ProblemSite is the chunk a Problem is in.
+type ProblemSite struct {
+	Dimension string `json:"dimension"`
+	X         int32  `json:"x"`
+	Z         int32  `json:"z"`
+}
+
+// CheckReport is the result of CheckWorld.
+type CheckReport struct {
+	Keys     int       `json:"keys"`
+	Chunks   int       `json:"chunks"`
+	Problems []Problem `json:"problems"`
+}
+
+// Count returns the number of problems with the given severity.
+func (r *CheckReport) Count(severity Severity) int {
+	n := 0
+	for _, p := range r.Problems {
+		if p.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// chunkID identifies a chunk in any dimension.
+type chunkID struct {
+	dimension world.Dimension
+	pos       world.ChunkPos
+}
+
+// chunkCheck is what the checker remembers about each chunk.
+type chunkCheck struct {
+	versionKey    []byte // The first key seen, to report a missing Version against
+	version       bool
+	legacyVersion bool
+}
+
+// digestRef is one actor listed in a digest.
+type digestRef struct {
+	key   []byte
+	id    string
+	chunk chunkID
+}
+
+// worldChecker accumulates a CheckReport.
+type worldChecker struct {
+	db      *leveldb.DB
+	report  *CheckReport
+	chunks  map[chunkID]*chunkCheck
+	order   []chunkID // Chunks in the order they were first seen
+	actors  map[string]bool
+	digests []digestRef
+}
+
+func (wc *worldChecker) add(severity Severity, check string, key []byte, c *chunkID, format string, args ...any) {
+	p := Problem{
+		Severity: severity,
+		Check:    check,
+		Key:      strconv.Quote(string(key)),
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if c != nil {
+		p.Chunk = &ProblemSite{Dimension: fmt.Sprint(c.dimension), X: c.pos.X(), Z: c.pos.Z()}
+	}
+	wc.report.Problems = append(wc.report.Problems, p)
+}
+
+// CheckWorld walks every key of a world's leveldb and checks that:
+//   - chunk keys are the right length for their record type, and their
+//     coordinates are within SaneChunkLimit;
+//   - each sub-chunk's key index matches the yIndex in its value, lies
+//     within the height limits of its dimension, and its blocks' palette
+//     indices are in range;
+//   - NBT values, including block palettes, decode;
+//   - every chunk has a Version record, and its CheckSums record, if it
+//     has one, matches its other records;
+//   - actor digests only list actors that exist.
+//
+// It only returns an error if the leveldb can't be read.
+func CheckWorld(db *leveldb.DB) (*CheckReport, error) {
+	wc := &worldChecker{
+		db:     db,
+		report: &CheckReport{Problems: []Problem{}},
+		chunks: make(map[chunkID]*chunkCheck),
+		actors: make(map[string]bool),
+	}
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		wc.report.Keys++
+		wc.checkKey(NewKeyVal(iter.Key(), iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range wc.order {
+		cc := wc.chunks[id]
+		switch {
+		case cc.version:
+		case cc.legacyVersion:
+			wc.add(SeverityWarning, "version", cc.versionKey, &id, "chunk has only a LegacyVersion record, from an old version of the game")
+		default:
+			wc.add(SeverityError, "version", cc.versionKey, &id, "chunk has no Version record")
+		}
+	}
+	for _, ref := range wc.digests {
+		if !wc.actors[ref.id] {
+			wc.add(SeverityError, "digest", ref.key, &ref.chunk, "digest lists actor %d, which doesn't exist", int64(binary.LittleEndian.Uint64([]byte(ref.id))))
+		}
+	}
+	wc.report.Chunks = len(wc.chunks)
+	return wc.report, nil
+}
+
+func (wc *worldChecker) checkKey(kv *KeyVal) {
+	info := kv.KeyTypeAndChunkLocation()
+	kt := info.KeyType
+
+	switch {
+	case kt == KeyTypeUnknown:
+		wc.checkUnknownKey(kv)
+		return
+
+	case kt == KeyTypeActorprefix:
+		if id, ok := ActorID(kv.Key); ok {
+			wc.actors[string(id)] = true
+		} else {
+			wc.add(SeverityWarning, "key", kv.Key, nil, "actor key has a %d-byte ID; want %d", len(kv.Key)-len(actorprefixPrefix), actorIDSize)
+		}
+
+	case kt == KeyTypeDigp:
+		wc.checkDigest(kv)
+		return
+
+	case kt.IsChunkData():
+		wc.checkChunkRecord(kv, info)
+		return
+	}
+
+	wc.checkNBT(kv, nil)
+}
+
+// checkUnknownKey looks for chunk records whose coordinates are out of
+// range, which KeyTypeAndChunkLocation doesn't recognize.
+func (wc *worldChecker) checkUnknownKey(kv *KeyVal) {
+	kl := len(kv.Key)
+	if kl != 9 && kl != 10 && kl != 13 && kl != 14 {
+		return
+	}
+	chunkPos, dimension, err := ParseChunkPrefix(kv.Key)
+	if err != nil {
+		return
+	}
+	if LevelChunkTag(kv.Key[kl&^3]).KeyTypeForDimension(dimension) == KeyTypeUnknown {
+		return
+	}
+	if _, _, err := ParseSaneChunkPrefix(kv.Key); err != nil {
+		wc.add(SeverityError, "coordinates", kv.Key, &chunkID{dimension, chunkPos}, "chunk record coordinates are out of range: %v", err)
+	}
+}
+
+func (wc *worldChecker) chunk(key []byte, id chunkID) *chunkCheck {
+	cc := wc.chunks[id]
+	if cc == nil {
+		cc = &chunkCheck{versionKey: slices.Clone(key)}
+		wc.chunks[id] = cc
+		wc.order = append(wc.order, id)
+	}
+	return cc
+}
+
+func (wc *worldChecker) checkChunkRecord(kv *KeyVal, info KeyInfo) {
+	id := chunkID{info.Dimension, info.ChunkPos}
+	cc := wc.chunk(kv.Key, id)
+	tag := info.KeyType.LevelChunkTag()
+
+	// Sub-chunk keys end with the sub-chunk's index; no other chunk
+	// records have anything after the tag.
+	if wantIndex, hasIndex := tag == LevelChunkTagSubChunkPrefix, len(kv.Key)%4 == 2; wantIndex != hasIndex {
+		wc.add(SeverityError, "key", kv.Key, &id, "%s key is %d bytes long", tag, len(kv.Key))
+		return
+	}
+
+	switch tag {
+	case LevelChunkTagVersion:
+		cc.version = true
+	case LevelChunkTagLegacyVersion:
+		cc.legacyVersion = true
+	case LevelChunkTagSubChunkPrefix:
+		wc.checkSubChunk(kv, id)
+		return
+	case LevelChunkTagCheckSums:
+		wc.checkCheckSums(kv, id)
+		return
+	}
+	wc.checkNBT(kv, &id)
+}
+
+func (wc *worldChecker) checkSubChunk(kv *KeyVal, id chunkID) {
+	index := int32(int8(kv.Key[len(kv.Key)-1]))
+	r := id.dimension.Range()
+	if index < int32(r.Min()>>4) || index > int32(r.Max()>>4) {
+		wc.add(SeverityError, "subchunk-range", kv.Key, &id, "sub-chunk %d is outside the height limits of %v (y=%d to %d)", index, id.dimension, r.Min(), r.Max())
+	}
+
+	if len(kv.Val) < 3 {
+		wc.add(SeverityError, "subchunk", kv.Key, &id, "sub-chunk value is only %d bytes long", len(kv.Val))
+		return
+	}
+	if version := kv.Val[0]; version != 9 {
+		wc.add(SeverityWarning, "subchunk", kv.Key, &id, "can't check sub-chunk version %d", version)
+		return
+	}
+	if yIndex := int32(int8(kv.Val[2])); yIndex != index {
+		wc.add(SeverityError, "subchunk-index", kv.Key, &id, "key has sub-chunk index %d, but the value has yIndex %d", index, yIndex)
+		return
+	}
+
+	_, err := ParseSubChunk(kv)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrPaletteIndex):
+		wc.add(SeverityError, "palette", kv.Key, &id, "%v", err)
+	case errors.Is(err, ErrPaletteEntry):
+		wc.add(SeverityError, "nbt", kv.Key, &id, "%v", err)
+	default:
+		wc.add(SeverityError, "subchunk", kv.Key, &id, "%v", err)
+	}
+}
+
+func (wc *worldChecker) checkCheckSums(kv *KeyVal, id chunkID) {
+	records, err := ChunkRecords(wc.db, id.pos, id.dimension)
+	if err != nil {
+		wc.add(SeverityError, "checksums", kv.Key, &id, "unable to read chunk records: %v", err)
+		return
+	}
+	mismatches, err := VerifyCheckSums(records, kv.Val)
+	if err != nil {
+		wc.add(SeverityError, "checksums", kv.Key, &id, "%v", err)
+		return
+	}
+	for _, m := range mismatches {
+		wc.add(SeverityError, "checksums", kv.Key, &id, "%s", m)
+	}
+}
+
+func (wc *worldChecker) checkDigest(kv *KeyVal) {
+	rest := kv.Key[len(digpPrefix):]
+	if len(rest) != 8 && len(rest) != 12 {
+		wc.add(SeverityError, "key", kv.Key, nil, "digest key is %d bytes long", len(kv.Key))
+		return
+	}
+	chunkPos, dimension, err := ParseChunkPrefix(rest)
+	if err != nil {
+		wc.add(SeverityError, "key", kv.Key, nil, "%v", err)
+		return
+	}
+	id := chunkID{dimension, chunkPos}
+	if _, _, err := ParseSaneChunkPrefix(rest); err != nil {
+		wc.add(SeverityError, "coordinates", kv.Key, &id, "digest coordinates are out of range: %v", err)
+	}
+	ids, err := ParseDigp(kv.Val)
+	if err != nil {
+		wc.add(SeverityError, "digest", kv.Key, &id, "%v", err)
+		return
+	}
+	for _, actorID := range ids {
+		wc.digests = append(wc.digests, digestRef{key: slices.Clone(kv.Key), id: string(actorID), chunk: id})
+	}
+}
+
+// checkNBT checks that NBT values decode.
+func (wc *worldChecker) checkNBT(kv *KeyVal, id *chunkID) {
+	kt := kv.KeyType()
+	if !kt.IsNBT() {
+		return
+	}
+	records, err := DecodeNBTRecords(kv.Val)
+	if err != nil {
+		wc.add(SeverityError, "nbt", kv.Key, id, "%v", err)
+		return
+	}
+	if !kt.IsNBTList() && len(records) != 1 {
+		wc.add(SeverityError, "nbt", kv.Key, id, "want one NBT compound for %s; got %d", kt, len(records))
+	}
+}
@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/storage"
+)
+
+func TestCheckWorld(t *testing.T) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sc := NewSubChunk(2)
+	sc.SetBlock(0, 0, 0, 0, map[string]any{"name": "minecraft:stone", "states": map[string]any{}, "version": int32(1)})
+	val, err := sc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, bad := world.ChunkPos{0, 0}, world.ChunkPos{1, 0}
+	put := func(key, val []byte) {
+		if err := db.Put(key, val, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(MakeChunkKey(good, world.Overworld, LevelChunkTagVersion), []byte{40})
+	put(MakeSubChunkKey(good, world.Overworld, 2), val)
+	put(MakeDigpKey(good, world.Overworld), nil)
+
+	// The bad chunk has no Version, a sub-chunk stored under the wrong
+	// index, one out of the nether's height limits, and a digest listing
+	// a missing actor.
+	put(MakeSubChunkKey(bad, world.Overworld, 3), val)
+	put(MakeSubChunkKey(bad, world.Nether, 2), val)
+	put(MakeChunkKey(bad, world.Nether, LevelChunkTagVersion), []byte{40})
+	netherVal := NewSubChunk(9)
+	if val, err = netherVal.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	put(MakeSubChunkKey(bad, world.Nether, 9), val)
+	put(MakeDigpKey(bad, world.Overworld), []byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	report, err := CheckWorld(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, p := range report.Problems {
+		if p.Severity != SeverityError {
+			t.Errorf("want only errors; got %+v", p)
+		}
+		if p.Chunk == nil || p.Chunk.X != 1 {
+			t.Errorf("want only problems in chunk 1,0; got %+v", p)
+		}
+		got[p.Check]++
+	}
+	want := map[string]int{"version": 1, "subchunk-index": 1, "subchunk-range": 1, "digest": 1}
+	if len(got) != len(want) {
+		t.Errorf("want problems %v; got %v", want, got)
+	}
+	for check, n := range want {
+		if got[check] != n {
+			t.Errorf("want %d %q problems; got %d (%+v)", n, check, got[check], report.Problems)
+		}
+	}
+	if report.Chunks != 3 {
+		t.Errorf("want 3 chunks; got %d", report.Chunks)
+	}
+}
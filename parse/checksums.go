@@ -0,0 +1,167 @@
+package parse
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+)
+
+// ChunkChecksum is one entry of a chunk's CheckSums record: the XXH64
+// hash of the value of one of the chunk's records.
+//
+// A CheckSums record is a uint32 count of entries, then for each a tag
+// byte, a sub-chunk index byte (0 for records other than sub-chunks),
+// and the hash as a uint64, all little-endian. Old versions of the game
+// wrote them, and may discard chunks whose checksums don't match.
+type ChunkChecksum struct {
+	Tag    LevelChunkTag
+	YIndex int8 // For sub-chunks
+	Hash   uint64
+}
+
+const checksumEntrySize = 1 + 1 + 8
+
+// checksummedTags are the chunk records the game writes checksums for.
+var checksummedTags = map[LevelChunkTag]bool{
+	LevelChunkTagData2D:         true,
+	LevelChunkTagSubChunkPrefix: true,
+	LevelChunkTagBlockEntity:    true,
+	LevelChunkTagEntity:         true,
+}
+
+// record returns the checksum without its hash, to identify the record
+// it covers.
+func (c ChunkChecksum) record() ChunkChecksum {
+	c.Hash = 0
+	return c
+}
+
+// String describes the record the checksum covers, like
+// "SubChunkPrefix 3".
+func (c ChunkChecksum) String() string {
+	tag := strings.TrimPrefix(c.Tag.String(), "LevelChunkTag")
+	if c.Tag == LevelChunkTagSubChunkPrefix {
+		return fmt.Sprintf("%s %d", tag, c.YIndex)
+	}
+	return tag
+}
+
+// ParseCheckSums decodes a CheckSums record.
+func ParseCheckSums(val []byte) ([]ChunkChecksum, error) {
+	if len(val) < 4 {
+		return nil, fmt.Errorf("checksums record is only %d bytes long", len(val))
+	}
+	count := int(binary.LittleEndian.Uint32(val))
+	if want := 4 + count*checksumEntrySize; len(val) != want {
+		return nil, fmt.Errorf("checksums record of %d entries should be %d bytes long; got %d", count, want, len(val))
+	}
+	res := make([]ChunkChecksum, count)
+	for i := range res {
+		entry := val[4+i*checksumEntrySize:]
+		res[i] = ChunkChecksum{
+			Tag:    LevelChunkTag(entry[0]),
+			YIndex: int8(entry[1]),
+			Hash:   binary.LittleEndian.Uint64(entry[2:]),
+		}
+	}
+	return res, nil
+}
+
+// EncodeCheckSums encodes a CheckSums record.
+func EncodeCheckSums(checksums []ChunkChecksum) []byte {
+	res := binary.LittleEndian.AppendUint32(nil, uint32(len(checksums)))
+	for _, c := range checksums {
+		res = append(res, byte(c.Tag), byte(c.YIndex))
+		res = binary.LittleEndian.AppendUint64(res, c.Hash)
+	}
+	return res
+}
+
+// checksumOf returns the checksum of a chunk record, and whether it's
+// of a type that checksums cover. Tags in extra are covered too.
+func checksumOf(kv *KeyVal, extra map[LevelChunkTag]bool) (ChunkChecksum, bool) {
+	tag := kv.KeyType().LevelChunkTag()
+	if !checksummedTags[tag] && !extra[tag] {
+		return ChunkChecksum{}, false
+	}
+	c := ChunkChecksum{Tag: tag, Hash: xxhash64(kv.Val)}
+	if tag == LevelChunkTagSubChunkPrefix {
+		c.YIndex = int8(kv.Key[len(kv.Key)-1])
+	}
+	return c, true
+}
+
+// ComputeCheckSums returns the checksums of a chunk's records, sorted
+// by tag and then sub-chunk index. Besides the records the game writes
+// checksums for, it covers any others with tags listed in old, the
+// chunk's current checksums.
+func ComputeCheckSums(records []*KeyVal, old []ChunkChecksum) []ChunkChecksum {
+	extra := make(map[LevelChunkTag]bool)
+	for _, c := range old {
+		extra[c.Tag] = true
+	}
+	res := []ChunkChecksum{}
+	for _, kv := range records {
+		if c, ok := checksumOf(kv, extra); ok {
+			res = append(res, c)
+		}
+	}
+	slices.SortFunc(res, func(a, b ChunkChecksum) int {
+		return cmp.Or(cmp.Compare(a.Tag, b.Tag), cmp.Compare(a.YIndex, b.YIndex))
+	})
+	return res
+}
+
+// VerifyCheckSums compares a chunk's CheckSums record, val, with the
+// chunk's records, and describes each checksum that doesn't match, and
+// each record that should have a checksum but doesn't.
+func VerifyCheckSums(records []*KeyVal, val []byte) ([]string, error) {
+	checksums, err := ParseCheckSums(val)
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[ChunkChecksum]uint64)
+	for _, c := range ComputeCheckSums(records, checksums) {
+		actual[c.record()] = c.Hash
+	}
+	var res []string
+	for _, c := range checksums {
+		hash, ok := actual[c.record()]
+		switch {
+		case !ok:
+			res = append(res, fmt.Sprintf("checksum of %s, which doesn't exist", c))
+		case hash != c.Hash:
+			res = append(res, fmt.Sprintf("checksum of %s doesn't match", c))
+		}
+		delete(actual, c.record())
+	}
+	for r := range actual {
+		res = append(res, fmt.Sprintf("no checksum of %s", r))
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// ChunkRecords returns the records stored under a chunk's key prefix,
+// in key order. Unlike AllEntriesWithChunkCoordinatePrefix, it leaves
+// out the records of other dimensions, whose prefixes start with an
+// overworld chunk's prefix.
+func ChunkRecords(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension) ([]*KeyVal, error) {
+	prefix := MakeChunkPrefix(chunkPos, dimension)
+	var res []*KeyVal
+	iter := db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if n := len(iter.Key()) - len(prefix); n != 1 && n != 2 {
+			continue
+		}
+		res = append(res, NewKeyVal(iter.Key(), iter.Value()))
+	}
+	return res, iter.Error()
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestXXHash64(t *testing.T) {
+	long := make([]byte, 100)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	for _, tt := range []struct {
+		data []byte
+		want uint64
+	}{
+		{nil, 0xef46db3751d8e999},
+		{[]byte("a"), 0xd24ec4f1a98c6e5b},
+		{[]byte("abc"), 0x44bc2cf5ad770999},
+		{long, 0x6ac1e58032166597}, // Long enough for the four-lane loop
+	} {
+		if got := xxhash64(tt.data); got != tt.want {
+			t.Errorf("xxhash64(%q): want %016x; got %016x", tt.data, tt.want, got)
+		}
+	}
+}
+
+func TestCheckSums(t *testing.T) {
+	pos := world.ChunkPos{3, -2}
+	records := []*KeyVal{
+		NewKeyVal(MakeChunkKey(pos, world.Nether, LevelChunkTagVersion), []byte{40}),
+		NewKeyVal(MakeSubChunkKey(pos, world.Nether, 0), []byte("sub-chunk 0")),
+		NewKeyVal(MakeSubChunkKey(pos, world.Nether, 1), []byte("sub-chunk 1")),
+		NewKeyVal(MakeChunkKey(pos, world.Nether, LevelChunkTagBlockEntity), []byte("block entities")),
+	}
+	checksums := ComputeCheckSums(records, nil)
+	if len(checksums) != 3 || checksums[1].YIndex != 1 || checksums[2].Tag != LevelChunkTagBlockEntity {
+		t.Fatalf("want checksums of two sub-chunks and the block entities; got %v", checksums)
+	}
+	val := EncodeCheckSums(checksums)
+	if len(val) != 4+3*checksumEntrySize {
+		t.Errorf("want %d bytes; got %d", 4+3*checksumEntrySize, len(val))
+	}
+	got, err := ParseCheckSums(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, checksums) {
+		t.Errorf("want %v after round trip; got %v", checksums, got)
+	}
+	if mismatches, err := VerifyCheckSums(records, val); err != nil || len(mismatches) != 0 {
+		t.Errorf("want no mismatches; got %v, %v", mismatches, err)
+	}
+
+	records[2] = NewKeyVal(records[2].Key, []byte("changed"))
+	records = append(records, NewKeyVal(MakeChunkKey(pos, world.Nether, LevelChunkTagEntity), []byte("entities")))
+	records = slices.Delete(records, 1, 2)
+	mismatches, err := VerifyCheckSums(records, val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"checksum of SubChunkPrefix 0, which doesn't exist",
+		"checksum of SubChunkPrefix 1 doesn't match",
+		"no checksum of Entity",
+	}
+	if !slices.Equal(mismatches, want) {
+		t.Errorf("want mismatches %q; got %q", want, mismatches)
+	}
+
+	if _, err := ParseCheckSums(val[:len(val)-1]); err == nil {
+		t.Errorf("want an error for a truncated record")
+	}
+}
@@ -0,0 +1,173 @@
+package parse
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+)
+
+// ChunkReport describes everything stored for one chunk, for
+// debugging chunks that render or load wrong.
+type ChunkReport struct {
+	Dimension     string            `json:"dimension"`
+	X             int32             `json:"x"`
+	Z             int32             `json:"z"`
+	Keys          []ChunkKeyReport  `json:"keys"`
+	Version       *byte             `json:"version,omitempty"`
+	SubChunks     []DecodedSubChunk `json:"sub_chunks"`
+	HeightMap     *HeightMap        `json:"height_map,omitempty"` // As stored in Data3D or Data2D, [z][x]
+	Biomes        []BiomeCount      `json:"biomes,omitempty"`
+	BlockEntities []ObjectSummary   `json:"block_entities"`
+	Entities      []ObjectSummary   `json:"entities"`
+	Problems      []string          `json:"problems,omitempty"` // Records that failed to decode
+}
+
+// ChunkKeyReport is one key of a chunk.
+type ChunkKeyReport struct {
+	Tag    string `json:"tag"`
+	YIndex *int8  `json:"y_index,omitempty"` // For sub-chunks
+	Size   int    `json:"size"`
+}
+
+// ObjectSummary is a short description of a block entity or entity.
+type ObjectSummary struct {
+	ID       string    `json:"id"`
+	UniqueID *int64    `json:"unique_id,omitempty"`
+	Pos      []float64 `json:"pos,omitempty"`
+	Legacy   bool      `json:"legacy,omitempty"` // Stored in the chunk's Entity record, not under actorprefix
+}
+
+// ReportChunk describes the chunk at chunkPos in the given dimension.
+// Records that fail to decode are noted in the report's Problems
+// rather than failing the whole report.
+func ReportChunk(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension) (*ChunkReport, error) {
+	kvs, err := AllEntriesWithChunkCoordinatePrefix(db, chunkPos, dimension)
+	if err != nil {
+		return nil, err
+	}
+	res := &ChunkReport{
+		Dimension:     fmt.Sprint(dimension),
+		X:             chunkPos.X(),
+		Z:             chunkPos.Z(),
+		Keys:          []ChunkKeyReport{},
+		SubChunks:     []DecodedSubChunk{},
+		BlockEntities: []ObjectSummary{},
+		Entities:      []ObjectSummary{},
+	}
+	problemf := func(format string, args ...any) {
+		res.Problems = append(res.Problems, fmt.Sprintf(format, args...))
+	}
+
+	for _, kv := range kvs {
+		kt := kv.KeyType()
+		if !kt.IsChunkDataForDimension(dimension) {
+			continue
+		}
+		tag := kt.LevelChunkTag()
+		key := ChunkKeyReport{Tag: strings.TrimPrefix(tag.String(), "LevelChunkTag"), Size: len(kv.Val)}
+		if kt.IsSubChunkPrefix() {
+			y := int8(kv.Key[len(kv.Key)-1])
+			key.YIndex = &y
+		}
+		res.Keys = append(res.Keys, key)
+
+		switch tag {
+		case LevelChunkTagVersion:
+			if len(kv.Val) == 1 {
+				res.Version = &kv.Val[0]
+			}
+		case LevelChunkTagSubChunkPrefix:
+			sc, err := ParseSubChunk(kv)
+			if err != nil {
+				problemf("sub-chunk %d: %v", *key.YIndex, err)
+				continue
+			}
+			res.SubChunks = append(res.SubChunks, decodeSubChunk(sc))
+		case LevelChunkTagData3D, LevelChunkTagData2D:
+			if res.HeightMap, err = StoredHeightMap(kv.Val, dimension); err != nil {
+				problemf("%s: %v", key.Tag, err)
+			}
+			if tag == LevelChunkTagData3D {
+				if res.Biomes, err = BiomeSummary(kv.Val); err != nil {
+					problemf("biomes: %v", err)
+				}
+			}
+		case LevelChunkTagBlockEntity, LevelChunkTagEntity:
+			records, err := DecodeNBTRecords(kv.Val)
+			if err != nil {
+				problemf("%s: %v", key.Tag, err)
+				continue
+			}
+			for _, rec := range records {
+				if tag == LevelChunkTagBlockEntity {
+					res.BlockEntities = append(res.BlockEntities, summarizeBlockEntity(rec.Data))
+				} else {
+					s := summarizeEntity(rec.Data)
+					s.Legacy = true
+					res.Entities = append(res.Entities, s)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(res.SubChunks, func(a, b DecodedSubChunk) int {
+		return cmp.Compare(a.YIndex, b.YIndex)
+	})
+
+	// Modern entities are listed in the chunk's actor digest.
+	digp, err := db.Get(MakeDigpKey(chunkPos, dimension), nil)
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return nil, err
+	}
+	ids, err := ParseDigp(digp)
+	if err != nil {
+		problemf("digp: %v", err)
+	}
+	for _, id := range ids {
+		val, err := db.Get(MakeActorKey(id), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			problemf("digp lists actor %x, which has no actorprefix record", id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records, err := DecodeNBTRecords(val)
+		if err != nil || len(records) != 1 {
+			problemf("actor %x: can't decode: %v", id, err)
+			continue
+		}
+		res.Entities = append(res.Entities, summarizeEntity(records[0].Data))
+	}
+	return res, nil
+}
+
+func summarizeBlockEntity(data map[string]any) ObjectSummary {
+	res := ObjectSummary{}
+	res.ID, _ = data["id"].(string)
+	if pos, ok := NBTBlockPos(data); ok {
+		res.Pos = []float64{float64(pos[0]), float64(pos[1]), float64(pos[2])}
+	}
+	return res
+}
+
+func summarizeEntity(data map[string]any) ObjectSummary {
+	res := ObjectSummary{}
+	res.ID, _ = data["identifier"].(string)
+	if id, ok := data["UniqueID"].(int64); ok {
+		res.UniqueID = &id
+	}
+	if pos, ok := data["Pos"].([]any); ok {
+		for _, p := range pos {
+			if f, ok := p.(float32); ok {
+				res.Pos = append(res.Pos, float64(f))
+			}
+		}
+	}
+	return res
+}
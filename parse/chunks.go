@@ -2,6 +2,7 @@ package parse
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -190,13 +191,13 @@ var levelChunkTagNames = map[byte]string{
 type Chunk struct {
 	Dimension  world.Dimension
 	ChunkPos   world.ChunkPos
-	SubChunks  []subChunk
+	SubChunks  []SubChunk
 	MaxLayer   int
 	KeyVals    []*KeyVal
 	HeightMaps []*HeightMap
 }
 
-type subChunk struct {
+type SubChunk struct {
 	subChunkIndex   int32
 	subChunkVersion int
 	layerCount      int
@@ -204,7 +205,7 @@ type subChunk struct {
 	layers          []subChunkLayer
 }
 
-func (s subChunk) Print(w io.Writer) {
+func (s SubChunk) Print(w io.Writer) {
 	fmt.Fprintf(w, "Subchunk {\n")
 	fmt.Fprintf(w, "  index: %d\n", s.subChunkIndex)
 	fmt.Fprintf(w, "  version: %d\n", s.subChunkVersion)
@@ -213,6 +214,24 @@ func (s subChunk) Print(w io.Writer) {
 	fmt.Fprintf(w, "}\n")
 }
 
+// YIndex returns the vertical index of this sub-chunk: its lowest
+// block is at y == YIndex()*16.
+func (s SubChunk) YIndex() int32 {
+	return s.yIndex
+}
+
+// LayerCount returns the number of block storage layers in this
+// sub-chunk. Layer 0 holds regular blocks; layer 1, if present, mostly
+// holds water for waterlogged blocks.
+func (s SubChunk) LayerCount() int {
+	return len(s.layers)
+}
+
+// Layer returns the given block storage layer.
+func (s SubChunk) Layer(layer int) subChunkLayer {
+	return s.layers[layer]
+}
+
 type subChunkIndices [4096]int
 
 func (s subChunkIndices) get(x, z, y int) int {
@@ -220,13 +239,30 @@ func (s subChunkIndices) get(x, z, y int) int {
 }
 
 type subChunkLayer struct {
+	bitsPerBlock int // As stored; 0 for newly built layers
 	blockEntries *subChunkIndices
 	palettes     []map[string]any
+	rawPalettes  [][]byte // Original encoding of each palette entry, or nil for new entries
 	allAir       *bool
 	airIndex     *int
 }
 
-var seenBitsPerBlock = map[int]bool{}
+// Palette returns the palette of block NBT values for this layer.
+func (l subChunkLayer) Palette() []map[string]any {
+	return l.palettes
+}
+
+// BitsPerBlock returns the number of bits per block index the layer
+// was stored with.
+func (l subChunkLayer) BitsPerBlock() int {
+	return l.bitsPerBlock
+}
+
+// PaletteIndex returns the palette index of the block at the given
+// position within the sub-chunk (each of x, y, z in 0-15).
+func (l subChunkLayer) PaletteIndex(x, y, z int) int {
+	return l.blockEntries.get(x, z, y)
+}
 
 var blocksPerWordForBitsPerBlock = map[int]int{
 	1:  32,
@@ -251,6 +287,13 @@ var wordCountForBitsPerBlock = map[int]int{
 	16: 2048,
 }
 
+// Errors ParseSubChunk wraps when a sub-chunk's blocks can't be
+// decoded.
+var (
+	ErrPaletteIndex = errors.New("palette index out of range")
+	ErrPaletteEntry = errors.New("bad palette entry")
+)
+
 /*
 Notes:
 
@@ -261,8 +304,8 @@ Notes:
 	sub[i], err = db.ldb.Get(k.Sum(keySubChunkData, y), nil)
 	```
 */
-func ParseSubChunk(kv *KeyVal) (subChunk, error) {
-	var res subChunk
+func ParseSubChunk(kv *KeyVal) (SubChunk, error) {
+	var res SubChunk
 
 	if !kv.KeyType().IsSubChunkPrefix() {
 		return res, fmt.Errorf("cannot parse subChunk for key/value of type %s", kv.KeyType())
@@ -309,11 +352,6 @@ func ParseSubChunk(kv *KeyVal) (subChunk, error) {
 			return res, fmt.Errorf("Unable to decode block with 0x7F (127) bits per block (key=%v, layer=%d)", kv.Key, layerIndex)
 		}
 
-		if !seenBitsPerBlock[bitsPerBlock] {
-			seenBitsPerBlock[bitsPerBlock] = true
-			fmt.Printf("First sight of %d bits per block for subchunk (key=%v, layer=%d)\n", bitsPerBlock, kv.Key, layerIndex)
-		}
-
 		paletteEntryCount := 1
 
 		wordCount, ok := wordCountForBitsPerBlock[bitsPerBlock]
@@ -326,6 +364,7 @@ func ParseSubChunk(kv *KeyVal) (subChunk, error) {
 		if len(wordBytes) < wordCount*4 {
 			return res, fmt.Errorf("ran out of bytes for block entries (key=%v, layer=%d)", kv.Key, layerIndex)
 		}
+		layer.bitsPerBlock = bitsPerBlock
 		layer.blockEntries = readBlockEntries(wordBytes, bitsPerBlock)
 
 		if bitsPerBlock > 0 {
@@ -338,33 +377,34 @@ func ParseSubChunk(kv *KeyVal) (subChunk, error) {
 
 		for i, paletteIndex := range layer.blockEntries {
 			if paletteIndex >= paletteEntryCount {
-				return res, fmt.Errorf("block %d has palette index %d, which is >= %d (key=%v, layer=%d): %w", i, paletteIndex, paletteEntryCount, kv.Key, layerIndex, err)
+				return res, fmt.Errorf("%w: block %d has palette index %d, which is >= %d (key=%v, layer=%d)", ErrPaletteIndex, i, paletteIndex, paletteEntryCount, kv.Key, layerIndex)
 			}
 		}
 
 		d := nbt.NewDecoderWithEncoding(buf, nbt.LittleEndian)
 		for i := 0; i < paletteEntryCount; i++ {
 			// fmt.Printf("About to read palette %d at at: %v\n", i, []byte(buf.String()[:10]))
+			start := len(kv.Val) - buf.Len()
 			var m map[string]any
 			err := d.Decode(&m)
 			if err != nil {
 				// fmt.Printf("Key: %v\nValue:\n%v\n", kv.Key, kv.Val)
-				return res, fmt.Errorf("unable to decode palette entry %d (key=%v, layer=%d): %w", i, kv.Key, layerIndex, err)
+				return res, fmt.Errorf("%w: unable to decode palette entry %d (key=%v, layer=%d): %w", ErrPaletteEntry, i, kv.Key, layerIndex, err)
 			}
 
 			// fmt.Printf("%v\n", m)
 
-			if m["name"] == "air" {
+			if IsAirBlock(m) {
 				layer.airIndex = &i
 			}
 
 			layer.palettes = append(layer.palettes, m)
+			end := len(kv.Val) - buf.Len()
+			layer.rawPalettes = append(layer.rawPalettes, kv.Val[start:end:end])
 		}
 
-		if bitsPerBlock == 0 && layer.airIndex != nil && *layer.airIndex == 0 {
-			t := true
-			layer.allAir = &t
-		}
+		allAir := layer.airIndex != nil && layerIsAllAir(layer)
+		layer.allAir = &allAir
 
 		res.layers = append(res.layers, layer)
 	}
@@ -372,6 +412,48 @@ func ParseSubChunk(kv *KeyVal) (subChunk, error) {
 	return res, nil
 }
 
+// IsAirBlock reports whether a block palette entry is air.
+func IsAirBlock(m map[string]any) bool {
+	return m["name"] == "minecraft:air" || m["name"] == "air"
+}
+
+// layerIsAllAir reports whether every block in the layer refers to an
+// air palette entry.
+func layerIsAllAir(layer subChunkLayer) bool {
+	isAir := make([]bool, len(layer.palettes))
+	found := false
+	for i, m := range layer.palettes {
+		isAir[i] = IsAirBlock(m)
+		found = found || isAir[i]
+	}
+	if !found {
+		return false
+	}
+	for _, paletteIndex := range layer.blockEntries {
+		if !isAir[paletteIndex] {
+			return false
+		}
+	}
+	return true
+}
+
+// AllAir reports whether every layer of the sub-chunk holds nothing but
+// air. A sub-chunk with no layers is all air too.
+func (s SubChunk) AllAir() bool {
+	for _, layer := range s.layers {
+		if layer.allAir == nil {
+			if !layerIsAllAir(layer) {
+				return false
+			}
+			continue
+		}
+		if !*layer.allAir {
+			return false
+		}
+	}
+	return true
+}
+
 func readBlockEntries(bb []byte, bitsPerBlock int) *subChunkIndices {
 	index := 0
 	var indices subChunkIndices
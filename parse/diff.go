@@ -0,0 +1,378 @@
+package parse
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+)
+
+// Change says how a chunk, record or block differs between two worlds.
+type Change string
+
+const (
+	ChangeAdded   Change = "added"
+	ChangeRemoved Change = "removed"
+	ChangeChanged Change = "changed"
+)
+
+// WorldDiff is the difference between two worlds, from an old one to
+// a new one.
+type WorldDiff struct {
+	Chunks  []ChunkDiff  `json:"chunks"`  // Sorted by dimension, then x, then z
+	Globals []GlobalDiff `json:"globals"` // Sorted by key
+}
+
+// ChunkDiff is how one chunk differs between two worlds.
+type ChunkDiff struct {
+	Dimension string       `json:"dimension"`
+	X         int32        `json:"x"`
+	Z         int32        `json:"z"`
+	Change    Change       `json:"change"`
+	Records   []RecordDiff `json:"records"`
+
+	dimension world.Dimension
+}
+
+// RecordDiff is one record of a chunk that differs between two
+// worlds. Actors are attributed to the chunks whose digests list them.
+type RecordDiff struct {
+	Tag     string `json:"tag"`                // As in ChunkKeyReport, or "Digest" or "Actor"
+	YIndex  *int8  `json:"y_index,omitempty"`  // For sub-chunks
+	ActorID *int64 `json:"actor_id,omitempty"` // For actors
+	Change  Change `json:"change"`
+}
+
+// GlobalDiff is a record not tied to a chunk that differs between two
+// worlds.
+type GlobalDiff struct {
+	Key    string `json:"key"` // Quoted Go string
+	Type   string `json:"type"`
+	Change Change `json:"change"`
+}
+
+// BlockDiff is one block that differs between two worlds.
+type BlockDiff struct {
+	X     int            `json:"x"`
+	Y     int            `json:"y"`
+	Z     int            `json:"z"`
+	Layer int            `json:"layer"`
+	From  map[string]any `json:"from"`
+	To    map[string]any `json:"to"`
+}
+
+// Pos returns the chunk's position.
+func (c ChunkDiff) Pos() world.ChunkPos {
+	return world.ChunkPos{c.X, c.Z}
+}
+
+// WorldDimension returns the chunk's dimension.
+func (c ChunkDiff) WorldDimension() world.Dimension {
+	return c.dimension
+}
+
+// SubChunks returns the y indexes of the chunk's sub-chunks that
+// differ, in increasing order.
+func (c ChunkDiff) SubChunks() []int32 {
+	var res []int32
+	for _, r := range c.Records {
+		if r.YIndex != nil {
+			res = append(res, int32(*r.YIndex))
+		}
+	}
+	return res
+}
+
+// Summary describes the chunk's changed records briefly, like
+// "SubChunkPrefix x3, BlockEntity, Actor x2".
+func (c ChunkDiff) Summary() string {
+	var tags []string
+	counts := make(map[string]int)
+	for _, r := range c.Records {
+		if counts[r.Tag] == 0 {
+			tags = append(tags, r.Tag)
+		}
+		counts[r.Tag]++
+	}
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		parts[i] = tag
+		if counts[tag] > 1 {
+			parts[i] += fmt.Sprintf(" x%d", counts[tag])
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Counts returns the number of chunks added, removed and changed in
+// the given dimension.
+func (d *WorldDiff) Counts(dimension world.Dimension) (added, removed, changed int) {
+	for _, c := range d.Chunks {
+		if c.dimension != dimension {
+			continue
+		}
+		switch c.Change {
+		case ChangeAdded:
+			added++
+		case ChangeRemoved:
+			removed++
+		case ChangeChanged:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// ChunkChanges returns the changed chunks of one dimension by
+// position.
+func (d *WorldDiff) ChunkChanges(dimension world.Dimension) map[world.ChunkPos]ChunkDiff {
+	res := make(map[world.ChunkPos]ChunkDiff)
+	for _, c := range d.Chunks {
+		if c.dimension == dimension {
+			res[c.Pos()] = c
+		}
+	}
+	return res
+}
+
+// dimChunkPos is a chunk position in a particular dimension.
+type dimChunkPos struct {
+	dimension world.Dimension
+	pos       world.ChunkPos
+}
+
+// worldDiffer accumulates a WorldDiff while DiffWorlds walks the keys
+// of both worlds.
+type worldDiffer struct {
+	inOld, inNew map[dimChunkPos]bool
+	records      map[dimChunkPos][]RecordDiff
+	actors       map[string]Change      // By actor ID
+	actorChunks  map[string]dimChunkPos // Where the new world's digests put each actor
+	oldActors    map[string]dimChunkPos // Where the old world's digests put each actor
+	globals      []GlobalDiff
+}
+
+// note records a key present in the old world, the new world or both,
+// and how its value changed: "" if it didn't.
+func (wd *worldDiffer) note(key, oldVal, newVal []byte, inOld, inNew bool, change Change) {
+	kv := NewKeyVal(key, nil)
+	info := kv.KeyTypeAndChunkLocation()
+	switch {
+	case info.HasLocation && info.KeyType.IsChunkData():
+		at := dimChunkPos{info.Dimension, info.ChunkPos}
+		wd.inOld[at] = wd.inOld[at] || inOld
+		wd.inNew[at] = wd.inNew[at] || inNew
+		if change == "" {
+			return
+		}
+		r := RecordDiff{Tag: strings.TrimPrefix(info.KeyType.LevelChunkTag().String(), "LevelChunkTag"), Change: change}
+		if info.KeyType.IsSubChunkPrefix() {
+			y := int8(key[len(key)-1])
+			r.YIndex = &y
+		}
+		wd.records[at] = append(wd.records[at], r)
+		return
+	case info.KeyType == KeyTypeDigp:
+		chunkPos, dimension, err := ParseDigpKey(key)
+		if err != nil {
+			break
+		}
+		at := dimChunkPos{dimension, chunkPos}
+		if ids, err := ParseDigp(oldVal); inOld && err == nil {
+			for _, id := range ids {
+				wd.oldActors[string(id)] = at
+			}
+		}
+		if ids, err := ParseDigp(newVal); inNew && err == nil {
+			for _, id := range ids {
+				wd.actorChunks[string(id)] = at
+			}
+		}
+		if change != "" {
+			wd.records[at] = append(wd.records[at], RecordDiff{Tag: "Digest", Change: change})
+		}
+		return
+	case info.KeyType == KeyTypeActorprefix:
+		if id, ok := ActorID(key); ok {
+			if change != "" {
+				wd.actors[string(id)] = change
+			}
+			return
+		}
+	}
+	if change != "" {
+		wd.globals = append(wd.globals, GlobalDiff{Key: strconv.Quote(string(key)), Type: info.KeyType.String(), Change: change})
+	}
+}
+
+// DiffWorlds compares two worlds key by key, iterating over both in
+// order, and returns how the new one differs from the old one. A chunk
+// is added if the old world has no records for it, removed if the new
+// one has none, and changed otherwise.
+func DiffWorlds(oldDB, newDB *leveldb.DB) (*WorldDiff, error) {
+	wd := &worldDiffer{
+		inOld:       make(map[dimChunkPos]bool),
+		inNew:       make(map[dimChunkPos]bool),
+		records:     make(map[dimChunkPos][]RecordDiff),
+		actors:      make(map[string]Change),
+		actorChunks: make(map[string]dimChunkPos),
+		oldActors:   make(map[string]dimChunkPos),
+	}
+
+	oldIter := oldDB.NewIterator(nil, nil)
+	defer oldIter.Release()
+	newIter := newDB.NewIterator(nil, nil)
+	defer newIter.Release()
+	okOld, okNew := oldIter.Next(), newIter.Next()
+	for okOld || okNew {
+		c := 0
+		switch {
+		case !okNew:
+			c = -1
+		case !okOld:
+			c = 1
+		default:
+			c = bytes.Compare(oldIter.Key(), newIter.Key())
+		}
+		switch {
+		case c < 0:
+			wd.note(oldIter.Key(), oldIter.Value(), nil, true, false, ChangeRemoved)
+			okOld = oldIter.Next()
+		case c > 0:
+			wd.note(newIter.Key(), nil, newIter.Value(), false, true, ChangeAdded)
+			okNew = newIter.Next()
+		default:
+			var change Change
+			if !bytes.Equal(oldIter.Value(), newIter.Value()) {
+				change = ChangeChanged
+			}
+			wd.note(oldIter.Key(), oldIter.Value(), newIter.Value(), true, true, change)
+			okOld, okNew = oldIter.Next(), newIter.Next()
+		}
+	}
+	if err := oldIter.Error(); err != nil {
+		return nil, err
+	}
+	if err := newIter.Error(); err != nil {
+		return nil, err
+	}
+
+	for id, change := range wd.actors {
+		at, ok := wd.actorChunks[id]
+		if !ok {
+			at, ok = wd.oldActors[id]
+		}
+		uniqueID := int64(binary.LittleEndian.Uint64([]byte(id)))
+		if !ok {
+			wd.globals = append(wd.globals, GlobalDiff{Key: strconv.Quote(string(MakeActorKey([]byte(id)))), Type: KeyTypeActorprefix.String(), Change: change})
+			continue
+		}
+		wd.records[at] = append(wd.records[at], RecordDiff{Tag: "Actor", ActorID: &uniqueID, Change: change})
+	}
+
+	res := &WorldDiff{Chunks: []ChunkDiff{}, Globals: wd.globals}
+	for at, records := range wd.records {
+		c := ChunkDiff{
+			Dimension: fmt.Sprint(at.dimension),
+			X:         at.pos.X(),
+			Z:         at.pos.Z(),
+			Change:    ChangeChanged,
+			Records:   records,
+			dimension: at.dimension,
+		}
+		switch {
+		case !wd.inOld[at] && wd.inNew[at]:
+			c.Change = ChangeAdded
+		case wd.inOld[at] && !wd.inNew[at]:
+			c.Change = ChangeRemoved
+		}
+		slices.SortFunc(c.Records, func(a, b RecordDiff) int {
+			return cmp.Or(cmp.Compare(a.Tag, b.Tag), cmp.Compare(derefOr(a.YIndex), derefOr(b.YIndex)), cmp.Compare(derefOr(a.ActorID), derefOr(b.ActorID)))
+		})
+		res.Chunks = append(res.Chunks, c)
+	}
+	slices.SortFunc(res.Chunks, func(a, b ChunkDiff) int {
+		aID, _ := world.DimensionID(a.dimension)
+		bID, _ := world.DimensionID(b.dimension)
+		return cmp.Or(cmp.Compare(aID, bID), cmp.Compare(a.X, b.X), cmp.Compare(a.Z, b.Z))
+	})
+	slices.SortFunc(res.Globals, func(a, b GlobalDiff) int {
+		return cmp.Compare(a.Key, b.Key)
+	})
+	return res, nil
+}
+
+// derefOr returns *p, or zero if p is nil.
+func derefOr[T cmp.Ordered](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// readSubChunkOrAir returns the sub-chunk with the given y index, or
+// an all-air one if there's none.
+func readSubChunkOrAir(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension, yIndex int32) (SubChunk, error) {
+	key := MakeSubChunkKey(chunkPos, dimension, yIndex)
+	val, err := db.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return NewSubChunk(yIndex), nil
+	} else if err != nil {
+		return SubChunk{}, err
+	}
+	return ParseSubChunk(NewKeyVal(key, val))
+}
+
+// blockOrAir returns the block in a layer of a sub-chunk, or air if the
+// sub-chunk doesn't have that layer.
+func blockOrAir(sc SubChunk, x, y, z, layer int) map[string]any {
+	if layer >= sc.LayerCount() {
+		return AirBlock()
+	}
+	return sc.Block(x, y, z, layer)
+}
+
+// DiffSubChunkBlocks returns the blocks of one sub-chunk that differ
+// between two worlds, in both layers. A missing sub-chunk counts as
+// all air.
+func DiffSubChunkBlocks(oldDB, newDB *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension, yIndex int32) ([]BlockDiff, error) {
+	oldSC, err := readSubChunkOrAir(oldDB, chunkPos, dimension, yIndex)
+	if err != nil {
+		return nil, fmt.Errorf("old sub-chunk %d of chunk %v in %v: %w", yIndex, chunkPos, dimension, err)
+	}
+	newSC, err := readSubChunkOrAir(newDB, chunkPos, dimension, yIndex)
+	if err != nil {
+		return nil, fmt.Errorf("new sub-chunk %d of chunk %v in %v: %w", yIndex, chunkPos, dimension, err)
+	}
+	var res []BlockDiff
+	layers := max(oldSC.LayerCount(), newSC.LayerCount())
+	for layer := range layers {
+		for x := range 16 {
+			for z := range 16 {
+				for y := range 16 {
+					from, to := blockOrAir(oldSC, x, y, z, layer), blockOrAir(newSC, x, y, z, layer)
+					if SameBlock(from, to) {
+						continue
+					}
+					res = append(res, BlockDiff{
+						X:     int(chunkPos.X())<<4 + x,
+						Y:     int(yIndex)<<4 + y,
+						Z:     int(chunkPos.Z())<<4 + z,
+						Layer: layer,
+						From:  from,
+						To:    to,
+					})
+				}
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,130 @@
+package parse
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/storage"
+)
+
+// memDB returns an in-memory leveldb holding the given keys.
+func memDB(t *testing.T, keys map[string][]byte) *leveldb.DB {
+	t.Helper()
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for k, v := range keys {
+		if err := db.Put([]byte(k), v, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func actorID(id int64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(id))
+}
+
+func TestDiffWorlds(t *testing.T) {
+	stone, _ := NewBlock("stone")
+	sc := NewSubChunk(1)
+	sc.SetBlock(1, 2, 3, 0, stone)
+	stoneVal, err := sc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	airVal, err := NewSubChunk(0).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chunk a gains a sub-chunk, b is removed along with its actor 3,
+	// c keeps its digest but its actor 1 changes, and d is added. Actor
+	// 2 isn't in any digest.
+	a, b, c, d := world.ChunkPos{0, 0}, world.ChunkPos{1, 0}, world.ChunkPos{2, 0}, world.ChunkPos{3, 0}
+	version := func(pos world.ChunkPos) string {
+		return string(MakeChunkKey(pos, world.Overworld, LevelChunkTagVersion))
+	}
+	actor := func(id int64) string {
+		return string(MakeActorKey(actorID(id)))
+	}
+	sub0, sub1 := string(MakeSubChunkKey(a, world.Overworld, 0)), string(MakeSubChunkKey(a, world.Overworld, 1))
+	digpB, digpC := string(MakeDigpKey(b, world.Overworld)), string(MakeDigpKey(c, world.Overworld))
+	oldDB := memDB(t, map[string][]byte{
+		version(a):      {40},
+		sub0:            airVal,
+		version(b):      {40},
+		digpB:           actorID(3),
+		version(c):      {40},
+		digpC:           actorID(1),
+		actor(1):        []byte("pig"),
+		actor(2):        []byte("cow"),
+		actor(3):        []byte("sheep"),
+		"~local_player": []byte("player"),
+	})
+	newDB := memDB(t, map[string][]byte{
+		version(a):      {40},
+		sub0:            airVal,
+		sub1:            stoneVal,
+		version(c):      {40},
+		digpC:           actorID(1),
+		version(d):      {40},
+		actor(1):        []byte("pig, moved"),
+		actor(2):        []byte("cow, moved"),
+		"~local_player": []byte("player, moved"),
+	})
+
+	diff, err := DiffWorlds(oldDB, newDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y1, id1, id3 := int8(1), int64(1), int64(3)
+	chunk := func(pos world.ChunkPos, change Change, records ...RecordDiff) ChunkDiff {
+		return ChunkDiff{Dimension: "Overworld", X: pos.X(), Z: pos.Z(), Change: change, Records: records, dimension: world.Overworld}
+	}
+	want := &WorldDiff{
+		Chunks: []ChunkDiff{
+			chunk(a, ChangeChanged, RecordDiff{Tag: "SubChunkPrefix", YIndex: &y1, Change: ChangeAdded}),
+			chunk(b, ChangeRemoved,
+				RecordDiff{Tag: "Actor", ActorID: &id3, Change: ChangeRemoved},
+				RecordDiff{Tag: "Digest", Change: ChangeRemoved},
+				RecordDiff{Tag: "Version", Change: ChangeRemoved}),
+			chunk(c, ChangeChanged, RecordDiff{Tag: "Actor", ActorID: &id1, Change: ChangeChanged}),
+			chunk(d, ChangeAdded, RecordDiff{Tag: "Version", Change: ChangeAdded}),
+		},
+		Globals: []GlobalDiff{
+			{Key: `"actorprefix\x02\x00\x00\x00\x00\x00\x00\x00"`, Type: "KeyTypeActorprefix", Change: ChangeChanged},
+			{Key: `"~local_player"`, Type: "KeyTypeLocalPlayer", Change: ChangeChanged},
+		},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("want %+v; got %+v", want, diff)
+	}
+
+	added, removed, changed := diff.Counts(world.Overworld)
+	if added != 1 || removed != 1 || changed != 2 {
+		t.Errorf("want 1 chunk added, 1 removed and 2 changed; got %d, %d, %d", added, removed, changed)
+	}
+
+	// Sub-chunk 1 of chunk a is missing from the old world, so it counts
+	// as air there.
+	blocks, err := DiffSubChunkBlocks(oldDB, newDB, a, world.Overworld, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("want 1 changed block; got %v", blocks)
+	}
+	if got := blocks[0]; got.X != 1 || got.Y != 18 || got.Z != 3 || got.Layer != 0 || got.From["name"] != "minecraft:air" || got.To["name"] != "minecraft:stone" {
+		t.Errorf("want air changed to stone at 1,18,3; got %+v", got)
+	}
+	// And the other way round.
+	if blocks, err := DiffSubChunkBlocks(newDB, oldDB, a, world.Overworld, 1); err != nil || len(blocks) != 1 || blocks[0].To["name"] != "minecraft:air" {
+		t.Errorf("want stone changed to air; got %v, %v", blocks, err)
+	}
+}
@@ -0,0 +1,207 @@
+package parse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// DefaultBlockVersion is the block version used for new palette
+// entries when there's no existing entry to copy a version from. It's
+// the version seen on most blocks in my 1.20 saves.
+const DefaultBlockVersion = int32(18100737)
+
+// AirBlock returns a new palette entry for air.
+func AirBlock() map[string]any {
+	return map[string]any{
+		"name":    "minecraft:air",
+		"states":  map[string]any{},
+		"version": DefaultBlockVersion,
+	}
+}
+
+// MakeChunkKey makes the leveldb key for a chunk record with the given
+// LevelChunkTag.
+func MakeChunkKey(chunkPos world.ChunkPos, dimension world.Dimension, tag LevelChunkTag) []byte {
+	return append(MakeChunkPrefix(chunkPos, dimension), byte(tag))
+}
+
+// MakeSubChunkKey makes the leveldb key for the sub-chunk with the
+// given y index.
+func MakeSubChunkKey(chunkPos world.ChunkPos, dimension world.Dimension, yIndex int32) []byte {
+	return append(MakeChunkKey(chunkPos, dimension, LevelChunkTagSubChunkPrefix), byte(int8(yIndex)))
+}
+
+// NewSubChunk returns a version 9 sub-chunk with a single layer of
+// air.
+func NewSubChunk(yIndex int32) SubChunk {
+	return SubChunk{
+		subChunkIndex:   yIndex,
+		subChunkVersion: 9,
+		layerCount:      1,
+		yIndex:          yIndex,
+		layers:          []subChunkLayer{newAirLayer()},
+	}
+}
+
+func newAirLayer() subChunkLayer {
+	airIndex := 0
+	allAir := true
+	return subChunkLayer{
+		blockEntries: &subChunkIndices{},
+		palettes:     []map[string]any{AirBlock()},
+		rawPalettes:  [][]byte{nil},
+		allAir:       &allAir,
+		airIndex:     &airIndex,
+	}
+}
+
+// Block returns the palette entry for the block at the given position
+// within the sub-chunk (each of x, y, z in 0-15), or nil if the
+// sub-chunk doesn't have the given layer.
+func (s SubChunk) Block(x, y, z, layer int) map[string]any {
+	if layer >= len(s.layers) {
+		return nil
+	}
+	l := s.layers[layer]
+	return l.palettes[l.blockEntries.get(x, z, y)]
+}
+
+// SetBlock sets the block at the given position within the sub-chunk
+// (each of x, y, z in 0-15), adding air-filled layers if needed. If
+// the block isn't already in the layer's palette, it's added; if it
+// has no "version", it's given the newest version already used in the
+// sub-chunk. Palette entries that are no longer used are only dropped
+// by Encode.
+func (s *SubChunk) SetBlock(x, y, z, layer int, block map[string]any) {
+	for len(s.layers) <= layer {
+		s.layers = append(s.layers, newAirLayer())
+	}
+	s.layerCount = len(s.layers)
+
+	l := &s.layers[layer]
+	index := slices.IndexFunc(l.palettes, func(entry map[string]any) bool {
+		return SameBlock(entry, block)
+	})
+	if index < 0 {
+		if _, ok := block["version"]; !ok {
+			block = cloneBlock(block)
+			block["version"] = s.newestBlockVersion()
+		}
+		index = len(l.palettes)
+		l.palettes = append(l.palettes, block)
+		l.rawPalettes = append(l.rawPalettes, nil)
+	}
+
+	// The cached air information may no longer be right.
+	l.allAir = nil
+	l.airIndex = nil
+	l.blockEntries[x<<8+z<<4+y] = index
+}
+
+func (s SubChunk) newestBlockVersion() int32 {
+	newest := int32(0)
+	for _, l := range s.layers {
+		for _, entry := range l.palettes {
+			if v, ok := entry["version"].(int32); ok && v > newest {
+				newest = v
+			}
+		}
+	}
+	if newest == 0 {
+		return DefaultBlockVersion
+	}
+	return newest
+}
+
+func cloneBlock(block map[string]any) map[string]any {
+	res := make(map[string]any, len(block)+1)
+	for k, v := range block {
+		res[k] = v
+	}
+	return res
+}
+
+// bitsPerBlockChoices are the palette sizes the disk format supports,
+// in increasing order.
+var bitsPerBlockChoices = []int{0, 1, 2, 3, 4, 5, 6, 8, 16}
+
+// Encode returns the version 9 disk encoding of the sub-chunk. Unused
+// palette entries are dropped, the remaining ones keep their order,
+// and entries that came from ParseSubChunk are written back exactly
+// as they were read.
+func (s SubChunk) Encode() ([]byte, error) {
+	if s.subChunkVersion != 9 {
+		return nil, fmt.Errorf("encoding for subChunk version %d not implemented", s.subChunkVersion)
+	}
+	res := []byte{9, byte(len(s.layers)), byte(int8(s.yIndex))}
+
+	for layerIndex, l := range s.layers {
+		used := make([]bool, len(l.palettes))
+		for _, index := range l.blockEntries {
+			used[index] = true
+		}
+		remap := make([]int, len(l.palettes))
+		var palettes []map[string]any
+		var rawPalettes [][]byte
+		for i, u := range used {
+			if !u {
+				continue
+			}
+			remap[i] = len(palettes)
+			palettes = append(palettes, l.palettes[i])
+			rawPalettes = append(rawPalettes, l.rawPalettes[i])
+		}
+
+		bitsPerBlock := -1
+		for _, bits := range bitsPerBlockChoices {
+			if 1<<bits >= len(palettes) {
+				bitsPerBlock = bits
+				break
+			}
+		}
+		if bitsPerBlock < 0 {
+			return nil, fmt.Errorf("too many palette entries (%d) in layer %d", len(palettes), layerIndex)
+		}
+
+		res = append(res, byte(bitsPerBlock<<1))
+		if bitsPerBlock > 0 {
+			var indices subChunkIndices
+			for i, index := range l.blockEntries {
+				indices[i] = remap[index]
+			}
+			res = appendBlockEntries(res, &indices, bitsPerBlock)
+			res = binary.LittleEndian.AppendUint32(res, uint32(len(palettes)))
+		}
+
+		for i, entry := range palettes {
+			if rawPalettes[i] != nil {
+				res = append(res, rawPalettes[i]...)
+				continue
+			}
+			data, err := nbt.MarshalEncoding(entry, nbt.LittleEndian)
+			if err != nil {
+				return nil, fmt.Errorf("unable to encode palette entry %d (layer=%d): %w", i, layerIndex, err)
+			}
+			res = append(res, data...)
+		}
+	}
+
+	return res, nil
+}
+
+// appendBlockEntries is the inverse of readBlockEntries.
+func appendBlockEntries(b []byte, indices *subChunkIndices, bitsPerBlock int) []byte {
+	perWord := blocksPerWordForBitsPerBlock[bitsPerBlock]
+	for i := 0; i < len(indices); i += perWord {
+		var word uint32
+		for j := 0; j < perWord && i+j < len(indices); j++ {
+			word |= uint32(indices[i+j]) << (j * bitsPerBlock)
+		}
+		b = binary.LittleEndian.AppendUint32(b, word)
+	}
+	return b
+}
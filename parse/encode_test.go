@@ -0,0 +1,81 @@
+package parse
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestSubChunkEncodeRoundTrip(t *testing.T) {
+	stone := map[string]any{"name": "minecraft:stone", "states": map[string]any{}, "version": DefaultBlockVersion}
+	water := map[string]any{"name": "minecraft:water", "states": map[string]any{"liquid_depth": int32(0)}}
+
+	for _, blockCount := range []int{0, 1, 2, 3, 5, 17, 40, 300} {
+		sc := NewSubChunk(-2)
+		for i := 0; i < blockCount; i++ {
+			block := map[string]any{"name": "minecraft:wool", "states": map[string]any{"color": string(rune('a' + i%26)), "n": int32(i)}}
+			sc.SetBlock(i%16, (i/16)%16, i/256, 0, block)
+		}
+		sc.SetBlock(15, 15, 15, 0, stone)
+		sc.SetBlock(15, 15, 15, 1, water)
+
+		val, err := sc.Encode()
+		if err != nil {
+			t.Fatalf("%d blocks: Encode: %v", blockCount, err)
+		}
+		kv := NewKeyVal(MakeSubChunkKey(world.ChunkPos{1, 2}, world.Overworld, -2), val)
+		got, err := ParseSubChunk(kv)
+		if err != nil {
+			t.Fatalf("%d blocks: ParseSubChunk: %v", blockCount, err)
+		}
+		if got.YIndex() != -2 || got.LayerCount() != 2 {
+			t.Fatalf("%d blocks: want yIndex -2 and 2 layers; got %d and %d", blockCount, got.YIndex(), got.LayerCount())
+		}
+		for x := 0; x < 16; x++ {
+			for y := 0; y < 16; y++ {
+				for z := 0; z < 16; z++ {
+					for layer := 0; layer < 2; layer++ {
+						if want, have := sc.Block(x, y, z, layer), got.Block(x, y, z, layer); !SameBlock(want, have) {
+							t.Fatalf("%d blocks: block (%d,%d,%d) layer %d: want %v; got %v", blockCount, x, y, z, layer, want, have)
+						}
+					}
+				}
+			}
+		}
+		if v := got.Block(15, 15, 15, 1)["version"]; v != DefaultBlockVersion {
+			t.Errorf("%d blocks: want new water block to get version %d; got %v", blockCount, DefaultBlockVersion, v)
+		}
+
+		// Re-encoding a parsed sub-chunk should give back the same bytes.
+		again, err := got.Encode()
+		if err != nil {
+			t.Fatalf("%d blocks: re-Encode: %v", blockCount, err)
+		}
+		if !bytes.Equal(val, again) {
+			t.Errorf("%d blocks: re-encoding changed the bytes", blockCount)
+		}
+	}
+}
+
+func TestSubChunkEncodeDropsUnusedPaletteEntries(t *testing.T) {
+	sc := NewSubChunk(0)
+	stone := map[string]any{"name": "minecraft:stone", "states": map[string]any{}}
+	for x := 0; x < 16; x++ {
+		sc.SetBlock(x, 0, 0, 0, stone)
+	}
+	for x := 0; x < 16; x++ {
+		sc.SetBlock(x, 0, 0, 0, AirBlock())
+	}
+	val, err := sc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseSubChunk(NewKeyVal(MakeSubChunkKey(world.ChunkPos{}, world.Overworld, 0), val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(got.Layer(0).Palette()); n != 1 {
+		t.Errorf("want 1 palette entry after removing all stone; got %d", n)
+	}
+}
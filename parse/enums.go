@@ -1,7 +1,10 @@
 package parse
 
 import (
+	"fmt"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/df-mc/dragonfly/server/world"
 )
@@ -227,6 +230,34 @@ func (kt KeyType) String() string {
 	return "KeyTypeINVALID:%d" + strconv.Itoa(int(kt))
 }
 
+// ParseKeyTypes returns the KeyTypes with the given name, ignoring
+// case, with or without the "KeyType" prefix: "KeyTypeDigp" or "digp".
+// A chunk key type name without a dimension, like "SubChunkPrefix",
+// returns the key types of all three dimensions.
+func ParseKeyTypes(name string) ([]KeyType, error) {
+	name = strings.TrimPrefix(strings.ToLower(name), "keytype")
+	var res []KeyType
+	for kt, s := range KeyTypeToString {
+		s = strings.TrimPrefix(strings.ToLower(s), "keytype")
+		if s == name {
+			return []KeyType{kt}, nil
+		}
+		if !kt.IsChunkData() {
+			continue
+		}
+		for _, dim := range []string{"overworld", "nether", "end"} {
+			if strings.TrimPrefix(s, dim) == name && s != name {
+				res = append(res, kt)
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("unknown key type %q", name)
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
 func (kt KeyType) IsChunkData() bool {
 	return kt >= KeyTypeOverworldData3D && kt <= KeyTypeEndLegacyVersion
 }
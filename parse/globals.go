@@ -0,0 +1,422 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// globalKeys are the keys of the world-wide records DecodeGlobal
+// knows, in the order GlobalRecords returns them.
+var globalKeys = []string{
+	"scoreboard",
+	"mobevents",
+	"schedulerWT",
+	"AutonomousEntities",
+	"BiomeData",
+	"LevelChunkMetaDataDictionary",
+	"Overworld",
+	"Nether",
+	"TheEnd",
+}
+
+// Scoreboard is the scoreboard record: the objectives created with
+// /scoreboard, where they're shown, and who has scores.
+type Scoreboard struct {
+	Objectives        []Objective        `json:"objectives"`
+	DisplayObjectives []DisplayObjective `json:"display_objectives"`
+	Entries           []ScoreboardEntry  `json:"entries"`
+	LastUniqueID      int64              `json:"last_unique_id"`
+	Other             map[string]any     `json:"other,omitempty"` // Fields not decoded above
+}
+
+// Objective is one scoreboard objective.
+type Objective struct {
+	Name        string  `json:"name"`
+	DisplayName string  `json:"display_name"`
+	Criteria    string  `json:"criteria"`
+	Scores      []Score `json:"scores"`
+}
+
+// Score is one score of an objective.
+type Score struct {
+	ScoreboardID int64  `json:"scoreboard_id"`
+	Holder       string `json:"holder,omitempty"` // From the matching ScoreboardEntry
+	Score        int32  `json:"score"`
+}
+
+// DisplayObjective says where an objective is shown.
+type DisplayObjective struct {
+	Slot          string `json:"slot"` // list, sidebar or belowname
+	ObjectiveName string `json:"objective_name"`
+	SortOrder     string `json:"sort_order"`
+}
+
+// ScoreboardEntry is a score holder: a player, an entity, or a fake
+// player (a name set with /scoreboard players set).
+type ScoreboardEntry struct {
+	ScoreboardID   int64  `json:"scoreboard_id"`
+	IdentityType   string `json:"identity_type"`
+	PlayerID       int64  `json:"player_id,omitempty"`
+	EntityID       int64  `json:"entity_id,omitempty"`
+	FakePlayerName string `json:"fake_player_name,omitempty"`
+}
+
+// Holder returns a short description of the score holder.
+func (e ScoreboardEntry) Holder() string {
+	switch {
+	case e.FakePlayerName != "":
+		return e.FakePlayerName
+	case e.PlayerID != 0:
+		return fmt.Sprintf("player %d", e.PlayerID)
+	case e.EntityID != 0:
+		return fmt.Sprintf("entity %d", e.EntityID)
+	}
+	return e.IdentityType
+}
+
+// scoreboardIdentityTypes are the names of IdentityType values.
+var scoreboardIdentityTypes = map[uint8]string{
+	1: "player",
+	2: "entity",
+	3: "fake_player",
+}
+
+// MobEvents is the mobevents record: the toggles set with /mobevent.
+type MobEvents struct {
+	EventsEnabled bool            `json:"events_enabled"`
+	Events        map[string]bool `json:"events"` // By event name, eg. "minecraft:pillager_patrols_event"
+}
+
+// WanderingTraderScheduler is the schedulerWT record, which decides
+// when the wandering trader next spawns.
+type WanderingTraderScheduler struct {
+	DaysSinceLastSpawn int32          `json:"days_since_last_spawn"`
+	IsSpawning         bool           `json:"is_spawning"`
+	NextSpawnCheckTick int64          `json:"next_spawn_check_tick"`
+	Other              map[string]any `json:"other,omitempty"`
+}
+
+// BiomeState is the per-world state of one biome, from the BiomeData
+// record.
+type BiomeState struct {
+	ID               int32   `json:"id"`
+	Name             string  `json:"name,omitempty"`
+	SnowAccumulation float32 `json:"snow_accumulation"`
+}
+
+// ChunkMetaData is one entry of the LevelChunkMetaDataDictionary
+// record. Chunks refer to an entry by its hash, in their MetaDataHash
+// record.
+type ChunkMetaData struct {
+	Hash string         `json:"hash"` // uint64, in hex
+	Data map[string]any `json:"data"`
+}
+
+// DimensionData is an Overworld, Nether or TheEnd record.
+type DimensionData struct {
+	// Entities that left the loaded area of the dimension, such as
+	// tamed pets following a player through a portal.
+	LimboEntities []ObjectSummary `json:"limbo_entities"`
+	DragonFight   *DragonFight    `json:"dragon_fight,omitempty"` // TheEnd only
+	Other         map[string]any  `json:"other,omitempty"`
+}
+
+// DragonFight is the state of the ender dragon fight.
+type DragonFight struct {
+	DragonKilled       bool           `json:"dragon_killed"`
+	PreviouslyKilled   bool           `json:"previously_killed"`
+	DragonSpawned      bool           `json:"dragon_spawned"`
+	IsRespawning       bool           `json:"is_respawning"`
+	DragonUUID         int64          `json:"dragon_uuid,omitempty"`
+	ExitPortalLocation []int32        `json:"exit_portal_location,omitempty"`
+	Gateways           []int32        `json:"gateways,omitempty"`
+	Other              map[string]any `json:"other,omitempty"`
+}
+
+// DecodeGlobal decodes one of the world-wide records into named
+// fields:
+//   - scoreboard as a Scoreboard;
+//   - mobevents as a MobEvents;
+//   - schedulerWT as a WanderingTraderScheduler;
+//   - AutonomousEntities as a slice of ObjectSummary;
+//   - BiomeData as a slice of BiomeState;
+//   - LevelChunkMetaDataDictionary as a slice of ChunkMetaData;
+//   - Overworld, Nether and TheEnd as a DimensionData.
+//
+// Fields it doesn't know are kept in the result's Other field, where
+// it has one.
+func DecodeGlobal(kv *KeyVal) (any, error) {
+	kt := kv.KeyType()
+	if kt == KeyTypeLevelChunkMetaDataDictionary {
+		return ParseChunkMetaDataDictionary(kv.Val)
+	}
+	if !slices.Contains(globalKeys, string(kv.Key)) {
+		return nil, fmt.Errorf("%q is not a global record", kv.Key)
+	}
+	records, err := DecodeNBTRecords(kv.Val)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != 1 {
+		return nil, fmt.Errorf("want one NBT compound for %s; got %d", kt, len(records))
+	}
+	data := records[0].Data
+
+	switch kt {
+	case KeyTypeScoreboard:
+		return parseScoreboard(data), nil
+	case KeyTypeMobevents:
+		res := MobEvents{Events: make(map[string]bool)}
+		for k, v := range data {
+			b, _ := v.(uint8)
+			if k == "events_enabled" {
+				res.EventsEnabled = b != 0
+			} else {
+				res.Events[k] = b != 0
+			}
+		}
+		return res, nil
+	case KeyTypeSchedulerWT:
+		res := WanderingTraderScheduler{Other: otherFields(data, "daysSinceLastWTSpawn", "isSpawningWT", "nextWTSpawnCheckTick")}
+		res.DaysSinceLastSpawn, _ = data["daysSinceLastWTSpawn"].(int32)
+		res.IsSpawning = nbtBool(data["isSpawningWT"])
+		res.NextSpawnCheckTick, _ = data["nextWTSpawnCheckTick"].(int64)
+		return res, nil
+	case KeyTypeAutonomousEntities:
+		return summarizeEntities(data["AutonomousEntityList"]), nil
+	case KeyTypeBiomeData:
+		res := []BiomeState{}
+		for _, m := range compounds(data["list"]) {
+			id, _ := nbtInt(m["id"])
+			bs := BiomeState{ID: int32(id)}
+			bs.SnowAccumulation, _ = m["snowAccumulation"].(float32)
+			if b, ok := world.BiomeByID(int(id)); ok {
+				bs.Name = b.String()
+			}
+			res = append(res, bs)
+		}
+		return res, nil
+	case KeyTypeOverworld, KeyTypeNether, KeyTypeTheEnd:
+		return parseDimensionData(data), nil
+	}
+	return nil, fmt.Errorf("no decoder for %s", kt)
+}
+
+// GlobalRecords returns every global record in the world, decoded by
+// DecodeGlobal, by key. Records that fail to decode are returned as
+// errors alongside the others.
+func GlobalRecords(db *leveldb.DB) (map[string]any, []error) {
+	res := make(map[string]any)
+	var errs []error
+	for _, key := range globalKeys {
+		val, err := db.Get([]byte(key), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, append(errs, err)
+		}
+		decoded, err := DecodeGlobal(NewKeyVal([]byte(key), val))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		res[key] = decoded
+	}
+	return res, errs
+}
+
+func parseScoreboard(data map[string]any) Scoreboard {
+	res := Scoreboard{
+		Objectives:        []Objective{},
+		DisplayObjectives: []DisplayObjective{},
+		Entries:           []ScoreboardEntry{},
+		Other:             otherFields(data, "Objectives", "DisplayObjectives", "Entries", "LastUniqueID"),
+	}
+	res.LastUniqueID, _ = data["LastUniqueID"].(int64)
+
+	holders := make(map[int64]string)
+	for _, m := range compounds(data["Entries"]) {
+		e := ScoreboardEntry{}
+		e.ScoreboardID, _ = m["ScoreboardId"].(int64)
+		identityType, _ := m["IdentityType"].(uint8)
+		e.IdentityType = scoreboardIdentityTypes[identityType]
+		if e.IdentityType == "" {
+			e.IdentityType = fmt.Sprint(identityType)
+		}
+		e.PlayerID, _ = m["PlayerId"].(int64)
+		e.EntityID, _ = m["EntityID"].(int64)
+		e.FakePlayerName, _ = m["FakePlayerName"].(string)
+		res.Entries = append(res.Entries, e)
+		holders[e.ScoreboardID] = e.Holder()
+	}
+
+	for _, m := range compounds(data["Objectives"]) {
+		o := Objective{Scores: []Score{}}
+		o.Name, _ = m["Name"].(string)
+		o.DisplayName, _ = m["DisplayName"].(string)
+		o.Criteria, _ = m["Criteria"].(string)
+		for _, s := range compounds(m["Scores"]) {
+			score := Score{}
+			score.ScoreboardID, _ = s["ScoreboardId"].(int64)
+			score.Score, _ = s["Score"].(int32)
+			score.Holder = holders[score.ScoreboardID]
+			o.Scores = append(o.Scores, score)
+		}
+		res.Objectives = append(res.Objectives, o)
+	}
+
+	for _, m := range compounds(data["DisplayObjectives"]) {
+		d := DisplayObjective{SortOrder: "ascending"}
+		d.Slot, _ = m["Name"].(string)
+		d.ObjectiveName, _ = m["ObjectiveName"].(string)
+		if order, _ := m["SortOrder"].(uint8); order == 1 {
+			d.SortOrder = "descending"
+		}
+		res.DisplayObjectives = append(res.DisplayObjectives, d)
+	}
+	return res
+}
+
+func parseDimensionData(data map[string]any) DimensionData {
+	// The fields are usually wrapped in a "data" compound.
+	if inner, ok := data["data"].(map[string]any); ok && len(data) == 1 {
+		data = inner
+	}
+	res := DimensionData{
+		LimboEntities: summarizeEntities(data["LimboEntities"]),
+		Other:         otherFields(data, "LimboEntities", "DragonFight"),
+	}
+	if m, ok := data["DragonFight"].(map[string]any); ok {
+		df := &DragonFight{
+			DragonKilled:       nbtBool(m["DragonKilled"]),
+			PreviouslyKilled:   nbtBool(m["PreviouslyKilled"]),
+			DragonSpawned:      nbtBool(m["DragonSpawned"]),
+			IsRespawning:       nbtBool(m["IsRespawning"]),
+			ExitPortalLocation: nbtInts(m["ExitPortalLocation"]),
+			Gateways:           nbtInts(m["Gateways"]),
+			Other:              otherFields(m, "DragonKilled", "PreviouslyKilled", "DragonSpawned", "IsRespawning", "DragonUUID", "ExitPortalLocation", "Gateways"),
+		}
+		df.DragonUUID, _ = m["DragonUUID"].(int64)
+		res.DragonFight = df
+	}
+	return res
+}
+
+// ParseChunkMetaDataDictionary decodes the LevelChunkMetaDataDictionary
+// record: a uint32 count, then that many entries of a uint64 hash and
+// an NBT compound.
+func ParseChunkMetaDataDictionary(val []byte) ([]ChunkMetaData, error) {
+	buf := bytes.NewBuffer(val)
+	count, err := readUint32AsInt(buf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read metadata count: %w", err)
+	}
+	d := nbt.NewDecoderWithEncoding(buf, nbt.LittleEndian)
+	res := []ChunkMetaData{}
+	for i := range count {
+		var hash uint64
+		if err := binary.Read(buf, binary.LittleEndian, &hash); err != nil {
+			return nil, fmt.Errorf("unable to read hash of metadata %d: %w", i, err)
+		}
+		var m map[string]any
+		if err := d.Decode(&m); err != nil {
+			return nil, fmt.Errorf("unable to decode metadata %d: %w", i, err)
+		}
+		res = append(res, ChunkMetaData{Hash: fmt.Sprintf("%016x", hash), Data: m})
+	}
+	if buf.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes left over after %d metadata entries", buf.Len(), count)
+	}
+	return res, nil
+}
+
+// compounds returns the compounds in an NBT list, skipping anything
+// else.
+func compounds(v any) []map[string]any {
+	list, _ := v.([]any)
+	var res []map[string]any
+	for _, entry := range list {
+		if m, ok := entry.(map[string]any); ok {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// summarizeEntities summarizes the entity compounds in an NBT list.
+func summarizeEntities(v any) []ObjectSummary {
+	res := []ObjectSummary{}
+	for _, m := range compounds(v) {
+		res = append(res, summarizeEntity(m))
+	}
+	return res
+}
+
+// otherFields returns the fields of data not in known, or nil if there
+// are none.
+func otherFields(data map[string]any, known ...string) map[string]any {
+	res := maps.Clone(data)
+	for _, k := range known {
+		delete(res, k)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// nbtBool returns whether a TAG_Byte is non-zero.
+func nbtBool(v any) bool {
+	b, _ := v.(uint8)
+	return b != 0
+}
+
+// nbtInt returns an integer tag of any size as an int64.
+func nbtInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case uint8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+// nbtInts returns a TAG_Int_Array, or a list of TAG_Int, as a slice.
+func nbtInts(v any) []int32 {
+	var res []int32
+	switch list := v.(type) {
+	case []int32:
+		// A list of TAG_Int decodes as []int32.
+		return slices.Clone(list)
+	case []any:
+		for _, entry := range list {
+			if n, ok := entry.(int32); ok {
+				res = append(res, n)
+			}
+		}
+		return res
+	}
+	// TAG_Int_Array decodes as a fixed-size array.
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Int32 {
+		for i := range rv.Len() {
+			res = append(res, int32(rv.Index(i).Int()))
+		}
+	}
+	return res
+}
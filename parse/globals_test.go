@@ -0,0 +1,144 @@
+package parse
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func marshalNBT(t *testing.T, data map[string]any) []byte {
+	t.Helper()
+	val, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestDecodeScoreboard(t *testing.T) {
+	val := marshalNBT(t, map[string]any{
+		"Objectives": []any{
+			map[string]any{
+				"Name": "kills", "DisplayName": "Kills", "Criteria": "dummy",
+				"Scores": []any{
+					map[string]any{"ScoreboardId": int64(1), "Score": int32(7)},
+					map[string]any{"ScoreboardId": int64(2), "Score": int32(3)},
+				},
+			},
+		},
+		"DisplayObjectives": []any{
+			map[string]any{"Name": "sidebar", "ObjectiveName": "kills", "SortOrder": uint8(1)},
+		},
+		"Entries": []any{
+			map[string]any{"ScoreboardId": int64(1), "IdentityType": uint8(1), "PlayerId": int64(-4294967295)},
+			map[string]any{"ScoreboardId": int64(2), "IdentityType": uint8(3), "FakePlayerName": "Bob"},
+		},
+		"LastUniqueID": int64(2),
+	})
+	got, err := DecodeGlobal(NewKeyVal([]byte("scoreboard"), val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb, ok := got.(Scoreboard)
+	if !ok {
+		t.Fatalf("want a Scoreboard; got %T", got)
+	}
+	wantScores := []Score{
+		{ScoreboardID: 1, Holder: "player -4294967295", Score: 7},
+		{ScoreboardID: 2, Holder: "Bob", Score: 3},
+	}
+	if len(sb.Objectives) != 1 || !reflect.DeepEqual(sb.Objectives[0].Scores, wantScores) {
+		t.Errorf("want one objective with scores %v; got %+v", wantScores, sb.Objectives)
+	}
+	wantDisplay := []DisplayObjective{{Slot: "sidebar", ObjectiveName: "kills", SortOrder: "descending"}}
+	if !reflect.DeepEqual(sb.DisplayObjectives, wantDisplay) {
+		t.Errorf("want display objectives %v; got %v", wantDisplay, sb.DisplayObjectives)
+	}
+	if sb.LastUniqueID != 2 || sb.Other != nil {
+		t.Errorf("want last unique ID 2 and no other fields; got %d, %v", sb.LastUniqueID, sb.Other)
+	}
+}
+
+func TestDecodeDimensionData(t *testing.T) {
+	val := marshalNBT(t, map[string]any{
+		"data": map[string]any{
+			"LimboEntities": []any{
+				map[string]any{"identifier": "minecraft:wolf", "UniqueID": int64(5)},
+			},
+			"DragonFight": map[string]any{
+				"DragonKilled":       uint8(1),
+				"PreviouslyKilled":   uint8(1),
+				"ExitPortalLocation": [3]int32{0, 64, 0},
+			},
+			"mystery": int32(3),
+		},
+	})
+	got, err := DecodeGlobal(NewKeyVal([]byte("TheEnd"), val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dd, ok := got.(DimensionData)
+	if !ok {
+		t.Fatalf("want a DimensionData; got %T", got)
+	}
+	if len(dd.LimboEntities) != 1 || dd.LimboEntities[0].ID != "minecraft:wolf" {
+		t.Errorf("want one limbo wolf; got %+v", dd.LimboEntities)
+	}
+	if dd.DragonFight == nil || !dd.DragonFight.DragonKilled || !reflect.DeepEqual(dd.DragonFight.ExitPortalLocation, []int32{0, 64, 0}) {
+		t.Errorf("got dragon fight %+v", dd.DragonFight)
+	}
+	if want := map[string]any{"mystery": int32(3)}; !reflect.DeepEqual(dd.Other, want) {
+		t.Errorf("want other fields %v; got %v", want, dd.Other)
+	}
+}
+
+// TestDecodeDragonFightLists checks the DragonFight fields that
+// Bedrock writes as a list of TAG_Int rather than a TAG_Int_Array.
+func TestDecodeDragonFightLists(t *testing.T) {
+	val := marshalNBT(t, map[string]any{
+		"DragonFight": map[string]any{
+			"ExitPortalLocation": []int32{0, 64, 0},
+			"Gateways":           []int32{96, 0, -96},
+		},
+	})
+	var m map[string]any
+	if err := nbt.UnmarshalEncoding(val, &m, nbt.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	if got := m["DragonFight"].(map[string]any)["Gateways"]; reflect.TypeOf(got) != reflect.TypeOf([]int32{}) {
+		t.Fatalf("want Gateways to decode as []int32; got %T", got)
+	}
+	got, err := DecodeGlobal(NewKeyVal([]byte("TheEnd"), val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	df := got.(DimensionData).DragonFight
+	if df == nil {
+		t.Fatal("want a dragon fight")
+	}
+	if want := []int32{0, 64, 0}; !reflect.DeepEqual(df.ExitPortalLocation, want) {
+		t.Errorf("want exit portal location %v; got %v", want, df.ExitPortalLocation)
+	}
+	if want := []int32{96, 0, -96}; !reflect.DeepEqual(df.Gateways, want) {
+		t.Errorf("want gateways %v; got %v", want, df.Gateways)
+	}
+}
+
+func TestParseChunkMetaDataDictionary(t *testing.T) {
+	val := binary.LittleEndian.AppendUint32(nil, 1)
+	val = binary.LittleEndian.AppendUint64(val, 0x0123456789abcdef)
+	val = append(val, marshalNBT(t, map[string]any{"LastSavedBaseGameVersion": "1.21.0"})...)
+	got, err := ParseChunkMetaDataDictionary(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChunkMetaData{{Hash: "0123456789abcdef", Data: map[string]any{"LastSavedBaseGameVersion": "1.21.0"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+	if _, err := ParseChunkMetaDataDictionary(append(val, 0)); err == nil {
+		t.Errorf("want an error for trailing bytes")
+	}
+}
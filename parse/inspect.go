@@ -0,0 +1,270 @@
+package parse
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+)
+
+// KeyFilter selects keys for ListKeys. Zero fields match every key.
+type KeyFilter struct {
+	Types  []KeyType // Only keys of these types
+	Prefix []byte    // Only keys with this prefix
+	// Only keys of this chunk. Dimension must be set too, since a
+	// chunk prefix in the overworld is also the start of the other
+	// dimensions' keys for the same position.
+	Chunk     *world.ChunkPos
+	Dimension world.Dimension
+	Limit     int // At most this many keys, if positive
+}
+
+// Matches reports whether the key passes the filter.
+func (f KeyFilter) Matches(kv *KeyVal) bool {
+	keyInfo := kv.KeyTypeAndChunkLocation()
+	if f.Types != nil && !slices.Contains(f.Types, keyInfo.KeyType) {
+		return false
+	}
+	if len(f.Prefix) > 0 && (len(kv.Key) < len(f.Prefix) || string(kv.Key[:len(f.Prefix)]) != string(f.Prefix)) {
+		return false
+	}
+	if f.Chunk != nil && (!keyInfo.HasLocation || keyInfo.ChunkPos != *f.Chunk || keyInfo.Dimension != f.Dimension) {
+		return false
+	}
+	return true
+}
+
+// ListKeys returns the keys and values in db that pass the filter, in
+// key order.
+func ListKeys(db *leveldb.DB, filter KeyFilter) ([]*KeyVal, error) {
+	prefix := filter.Prefix
+	if filter.Chunk != nil && len(prefix) == 0 {
+		prefix = MakeChunkPrefix(*filter.Chunk, filter.Dimension)
+	}
+	var res []*KeyVal
+	iter := db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &KeyVal{Key: iter.Key(), Val: iter.Value()}
+		if !filter.Matches(kv) {
+			continue
+		}
+		res = append(res, NewKeyVal(iter.Key(), iter.Value()))
+		if filter.Limit > 0 && len(res) >= filter.Limit {
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// nbtKeyTypes are the key types whose values are little-endian NBT
+// compounds.
+var nbtKeyTypes = map[KeyType]bool{
+	KeyTypePlayerServer:             true,
+	KeyTypePlayer:                   true,
+	KeyTypeLocalPlayer:              true,
+	KeyTypeMap:                      true,
+	KeyTypeVillageDwellers:          true,
+	KeyTypeVillageInfo:              true,
+	KeyTypeVillagePlayers:           true,
+	KeyTypeVillagePOI:               true,
+	KeyTypeVillageOverworldDwellers: true,
+	KeyTypeVillageOverworldInfo:     true,
+	KeyTypeVillageOverworldPlayers:  true,
+	KeyTypeVillageOverworldPOI:      true,
+	KeyTypeAutonomousEntities:       true,
+	KeyTypeBiomeData:                true,
+	KeyTypeNether:                   true,
+	KeyTypeOverworld:                true,
+	KeyTypeTheEnd:                   true,
+	KeyTypeMobevents:                true,
+	KeyTypePortals:                  true,
+	KeyTypeSchedulerWT:              true,
+	KeyTypeScoreboard:               true,
+	KeyTypeActorprefix:              true,
+	KeyTypeStructureTemplate:        true,
+	KeyTypeTickingArea:              true,
+}
+
+// nbtListChunkTags are the chunk record tags whose values hold any
+// number of NBT compounds back to back.
+var nbtListChunkTags = map[LevelChunkTag]bool{
+	LevelChunkTagBlockEntity:  true,
+	LevelChunkTagEntity:       true,
+	LevelChunkTagPendingTicks: true,
+	LevelChunkTagRandomTicks:  true,
+}
+
+// IsNBT reports whether values of this key type are NBT. For most
+// such types the value is one compound; for block entities, entities
+// and ticks it's a list of them (see IsNBTList).
+func (kt KeyType) IsNBT() bool {
+	return nbtKeyTypes[kt] || kt.IsNBTList()
+}
+
+// IsNBTList reports whether values of this key type are any number of
+// NBT compounds back to back.
+func (kt KeyType) IsNBTList() bool {
+	return kt.IsChunkData() && nbtListChunkTags[kt.LevelChunkTag()]
+}
+
+// DecodedSubChunk is a sub-chunk decoded by DecodeValue.
+type DecodedSubChunk struct {
+	Version int                    `json:"version"`
+	YIndex  int32                  `json:"y_index"`
+	Layers  []DecodedSubChunkLayer `json:"layers"`
+}
+
+// DecodedSubChunkLayer is one storage layer of a DecodedSubChunk:
+// its palette, and how many of its 4096 blocks use each entry.
+type DecodedSubChunkLayer struct {
+	BitsPerBlock int              `json:"bits_per_block"`
+	Palette      []map[string]any `json:"palette"`
+	Counts       []int            `json:"counts"`
+}
+
+func decodeSubChunk(sc SubChunk) DecodedSubChunk {
+	res := DecodedSubChunk{Version: sc.subChunkVersion, YIndex: sc.yIndex, Layers: []DecodedSubChunkLayer{}}
+	for _, layer := range sc.layers {
+		counts := make([]int, len(layer.palettes))
+		for _, index := range layer.blockEntries {
+			counts[index]++
+		}
+		res.Layers = append(res.Layers, DecodedSubChunkLayer{
+			BitsPerBlock: layer.bitsPerBlock,
+			Palette:      layer.palettes,
+			Counts:       counts,
+		})
+	}
+	return res
+}
+
+// DecodedActorID is one actor ID from an actor digest (digp) value.
+type DecodedActorID struct {
+	ID  int64  `json:"id"`
+	Key string `json:"key"` // The actor's actorprefix key, in hex
+}
+
+// DecodedChecksum is one entry of a chunk's CheckSums record.
+type DecodedChecksum struct {
+	Tag    string `json:"tag"`
+	YIndex *int8  `json:"y_index,omitempty"` // For sub-chunks
+	Hash   string `json:"hash"`              // In hex
+}
+
+// DecodedHex is the value of a key DecodeValue doesn't know how to
+// decode.
+type DecodedHex struct {
+	Hex string `json:"hex"`
+}
+
+// DecodeValue decodes the value of a key into something that can be
+// marshalled as JSON:
+//   - NBT values (see IsNBT) as a map[string]any, or a slice of them
+//     for IsNBTList types;
+//   - sub-chunks as a DecodedSubChunk;
+//   - actor digests as a slice of DecodedActorID;
+//   - LevelChunkMetaDataDictionary as a slice of ChunkMetaData, and
+//     chunks' MetaDataHash records as the hash in hex;
+//   - Data3D and Data2D records as their heightmap, [z][x];
+//   - CheckSums records as a slice of DecodedChecksum;
+//   - Version, LegacyVersion and ActorDigestVersion as a byte, and
+//     FinalizedState as an int32;
+//   - anything else, including values that fail to decode, as
+//     DecodedHex.
+func DecodeValue(kv *KeyVal) (any, error) {
+	kt := kv.KeyType()
+	switch {
+	case kt.IsNBT():
+		records, err := DecodeNBTRecords(kv.Val)
+		if err != nil {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, err
+		}
+		if kt.IsNBTList() {
+			res := []map[string]any{}
+			for _, rec := range records {
+				res = append(res, rec.Data)
+			}
+			return res, nil
+		}
+		if len(records) != 1 {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, fmt.Errorf("want one NBT compound for %s; got %d", kt, len(records))
+		}
+		return records[0].Data, nil
+
+	case kt.IsSubChunkPrefix():
+		sc, err := ParseSubChunk(kv)
+		if err != nil {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, err
+		}
+		return decodeSubChunk(sc), nil
+
+	case kt == KeyTypeDigp:
+		ids, err := ParseDigp(kv.Val)
+		if err != nil {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, err
+		}
+		res := []DecodedActorID{}
+		for _, id := range ids {
+			res = append(res, DecodedActorID{
+				ID:  int64(binary.LittleEndian.Uint64(id)),
+				Key: hex.EncodeToString(MakeActorKey(id)),
+			})
+		}
+		return res, nil
+
+	case kt == KeyTypeLevelChunkMetaDataDictionary:
+		res, err := ParseChunkMetaDataDictionary(kv.Val)
+		if err != nil {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, err
+		}
+		return res, nil
+	}
+
+	if !kt.IsChunkData() {
+		return DecodedHex{hex.EncodeToString(kv.Val)}, nil
+	}
+	switch kt.LevelChunkTag() {
+	case LevelChunkTagData3D, LevelChunkTagData2D:
+		hm, err := StoredHeightMap(kv.Val, kv.KeyTypeAndChunkLocation().Dimension)
+		if err != nil {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, err
+		}
+		return hm, nil
+	case LevelChunkTagVersion, LevelChunkTagLegacyVersion, LevelChunkTagActorDigestVersion:
+		if len(kv.Val) == 1 {
+			return kv.Val[0], nil
+		}
+	case LevelChunkTagFinalizedState:
+		if len(kv.Val) == 4 {
+			return int32(binary.LittleEndian.Uint32(kv.Val)), nil
+		}
+	case LevelChunkTagCheckSums:
+		checksums, err := ParseCheckSums(kv.Val)
+		if err != nil {
+			return DecodedHex{hex.EncodeToString(kv.Val)}, err
+		}
+		res := []DecodedChecksum{}
+		for _, c := range checksums {
+			d := DecodedChecksum{Tag: strings.TrimPrefix(c.Tag.String(), "LevelChunkTag"), Hash: fmt.Sprintf("%016x", c.Hash)}
+			if c.Tag == LevelChunkTagSubChunkPrefix {
+				d.YIndex = &c.YIndex
+			}
+			res = append(res, d)
+		}
+		return res, nil
+	case LevelChunkTagMetaDataHash:
+		if len(kv.Val) == 8 {
+			return fmt.Sprintf("%016x", binary.LittleEndian.Uint64(kv.Val)), nil
+		}
+	}
+	return DecodedHex{hex.EncodeToString(kv.Val)}, nil
+}
@@ -0,0 +1,90 @@
+package parse
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestEncodeEditedNBT(t *testing.T) {
+	orig := map[string]any{
+		"Count":   uint8(3),
+		"Damage":  int16(-2),
+		"Name":    "minecraft:stone",
+		"Pos":     []any{float32(1.5), float32(64), float32(-2.5)},
+		"Time":    int64(1234567890123),
+		"Colors":  [3]int32{1, 2, 3},
+		"Flags":   []any{uint8(1), uint8(0), uint8(1)},
+		"Levels":  []any{uint8(4), uint8(5)},
+		"Nested":  map[string]any{"id": int32(7)},
+		"Unknown": float64(0.25),
+	}
+	val, err := nbt.MarshalEncoding(orig, nbt.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv := NewKeyVal([]byte("player_server_1234"), val)
+	decoded, err := DecodeValue(kv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(edited, &m); err != nil {
+		t.Fatal(err)
+	}
+	m["Count"] = 5
+	m["Added"] = 9
+	// A list of bytes comes out as base64; it can go back as that or as
+	// a list of numbers.
+	if _, ok := m["Flags"].(string); !ok {
+		t.Errorf("want a base64 string for a list of bytes; got %#v", m["Flags"])
+	}
+	m["Levels"] = []any{4, 6}
+	edited, err = json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newVal, err := EncodeEditedNBT(kv.KeyType(), val, edited)
+	if err != nil {
+		t.Fatalf("EncodeEditedNBT: %v", err)
+	}
+	var got map[string]any
+	if err := nbt.UnmarshalEncoding(newVal, &got, nbt.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	want := orig
+	want["Count"] = uint8(5)
+	want["Added"] = int32(9)
+	want["Flags"] = []byte{1, 0, 1}
+	want["Levels"] = []byte{4, 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v; got %#v", want, got)
+	}
+}
+
+func TestFormatSNBT(t *testing.T) {
+	v := map[string]any{
+		"b":     uint8(1),
+		"id":    "minecraft:pig",
+		"Pos":   []any{float32(1.5), float32(2)},
+		"a b":   int64(3),
+		"Array": [2]int32{4, 5},
+	}
+	want := `{
+  Array: [I; 4, 5],
+  Pos: [1.5f, 2f],
+  "a b": 3L,
+  b: 1b,
+  id: "minecraft:pig"
+}`
+	if got := FormatSNBT(v); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -124,6 +124,8 @@ var keyStringUniquePrefixesAndSuffixes = []struct {
 	{"player_", "", KeyTypePlayer},
 	{"actorprefix", "", KeyTypeActorprefix},
 	{"digp", "", KeyTypeDigp},
+	{"tickingarea_", "", KeyTypeTickingArea},
+	{"structuretemplate_", "", KeyTypeStructureTemplate},
 
 	{"VILLAGE_Overworld_", "_DWELLERS", KeyTypeVillageOverworldDwellers},
 	{"VILLAGE_Overworld_", "_INFO", KeyTypeVillageOverworldInfo},
@@ -0,0 +1,151 @@
+package parse
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"slices"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+)
+
+// MapItem is an in-game map, stored under a map_-<id> key.
+type MapItem struct {
+	Key       string `json:"key"`
+	ID        int64  `json:"id"`
+	ParentID  int64  `json:"parent_id"`
+	Dimension string `json:"dimension"`
+	Scale     int    `json:"scale"` // Each map pixel covers 2^Scale blocks square
+	XCenter   int32  `json:"x_center"`
+	ZCenter   int32  `json:"z_center"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Locked    bool   `json:"locked,omitempty"`
+
+	dimension world.Dimension
+	colors    []byte // RGBA, Width*Height*4 bytes
+}
+
+// mapKeyPrefix is the prefix of map item keys.
+const mapKeyPrefix = "map_-"
+
+// ParseMap decodes a map_-<id> record.
+func ParseMap(kv *KeyVal) (MapItem, error) {
+	if kv.KeyType() != KeyTypeMap {
+		return MapItem{}, fmt.Errorf("can't parse a map from a %s key", kv.KeyType())
+	}
+	records, err := DecodeNBTRecords(kv.Val)
+	if err != nil {
+		return MapItem{}, err
+	}
+	if len(records) != 1 {
+		return MapItem{}, fmt.Errorf("want one NBT compound for map %q; got %d", kv.Key, len(records))
+	}
+	data := records[0].Data
+
+	m := MapItem{Key: string(kv.Key)}
+	m.ID, _ = data["mapId"].(int64)
+	m.ParentID, _ = data["parentMapId"].(int64)
+	m.XCenter, _ = data["xCenter"].(int32)
+	m.ZCenter, _ = data["zCenter"].(int32)
+	scale, _ := data["scale"].(uint8)
+	m.Scale = int(scale)
+	width, _ := data["width"].(int16)
+	height, _ := data["height"].(int16)
+	m.Width, m.Height = int(width), int(height)
+	locked, _ := data["mapLocked"].(uint8)
+	m.Locked = locked != 0
+
+	dimensionID, _ := data["dimension"].(uint8)
+	dimension, ok := world.DimensionByID(int(dimensionID))
+	if !ok {
+		return MapItem{}, fmt.Errorf("map %q has unknown dimension %d", kv.Key, dimensionID)
+	}
+	m.dimension = dimension
+	m.Dimension = fmt.Sprint(dimension)
+
+	// TAG_ByteArray decodes as a fixed-size byte array.
+	colors := reflect.ValueOf(data["colors"])
+	if colors.Kind() != reflect.Array || colors.Type().Elem().Kind() != reflect.Uint8 {
+		return MapItem{}, fmt.Errorf("map %q has no colors", kv.Key)
+	}
+	m.colors = make([]byte, colors.Len())
+	reflect.Copy(reflect.ValueOf(m.colors), colors)
+	if len(m.colors) != m.Width*m.Height*4 {
+		return MapItem{}, fmt.Errorf("map %q is %dx%d, but has %d bytes of colors", kv.Key, m.Width, m.Height, len(m.colors))
+	}
+	return m, nil
+}
+
+// BlocksPerPixel is the width and height in blocks of each map pixel.
+func (m MapItem) BlocksPerPixel() int {
+	return 1 << m.Scale
+}
+
+// Bounds returns the area of the world the map covers, as block x
+// (image x) and block z (image y) coordinates.
+func (m MapItem) Bounds() image.Rectangle {
+	bpp := m.BlocksPerPixel()
+	minX := int(m.XCenter) - m.Width*bpp/2
+	minZ := int(m.ZCenter) - m.Height*bpp/2
+	return image.Rect(minX, minZ, minX+m.Width*bpp, minZ+m.Height*bpp)
+}
+
+// WorldDimension returns the dimension the map is of.
+func (m MapItem) WorldDimension() world.Dimension {
+	return m.dimension
+}
+
+// CenterChunk returns the chunk the centre of the map is in.
+func (m MapItem) CenterChunk() world.ChunkPos {
+	return world.ChunkPos{m.XCenter >> 4, m.ZCenter >> 4}
+}
+
+// Image returns the map's pixels. Unexplored pixels are transparent.
+func (m MapItem) Image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, m.Width, m.Height))
+	copy(img.Pix, m.colors)
+	return img
+}
+
+// ColorAt returns the map's color for the block column at x, z, and
+// false if the map doesn't cover it or that part is unexplored.
+func (m MapItem) ColorAt(x, z int) (color.NRGBA, bool) {
+	bounds := m.Bounds()
+	if !image.Pt(x, z).In(bounds) {
+		return color.NRGBA{}, false
+	}
+	bpp := m.BlocksPerPixel()
+	px, pz := (x-bounds.Min.X)/bpp, (z-bounds.Min.Y)/bpp
+	i := (pz*m.Width + px) * 4
+	c := color.NRGBA{R: m.colors[i], G: m.colors[i+1], B: m.colors[i+2], A: m.colors[i+3]}
+	return c, c.A != 0
+}
+
+// ReadMaps returns every map item in the world, sorted by ID. Maps
+// that fail to decode are returned as errors alongside the others.
+func ReadMaps(db *leveldb.DB) ([]MapItem, []error) {
+	var res []MapItem
+	var errs []error
+	iter := db.NewIterator(util.BytesPrefix([]byte(mapKeyPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		m, err := ParseMap(&KeyVal{Key: iter.Key(), Val: iter.Value()})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res = append(res, m)
+	}
+	if err := iter.Error(); err != nil {
+		errs = append(errs, err)
+	}
+	slices.SortFunc(res, func(a, b MapItem) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return res, errs
+}
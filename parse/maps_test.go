@@ -0,0 +1,67 @@
+package parse
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestParseMap(t *testing.T) {
+	var colors [128 * 128 * 4]byte
+	// Pixel (1, 2) is explored and red.
+	i := (2*128 + 1) * 4
+	copy(colors[i:], []byte{0xFF, 0, 0, 0xFF})
+	data := map[string]any{
+		"mapId":       int64(-5),
+		"parentMapId": int64(-1),
+		"dimension":   uint8(0),
+		"scale":       uint8(1),
+		"width":       int16(128),
+		"height":      int16(128),
+		"xCenter":     int32(64),
+		"zCenter":     int32(-64),
+		"mapLocked":   uint8(0),
+		"colors":      colors,
+	}
+	val, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseMap(NewKeyVal([]byte("map_--5"), val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != -5 || m.WorldDimension() != world.Overworld || m.BlocksPerPixel() != 2 {
+		t.Errorf("got ID %d, dimension %v, %d blocks per pixel", m.ID, m.WorldDimension(), m.BlocksPerPixel())
+	}
+	if want := image.Rect(-64, -192, 192, 64); m.Bounds() != want {
+		t.Errorf("want bounds %v; got %v", want, m.Bounds())
+	}
+	if want := (world.ChunkPos{4, -4}); m.CenterChunk() != want {
+		t.Errorf("want centre chunk %v; got %v", want, m.CenterChunk())
+	}
+
+	// Pixel (1, 2) covers blocks x -62..-61, z -188..-187.
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	for _, tt := range []struct {
+		x, z int
+		ok   bool
+	}{
+		{-62, -188, true},
+		{-61, -187, true},
+		{-63, -188, false},
+		{-62, -186, false},
+		{1000, 0, false},
+	} {
+		c, ok := m.ColorAt(tt.x, tt.z)
+		if ok != tt.ok || (ok && c != red) {
+			t.Errorf("ColorAt(%d, %d) = %v, %v; want ok=%v", tt.x, tt.z, c, ok, tt.ok)
+		}
+	}
+	if got := m.Image().NRGBAAt(1, 2); got != red {
+		t.Errorf("want image pixel (1, 2) %v; got %v", red, got)
+	}
+}
@@ -0,0 +1,97 @@
+package parse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// blockCoordFields lists the pairs of int fields that hold the x and z
+// of a block position, in block entities, entities and tick lists.
+// "x" and "z" only count alongside a "y".
+var blockCoordFields = [][2]string{
+	{"x", "z"},
+	{"pairx", "pairz"},           // Paired chests
+	{"pistonPosX", "pistonPosZ"}, // Moving blocks
+	{"BoundX", "BoundZ"},         // Vexes
+	{"HomeX", "HomeZ"},           // Turtles
+	{"TargetX", "TargetZ"},       // Dolphins, looking for treasure
+	{"SpawnBlockPositionX", "SpawnBlockPositionZ"},
+	{"LodestonePosX", "LodestonePosZ"},
+}
+
+// ShiftNBTPositions moves every block or entity position in decoded
+// NBT by dx and dz blocks, in place, including those nested in lists
+// and compounds, like the entries of a tick list, or an entity's
+// passengers. It returns the number of positions moved.
+func ShiftNBTPositions(data any, dx, dz int) int {
+	switch v := data.(type) {
+	case map[string]any:
+		count := 0
+		for _, fields := range blockCoordFields {
+			if fields[0] == "x" {
+				if _, ok := NBTBlockPos(v); !ok {
+					continue
+				}
+			}
+			x, okX := v[fields[0]].(int32)
+			z, okZ := v[fields[1]].(int32)
+			if okX && okZ {
+				v[fields[0]], v[fields[1]] = x+int32(dx), z+int32(dz)
+				count++
+			}
+		}
+		if pos, ok := v["Pos"].([]any); ok && len(pos) == 3 {
+			x, okX := pos[0].(float32)
+			z, okZ := pos[2].(float32)
+			if okX && okZ {
+				v["Pos"] = []any{x + float32(dx), pos[1], z + float32(dz)}
+				count++
+			}
+		}
+		// End gateways store their exit as a list of three ints.
+		if exit, ok := v["ExitPortal"].([]int32); ok && len(exit) == 3 {
+			v["ExitPortal"] = []int32{exit[0] + int32(dx), exit[1], exit[2] + int32(dz)}
+			count++
+		}
+		for key, child := range v {
+			if key != "Pos" && key != "ExitPortal" {
+				count += ShiftNBTPositions(child, dx, dz)
+			}
+		}
+		return count
+	case []any:
+		count := 0
+		for _, child := range v {
+			count += ShiftNBTPositions(child, dx, dz)
+		}
+		return count
+	}
+	return 0
+}
+
+// hardcodedSpawnerSize is the size of one entry of a
+// HardcodedSpawners record: a box as six ints, then the spawner type.
+const hardcodedSpawnerSize = 6*4 + 1
+
+// ShiftHardcodedSpawners returns a copy of a HardcodedSpawners record,
+// which holds the boxes of structures like witch huts and nether
+// fortresses, with the boxes moved by dx and dz blocks.
+func ShiftHardcodedSpawners(val []byte, dx, dz int) ([]byte, error) {
+	if len(val) < 4 {
+		return nil, fmt.Errorf("want at least 4 bytes for hardcoded spawner count; got %d", len(val))
+	}
+	count := int(binary.LittleEndian.Uint32(val))
+	if want := 4 + count*hardcodedSpawnerSize; len(val) != want {
+		return nil, fmt.Errorf("want %d bytes for %d hardcoded spawners; got %d", want, count, len(val))
+	}
+	res := slices.Clone(val)
+	for i := range count {
+		entry := res[4+i*hardcodedSpawnerSize:]
+		for j, d := range []int{dx, 0, dz, dx, 0, dz} {
+			c := int32(binary.LittleEndian.Uint32(entry[j*4:]))
+			binary.LittleEndian.PutUint32(entry[j*4:], uint32(c+int32(d)))
+		}
+	}
+	return res, nil
+}
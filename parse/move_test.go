@@ -0,0 +1,55 @@
+package parse
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestShiftNBTPositions(t *testing.T) {
+	chest := map[string]any{"id": "Chest", "x": int32(2), "y": int32(70), "z": int32(3), "pairx": int32(3), "pairz": int32(3),
+		"Items": []any{map[string]any{"Name": "minecraft:stone", "Count": uint8(1)}}}
+	pig := map[string]any{"identifier": "minecraft:pig", "Pos": []any{float32(4.5), float32(70), float32(-1.5)},
+		"Motion": []any{float32(1), float32(0), float32(1)}}
+	ticks := map[string]any{"tickList": []any{map[string]any{"x": int32(0), "y": int32(5), "z": int32(15)}}}
+
+	if got := ShiftNBTPositions(chest, 16, -32) + ShiftNBTPositions(pig, 16, -32) + ShiftNBTPositions(ticks, 16, -32); got != 4 {
+		t.Errorf("want 4 positions moved; got %d", got)
+	}
+	if pos, _ := NBTBlockPos(chest); pos[0] != 18 || pos[1] != 70 || pos[2] != -29 || chest["pairx"] != int32(19) || chest["pairz"] != int32(-29) {
+		t.Errorf("got chest %v", chest)
+	}
+	if want := []any{float32(20.5), float32(70), float32(-33.5)}; !reflect.DeepEqual(pig["Pos"], want) {
+		t.Errorf("want pig at %v; got %v", want, pig["Pos"])
+	}
+	if want := []any{float32(1), float32(0), float32(1)}; !reflect.DeepEqual(pig["Motion"], want) {
+		t.Errorf("want motion unchanged; got %v", pig["Motion"])
+	}
+	if tick := ticks["tickList"].([]any)[0].(map[string]any); tick["x"] != int32(16) || tick["z"] != int32(-17) {
+		t.Errorf("got tick %v", tick)
+	}
+}
+
+func TestRebaseData3D(t *testing.T) {
+	// Overworld sections -4 to 19, each all one biome: its index.
+	val := make([]byte, storedHeightMapSize)
+	for i := range 24 {
+		val = append(val, 0<<1|1)
+		val = binary.LittleEndian.AppendUint32(val, uint32(i))
+	}
+	got, err := RebaseData3D(val, world.Overworld, world.Nether)
+	if err != nil {
+		t.Fatal(err)
+	}
+	palettes, _, err := ParseBiomes(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The nether's sections 0 to 7 are the overworld's 4th to 11th.
+	want := [][]int32{{4}, {5}, {6}, {7}, {8}, {9}, {10}, {11}}
+	if !reflect.DeepEqual(palettes, want) {
+		t.Errorf("want palettes %v; got %v", want, palettes)
+	}
+}
@@ -0,0 +1,80 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// NBTRecord is one little-endian NBT compound from a value that holds
+// several of them back to back (as block entity and entity values
+// do). Raw holds the original encoding, and is used as-is when
+// re-encoding, unless it's nil.
+type NBTRecord struct {
+	Raw  []byte
+	Data map[string]any
+}
+
+// DecodeNBTRecords decodes a value holding zero or more concatenated
+// little-endian NBT compounds.
+func DecodeNBTRecords(val []byte) ([]NBTRecord, error) {
+	var res []NBTRecord
+	buf := bytes.NewBuffer(val)
+	d := nbt.NewDecoderWithEncoding(buf, nbt.LittleEndian)
+	for buf.Len() > 0 {
+		start := len(val) - buf.Len()
+		var m map[string]any
+		if err := d.Decode(&m); err != nil {
+			return nil, fmt.Errorf("unable to decode NBT record %d at offset %d: %w", len(res), start, err)
+		}
+		end := len(val) - buf.Len()
+		res = append(res, NBTRecord{Raw: val[start:end:end], Data: m})
+	}
+	return res, nil
+}
+
+// EncodeNBTRecords is the inverse of DecodeNBTRecords.
+func EncodeNBTRecords(records []NBTRecord) ([]byte, error) {
+	var res []byte
+	for i, rec := range records {
+		if rec.Raw != nil {
+			res = append(res, rec.Raw...)
+			continue
+		}
+		data, err := nbt.MarshalEncoding(rec.Data, nbt.LittleEndian)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode NBT record %d: %w", i, err)
+		}
+		res = append(res, data...)
+	}
+	return res, nil
+}
+
+// SameBlock reports whether two block palette entries describe the
+// same block: the same name and states. Versions are ignored.
+func SameBlock(a, b map[string]any) bool {
+	if a["name"] != b["name"] {
+		return false
+	}
+	aStates, _ := a["states"].(map[string]any)
+	bStates, _ := b["states"].(map[string]any)
+	if len(aStates) == 0 && len(bStates) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(aStates, bStates)
+}
+
+// NBTBlockPos returns the block position stored in the "x", "y" and
+// "z" int fields of a block entity's NBT.
+func NBTBlockPos(data map[string]any) (cube.Pos, bool) {
+	x, okX := data["x"].(int32)
+	y, okY := data["y"].(int32)
+	z, okZ := data["z"].(int32)
+	if !okX || !okY || !okZ {
+		return cube.Pos{}, false
+	}
+	return cube.Pos{int(x), int(y), int(z)}, true
+}
@@ -0,0 +1,201 @@
+package parse
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+)
+
+// Player is a player record: the local player of a single-player
+// world (~local_player), or a player of a multiplayer world
+// (player_server_<uuid>).
+type Player struct {
+	Key   string `json:"key"`
+	Local bool   `json:"local,omitempty"`
+	ID    string `json:"id,omitempty"` // The UUID in a player_server_ key
+	// From the player_<uuid> record pointing at this one, if any.
+	MsaID        string `json:"msa_id,omitempty"`
+	SelfSignedID string `json:"self_signed_id,omitempty"`
+
+	UniqueID       int64      `json:"unique_id"`
+	Dimension      string     `json:"dimension"`
+	Pos            [3]float32 `json:"pos"` // Eye position
+	Spawn          *cube.Pos  `json:"spawn,omitempty"`
+	SpawnDimension string     `json:"spawn_dimension,omitempty"`
+	GameMode       string     `json:"game_mode"`
+	Level          int32      `json:"level"`
+	LevelProgress  float32    `json:"level_progress"`
+	Inventory      []Item     `json:"inventory"`
+	Armor          []Item     `json:"armor,omitempty"`
+	Offhand        []Item     `json:"offhand,omitempty"`
+	EnderChest     []Item     `json:"ender_chest"`
+
+	Data map[string]any `json:"-"` // The full record
+}
+
+// Item is an item stack in a player's inventory.
+type Item struct {
+	Slot   int    `json:"slot"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Damage int    `json:"damage,omitempty"`
+}
+
+// BlockPos returns the block position the player is standing in.
+func (p Player) BlockPos() cube.Pos {
+	// Pos is the eye position, 1.62 blocks above the feet.
+	return cube.PosFromVec3([3]float64{float64(p.Pos[0]), float64(p.Pos[1]) - 1.62, float64(p.Pos[2])})
+}
+
+// Name returns the best short name for the player: the MSA ID, else
+// the UUID, else the key.
+func (p Player) Name() string {
+	return cmp.Or(p.MsaID, p.ID, p.Key)
+}
+
+// playerGameModes are the names of PlayerGameMode values.
+var playerGameModes = map[int32]string{
+	0: "survival",
+	1: "creative",
+	2: "adventure",
+	5: "default",
+	6: "spectator",
+}
+
+// ParsePlayer decodes a KeyTypeLocalPlayer or KeyTypePlayerServer
+// record.
+func ParsePlayer(kv *KeyVal) (Player, error) {
+	kt := kv.KeyType()
+	if kt != KeyTypeLocalPlayer && kt != KeyTypePlayerServer {
+		return Player{}, fmt.Errorf("can't parse a player from a %s key", kt)
+	}
+	records, err := DecodeNBTRecords(kv.Val)
+	if err != nil {
+		return Player{}, err
+	}
+	if len(records) != 1 {
+		return Player{}, fmt.Errorf("want one NBT compound for player %q; got %d", kv.Key, len(records))
+	}
+	data := records[0].Data
+
+	p := Player{
+		Key:        string(kv.Key),
+		Local:      kt == KeyTypeLocalPlayer,
+		ID:         strings.TrimPrefix(string(kv.Key), "player_server_"),
+		Data:       data,
+		Inventory:  parseItems(data["Inventory"]),
+		Armor:      parseItems(data["Armor"]),
+		Offhand:    parseItems(data["Offhand"]),
+		EnderChest: parseItems(data["EnderChestInventory"]),
+	}
+	if p.Local {
+		p.ID = ""
+	}
+	p.UniqueID, _ = data["UniqueID"].(int64)
+	p.Level, _ = data["PlayerLevel"].(int32)
+	p.LevelProgress, _ = data["PlayerLevelProgress"].(float32)
+	p.Dimension = dimensionIDName(data["DimensionId"])
+	if pos, ok := data["Pos"].([]any); ok && len(pos) == 3 {
+		for i := range pos {
+			p.Pos[i], _ = pos[i].(float32)
+		}
+	}
+	gameMode, _ := data["PlayerGameMode"].(int32)
+	p.GameMode = cmp.Or(playerGameModes[gameMode], fmt.Sprint(gameMode))
+
+	x, okX := data["SpawnX"].(int32)
+	y, okY := data["SpawnY"].(int32)
+	z, okZ := data["SpawnZ"].(int32)
+	// Players without a bed or anchor spawn have SpawnY set to
+	// math.MinInt32.
+	if okX && okY && okZ && y != -1<<31 {
+		p.Spawn = &cube.Pos{int(x), int(y), int(z)}
+		p.SpawnDimension = dimensionIDName(data["SpawnDimension"])
+	}
+	return p, nil
+}
+
+// dimensionIDName returns the name of a dimension stored as an int32
+// ID, or the number if it isn't a known dimension.
+func dimensionIDName(v any) string {
+	id, _ := v.(int32)
+	if dimension, ok := world.DimensionByID(int(id)); ok {
+		return fmt.Sprint(dimension)
+	}
+	return fmt.Sprint(id)
+}
+
+// parseItems decodes an NBT list of item stacks, skipping empty
+// slots.
+func parseItems(v any) []Item {
+	list, _ := v.([]any)
+	res := []Item{}
+	for _, entry := range list {
+		m, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := m["Name"].(string)
+		count, _ := m["Count"].(uint8)
+		if name == "" || count == 0 {
+			continue
+		}
+		slot, _ := m["Slot"].(uint8)
+		damage, _ := m["Damage"].(int16)
+		res = append(res, Item{Slot: int(slot), Name: name, Count: int(count), Damage: int(damage)})
+	}
+	return res
+}
+
+// ReadPlayers returns every player in the world, with the identities
+// from player_<uuid> records filled in on the player_server_ records
+// they point to. Players that fail to decode are returned as errors
+// alongside the others.
+func ReadPlayers(db *leveldb.DB) ([]Player, []error) {
+	var players []Player
+	var errs []error
+	identities := make(map[string]map[string]any) // By ServerId
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &KeyVal{Key: iter.Key(), Val: iter.Value()}
+		switch kv.KeyType() {
+		case KeyTypeLocalPlayer, KeyTypePlayerServer:
+			p, err := ParsePlayer(NewKeyVal(iter.Key(), iter.Value()))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("player %q: %w", kv.Key, err))
+				continue
+			}
+			players = append(players, p)
+		case KeyTypePlayer:
+			records, err := DecodeNBTRecords(kv.Val)
+			if err != nil || len(records) != 1 {
+				errs = append(errs, fmt.Errorf("player identity %q: can't decode: %v", kv.Key, err))
+				continue
+			}
+			if serverID, ok := records[0].Data["ServerId"].(string); ok {
+				identities[serverID] = records[0].Data
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, append(errs, err)
+	}
+
+	for i, p := range players {
+		if identity, ok := identities[p.Key]; ok {
+			players[i].MsaID, _ = identity["MsaId"].(string)
+			players[i].SelfSignedID, _ = identity["SelfSignedId"].(string)
+		}
+	}
+	slices.SortFunc(players, func(a, b Player) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return players, errs
+}
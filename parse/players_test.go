@@ -0,0 +1,55 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestParsePlayer(t *testing.T) {
+	data := map[string]any{
+		"UniqueID":       int64(-4294967295),
+		"DimensionId":    int32(1),
+		"Pos":            []any{float32(10.5), float32(65.62), float32(-20.5)},
+		"PlayerGameMode": int32(1),
+		"PlayerLevel":    int32(30),
+		"SpawnX":         int32(1),
+		"SpawnY":         int32(64),
+		"SpawnZ":         int32(2),
+		"SpawnDimension": int32(0),
+		"Inventory": []any{
+			map[string]any{"Slot": uint8(0), "Name": "minecraft:diamond_pickaxe", "Count": uint8(1), "Damage": int16(12)},
+			map[string]any{"Slot": uint8(1), "Name": "", "Count": uint8(0)},
+		},
+		"EnderChestInventory": []any{
+			map[string]any{"Slot": uint8(3), "Name": "minecraft:elytra", "Count": uint8(1)},
+		},
+	}
+	val, err := nbt.MarshalEncoding(data, nbt.LittleEndian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParsePlayer(NewKeyVal([]byte("player_server_1234-abcd"), val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "1234-abcd" || p.Dimension != "Nether" || p.GameMode != "creative" || p.Level != 30 {
+		t.Errorf("got ID %q, dimension %q, game mode %q, level %d", p.ID, p.Dimension, p.GameMode, p.Level)
+	}
+	if want := (cube.Pos{10, 64, -21}); p.BlockPos() != want {
+		t.Errorf("want block position %v; got %v", want, p.BlockPos())
+	}
+	if want := (&cube.Pos{1, 64, 2}); !reflect.DeepEqual(p.Spawn, want) || p.SpawnDimension != "Overworld" {
+		t.Errorf("want spawn %v in Overworld; got %v in %s", want, p.Spawn, p.SpawnDimension)
+	}
+	wantInventory := []Item{{Slot: 0, Name: "minecraft:diamond_pickaxe", Count: 1, Damage: 12}}
+	if !reflect.DeepEqual(p.Inventory, wantInventory) {
+		t.Errorf("want inventory %v; got %v", wantInventory, p.Inventory)
+	}
+	wantEnderChest := []Item{{Slot: 3, Name: "minecraft:elytra", Count: 1}}
+	if !reflect.DeepEqual(p.EnderChest, wantEnderChest) {
+		t.Errorf("want ender chest %v; got %v", wantEnderChest, p.EnderChest)
+	}
+}
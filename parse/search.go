@@ -0,0 +1,221 @@
+package parse
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"golang.org/x/exp/maps"
+)
+
+// BlockQuery describes blocks to look for: a full block name, and
+// optionally some block states that must all match.
+type BlockQuery struct {
+	Name   string            // eg. "minecraft:mob_spawner"
+	States map[string]string // state name to value, compared using the value's %v formatting
+}
+
+// NewBlockQuery returns a BlockQuery for the given block name, adding
+// the "minecraft:" namespace if it's missing. Each state should be in
+// the form "name=value".
+func NewBlockQuery(name string, states ...string) (BlockQuery, error) {
+	if name == "" {
+		return BlockQuery{}, fmt.Errorf("block query needs a block name")
+	}
+	if !strings.Contains(name, ":") {
+		name = "minecraft:" + name
+	}
+	q := BlockQuery{Name: name}
+	for _, state := range states {
+		k, v, ok := strings.Cut(state, "=")
+		if !ok || k == "" {
+			return BlockQuery{}, fmt.Errorf("want block state as name=value; got %q", state)
+		}
+		if q.States == nil {
+			q.States = make(map[string]string)
+		}
+		q.States[k] = v
+	}
+	return q, nil
+}
+
+// String returns the query in a form like "minecraft:chest[facing_direction=2]".
+func (q BlockQuery) String() string {
+	if len(q.States) == 0 {
+		return q.Name
+	}
+	keys := maps.Keys(q.States)
+	slices.Sort(keys)
+	var states []string
+	for _, k := range keys {
+		states = append(states, k+"="+q.States[k])
+	}
+	return q.Name + "[" + strings.Join(states, ",") + "]"
+}
+
+// NewBlock returns a block palette entry (without a version) for the
+// given block name, adding the "minecraft:" namespace if it's missing.
+// Each state should be in the form "name=value". Since the NBT type of
+// a state can't be told from its text, states ending in "_bit" are
+// stored as bytes, other integers as 32-bit ints, and anything else
+// as strings, which matches what Bedrock uses.
+func NewBlock(name string, states ...string) (map[string]any, error) {
+	q, err := NewBlockQuery(name, states...)
+	if err != nil {
+		return nil, err
+	}
+	blockStates := make(map[string]any)
+	for k, v := range q.States {
+		if strings.HasSuffix(k, "_bit") {
+			switch v {
+			case "0", "false":
+				blockStates[k] = uint8(0)
+			case "1", "true":
+				blockStates[k] = uint8(1)
+			default:
+				return nil, fmt.Errorf("want 0, 1, true, or false for block state %q; got %q", k, v)
+			}
+			continue
+		}
+		if i, err := strconv.ParseInt(v, 10, 32); err == nil {
+			blockStates[k] = int32(i)
+			continue
+		}
+		blockStates[k] = v
+	}
+	return map[string]any{"name": q.Name, "states": blockStates}, nil
+}
+
+// Matches reports whether the given block palette entry matches the
+// query.
+func (q BlockQuery) Matches(block map[string]any) bool {
+	if block["name"] != q.Name {
+		return false
+	}
+	if len(q.States) == 0 {
+		return true
+	}
+	states, _ := block["states"].(map[string]any)
+	for k, want := range q.States {
+		got, ok := states[k]
+		if !ok || fmt.Sprintf("%v", got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// BlockMatch is a single block found by SearchBlocks.
+type BlockMatch struct {
+	Dimension string         `json:"dimension"`
+	X         int            `json:"x"`
+	Y         int            `json:"y"`
+	Z         int            `json:"z"`
+	Layer     int            `json:"layer"`
+	Name      string         `json:"name"`
+	States    map[string]any `json:"states,omitempty"`
+}
+
+// Pos returns the block position of the match.
+func (m BlockMatch) Pos() cube.Pos {
+	return cube.Pos{m.X, m.Y, m.Z}
+}
+
+// SearchBlocks returns all blocks in the given Dimension and Box that
+// match the query, in every storage layer. Sub-chunks whose raw data
+// doesn't contain the block name, or whose palettes have no matching
+// entry, are skipped without looking at their blocks. Sub-chunks that
+// fail to decode are skipped too, and returned as errors alongside the
+// matches.
+func SearchBlocks(db *leveldb.DB, dimension world.Dimension, box Box, q BlockQuery) ([]BlockMatch, []error) {
+	var res []BlockMatch
+	var errs []error
+	name := []byte(q.Name)
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &KeyVal{Key: iter.Key(), Val: iter.Value()}
+		keyInfo := kv.KeyTypeAndChunkLocation()
+		if !keyInfo.HasLocation || !keyInfo.KeyType.IsSubChunkPrefix() || keyInfo.Dimension != dimension {
+			continue
+		}
+		if !box.ContainsSubChunk(keyInfo.ChunkPos, int32(int8(kv.Key[len(kv.Key)-1]))) {
+			continue
+		}
+		// Palette entries hold block names as plain strings, so this
+		// is a cheap way to rule out most sub-chunks.
+		if !bytes.Contains(kv.Val, name) {
+			continue
+		}
+
+		yIndex := int8(kv.Key[len(kv.Key)-1])
+		sc, err := ParseSubChunk(NewKeyVal(iter.Key(), iter.Value()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("skipped sub-chunk %d of chunk %v in %v: %w", yIndex, keyInfo.ChunkPos, dimension, err))
+			continue
+		}
+		res = append(res, searchSubChunk(sc, keyInfo.ChunkPos, dimension, box, q)...)
+	}
+	if err := iter.Error(); err != nil {
+		errs = append(errs, err)
+	}
+
+	slices.SortFunc(res, func(a, b BlockMatch) int {
+		return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Z, b.Z), cmp.Compare(a.Y, b.Y), cmp.Compare(a.Layer, b.Layer))
+	})
+	return res, errs
+}
+
+func searchSubChunk(sc SubChunk, chunkPos world.ChunkPos, dimension world.Dimension, box Box, q BlockQuery) []BlockMatch {
+	var res []BlockMatch
+	baseX, baseY, baseZ := int(chunkPos.X())<<4, int(sc.yIndex)<<4, int(chunkPos.Z())<<4
+
+	for layerIndex, layer := range sc.layers {
+		matching := make([]bool, len(layer.palettes))
+		found := false
+		for i, block := range layer.palettes {
+			if q.Matches(block) {
+				matching[i] = true
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		for x := 0; x < 16; x++ {
+			for z := 0; z < 16; z++ {
+				for y := 0; y < 16; y++ {
+					index := layer.blockEntries.get(x, z, y)
+					if !matching[index] {
+						continue
+					}
+					pos := cube.Pos{baseX + x, baseY + y, baseZ + z}
+					if !box.Contains(pos) {
+						continue
+					}
+					block := layer.palettes[index]
+					states, _ := block["states"].(map[string]any)
+					res = append(res, BlockMatch{
+						Dimension: fmt.Sprint(dimension),
+						X:         pos[0],
+						Y:         pos[1],
+						Z:         pos[2],
+						Layer:     layerIndex,
+						Name:      q.Name,
+						States:    states,
+					})
+				}
+			}
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,132 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestNewBlockQuery(t *testing.T) {
+	q, err := NewBlockQuery("chest", "facing_direction=2", "open_bit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BlockQuery{Name: "minecraft:chest", States: map[string]string{"facing_direction": "2", "open_bit": "1"}}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("want %+v; got %+v", want, q)
+	}
+	if got, want := q.String(), "minecraft:chest[facing_direction=2,open_bit=1]"; got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+	if q, err := NewBlockQuery("other:thing"); err != nil || q.Name != "other:thing" || q.States != nil {
+		t.Errorf("want other:thing with no states; got %+v, %v", q, err)
+	}
+	for _, bad := range [][]string{{""}, {"chest", "facing_direction"}, {"chest", "=2"}} {
+		if _, err := NewBlockQuery(bad[0], bad[1:]...); err == nil {
+			t.Errorf("NewBlockQuery(%q): want an error", bad)
+		}
+	}
+}
+
+func TestBlockQueryMatches(t *testing.T) {
+	chest := map[string]any{"name": "minecraft:chest", "states": map[string]any{
+		"facing_direction": int32(2),
+		"open_bit":         uint8(1),
+		"color":            "red",
+	}}
+	for _, tt := range []struct {
+		name   string
+		states []string
+		want   bool
+	}{
+		{"chest", nil, true},
+		{"barrel", nil, false},
+		{"chest", []string{"facing_direction=2"}, true},
+		{"chest", []string{"facing_direction=3"}, false},
+		{"chest", []string{"facing_direction=2", "open_bit=1", "color=red"}, true},
+		{"chest", []string{"facing_direction=2", "open_bit=0"}, false},
+		{"chest", []string{"missing=1"}, false},
+	} {
+		q, err := NewBlockQuery(tt.name, tt.states...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Matches(chest); got != tt.want {
+			t.Errorf("%s matches chest: want %v; got %v", q, tt.want, got)
+		}
+	}
+}
+
+func TestSearchBlocks(t *testing.T) {
+	chest := func(facing int) map[string]any {
+		block, _ := NewBlock("chest", fmt.Sprintf("facing_direction=%d", facing))
+		return block
+	}
+	stone, _ := NewBlock("stone")
+	encode := func(sc SubChunk) []byte {
+		val, err := sc.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return val
+	}
+
+	// Sub-chunk 0 of chunk 0,0 has chests facing 2 and 3 in layer 0,
+	// and one facing 2 in layer 1 above the box.
+	sc0 := NewSubChunk(0)
+	sc0.SetBlock(1, 2, 3, 0, chest(2))
+	sc0.SetBlock(4, 5, 6, 0, chest(3))
+	sc0.SetBlock(7, 7, 7, 1, chest(2))
+	sc0.SetBlock(0, 0, 0, 0, stone)
+	// Sub-chunk 1 has a chest facing 3 only, so the palette rules it
+	// out when searching for one facing 2.
+	sc1 := NewSubChunk(1)
+	sc1.SetBlock(1, 1, 1, 0, chest(3))
+	// Chunk 1,0 is outside the box.
+	outside := NewSubChunk(0)
+	outside.SetBlock(1, 2, 3, 0, chest(2))
+
+	pos, other := world.ChunkPos{0, 0}, world.ChunkPos{1, 0}
+	db := memDB(t, map[string][]byte{
+		string(MakeSubChunkKey(pos, world.Overworld, 0)):   encode(sc0),
+		string(MakeSubChunkKey(pos, world.Overworld, 1)):   encode(sc1),
+		string(MakeSubChunkKey(other, world.Overworld, 0)): encode(outside),
+		string(MakeSubChunkKey(pos, world.Nether, 0)):      encode(sc0),
+		// A sub-chunk version ParseSubChunk doesn't know, which doesn't
+		// mention chests, so the raw-bytes check skips it...
+		string(MakeSubChunkKey(pos, world.Overworld, 2)): []byte("\x07\x01\x02minecraft:stone"),
+		// ...and one that does, which fails to decode.
+		string(MakeSubChunkKey(pos, world.Overworld, 3)): []byte("\x07\x01\x03minecraft:chest"),
+	})
+	box := Box{Min: cube.Pos{0, -64, 0}, Max: cube.Pos{15, 60, 15}}
+
+	q, _ := NewBlockQuery("chest")
+	matches, errs := SearchBlocks(db, world.Overworld, Box{Min: cube.Pos{0, -64, 0}, Max: cube.Pos{10, 5, 10}}, q)
+	if len(errs) != 0 {
+		t.Errorf("want no errors with the bad sub-chunk outside the box; got %v", errs)
+	}
+	var got []cube.Pos
+	for _, m := range matches {
+		got = append(got, m.Pos())
+	}
+	if want := []cube.Pos{{1, 2, 3}, {4, 5, 6}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want chests at %v; got %v", want, got)
+	}
+
+	q, _ = NewBlockQuery("chest", "facing_direction=2")
+	matches, errs = SearchBlocks(db, world.Overworld, box, q)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "sub-chunk 3 of chunk") {
+		t.Errorf("want the bad sub-chunk 3 reported; got %v", errs)
+	}
+	want := []BlockMatch{
+		{Dimension: "Overworld", X: 1, Y: 2, Z: 3, Layer: 0, Name: "minecraft:chest", States: map[string]any{"facing_direction": int32(2)}},
+		{Dimension: "Overworld", X: 7, Y: 7, Z: 7, Layer: 1, Name: "minecraft:chest", States: map[string]any{"facing_direction": int32(2)}},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("want %+v; got %+v", want, matches)
+	}
+}
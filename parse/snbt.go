@@ -0,0 +1,345 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"golang.org/x/exp/maps"
+)
+
+// FormatSNBT formats a decoded NBT value (as returned by DecodeValue
+// or DecodeNBTRecords) as SNBT, the text format used by Minecraft
+// commands, with compound keys sorted.
+func FormatSNBT(v any) string {
+	var b strings.Builder
+	writeSNBT(&b, reflect.ValueOf(v), "")
+	return b.String()
+}
+
+var bareSNBTKey = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+func writeSNBT(b *strings.Builder, v reflect.Value, indent string) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		b.WriteString("null")
+		return
+	}
+	switch x := v.Interface().(type) {
+	case uint8:
+		fmt.Fprintf(b, "%db", int8(x))
+		return
+	case int16:
+		fmt.Fprintf(b, "%ds", x)
+		return
+	case int32:
+		fmt.Fprintf(b, "%d", x)
+		return
+	case int64:
+		fmt.Fprintf(b, "%dL", x)
+		return
+	case float32:
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32) + "f")
+		return
+	case float64:
+		b.WriteString(strconv.FormatFloat(x, 'g', -1, 64) + "d")
+		return
+	case string:
+		b.WriteString(strconv.Quote(x))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Len() == 0 {
+			b.WriteString("{}")
+			return
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		inner := indent + "  "
+		b.WriteString("{\n")
+		for i, k := range keys {
+			b.WriteString(inner)
+			if bareSNBTKey.MatchString(k.String()) {
+				b.WriteString(k.String())
+			} else {
+				b.WriteString(strconv.Quote(k.String()))
+			}
+			b.WriteString(": ")
+			writeSNBT(b, v.MapIndex(k), inner)
+			if i < len(keys)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "}")
+	case reflect.Array:
+		prefix := map[reflect.Kind]string{reflect.Uint8: "B", reflect.Int32: "I", reflect.Int64: "L"}[v.Type().Elem().Kind()]
+		b.WriteString("[" + prefix + ";")
+		for i := range v.Len() {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(" ")
+			writeSNBT(b, v.Index(i), indent)
+		}
+		b.WriteString("]")
+	case reflect.Slice:
+		b.WriteString("[")
+		for i := range v.Len() {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeSNBT(b, v.Index(i), indent)
+		}
+		b.WriteString("]")
+	default:
+		fmt.Fprintf(b, "%v", v.Interface())
+	}
+}
+
+// EncodeEditedNBT encodes JSON, as written by marshalling the result
+// of DecodeValue for a key of an NBT type and then edited, back into
+// the key's value. JSON doesn't say which NBT type a number is, so
+// each tag keeps the type it has in orig, the key's current value.
+// New tags get the type Bedrock mostly uses: TAG_Int for whole
+// numbers, TAG_Float for others, TAG_Byte for booleans.
+func EncodeEditedNBT(kt KeyType, orig []byte, edited []byte) ([]byte, error) {
+	if !kt.IsNBT() {
+		return nil, fmt.Errorf("%s values aren't NBT", kt)
+	}
+	records, err := DecodeNBTRecords(orig)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(edited))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("error reading edited JSON: %w", err)
+	}
+
+	var compounds []any
+	if kt.IsNBTList() {
+		list, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("want a JSON list of compounds for %s; got %T", kt, v)
+		}
+		compounds = list
+	} else {
+		compounds = []any{v}
+	}
+
+	var res []byte
+	for i, c := range compounds {
+		var template any
+		if i < len(records) {
+			template = records[i].Data
+		}
+		m, err := coerceNBT(c, template, fmt.Sprintf("[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m.(map[string]any); !ok {
+			return nil, fmt.Errorf("want a JSON object for compound %d; got %T", i, c)
+		}
+		data, err := nbt.MarshalEncoding(m, nbt.LittleEndian)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode NBT compound %d: %w", i, err)
+		}
+		res = append(res, data...)
+	}
+	return res, nil
+}
+
+// coerceNBT converts a value decoded from JSON into the NBT type of
+// template, or an inferred type if template is nil. path is used in
+// error messages.
+func coerceNBT(v any, template any, path string) (any, error) {
+	switch template.(type) {
+	case uint8, int16, int32, int64, float32, float64:
+		if b, ok := v.(bool); ok {
+			v = json.Number("0")
+			if b {
+				v = json.Number("1")
+			}
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a number; got %T", path, v)
+		}
+		return coerceNumber(n, template, path)
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a string; got %T", path, v)
+		}
+		return s, nil
+	case map[string]any:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want an object; got %T", path, v)
+		}
+		return coerceCompound(m, template.(map[string]any), path)
+	case []byte:
+		// A TAG_List of TAG_Byte decodes as []byte, which JSON writes
+		// as a base64 string. Accept that, or a list of numbers.
+		if s, ok := v.(string); ok {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad base64 byte list: %w", path, err)
+			}
+			return b, nil
+		}
+		list, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: want a list or a base64 string; got %T", path, v)
+		}
+		res := make([]byte, len(list))
+		for i, item := range list {
+			c, err := coerceNBT(item, uint8(0), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = c.(uint8)
+		}
+		return res, nil
+	}
+
+	if template != nil {
+		t := reflect.TypeOf(template)
+		switch t.Kind() {
+		case reflect.Array:
+			list, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: want a list; got %T", path, v)
+			}
+			elem := reflect.New(t.Elem()).Elem().Interface()
+			res := reflect.New(reflect.ArrayOf(len(list), t.Elem())).Elem()
+			for i, item := range list {
+				c, err := coerceNBT(item, elem, fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return nil, err
+				}
+				res.Index(i).Set(reflect.ValueOf(c))
+			}
+			return res.Interface(), nil
+		case reflect.Slice:
+			list, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: want a list; got %T", path, v)
+			}
+			tv := reflect.ValueOf(template)
+			res := make([]any, len(list))
+			for i, item := range list {
+				var elem any
+				if tv.Len() > 0 {
+					elem = tv.Index(min(i, tv.Len()-1)).Interface()
+				}
+				c, err := coerceNBT(item, elem, fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return nil, err
+				}
+				res[i] = c
+			}
+			return res, nil
+		}
+	}
+
+	// No usable template: infer the type.
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return uint8(1), nil
+		}
+		return uint8(0), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(x), 10, 32); err == nil {
+			return int32(i), nil
+		}
+		if i, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(string(x), 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad number %q", path, x)
+		}
+		return float32(f), nil
+	case string:
+		return x, nil
+	case map[string]any:
+		return coerceCompound(x, nil, path)
+	case []any:
+		res := make([]any, len(x))
+		for i, item := range x {
+			var elem any
+			if i > 0 {
+				elem = res[0]
+			}
+			c, err := coerceNBT(item, elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = c
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("%s: can't store %T in NBT", path, v)
+}
+
+func coerceCompound(m map[string]any, template map[string]any, path string) (map[string]any, error) {
+	res := make(map[string]any, len(m))
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+	for _, k := range keys {
+		c, err := coerceNBT(m[k], template[k], path+"."+k)
+		if err != nil {
+			return nil, err
+		}
+		res[k] = c
+	}
+	return res, nil
+}
+
+func coerceNumber(n json.Number, template any, path string) (any, error) {
+	bits := map[reflect.Kind]int{reflect.Uint8: 8, reflect.Int16: 16, reflect.Int32: 32, reflect.Int64: 64}
+	switch template.(type) {
+	case float32, float64:
+		size := 32
+		if _, ok := template.(float64); ok {
+			size = 64
+		}
+		f, err := strconv.ParseFloat(string(n), size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad number %q: %w", path, n, err)
+		}
+		if size == 32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case uint8:
+		// TAG_Byte is signed in NBT, but decodes as a uint8; accept both
+		// ranges.
+		i, err := strconv.ParseInt(string(n), 10, 16)
+		if err != nil || i < -128 || i > 255 {
+			return nil, fmt.Errorf("%s: want a byte; got %q", path, n)
+		}
+		return uint8(i), nil
+	}
+	kind := reflect.TypeOf(template).Kind()
+	i, err := strconv.ParseInt(string(n), 10, bits[kind])
+	if err != nil {
+		return nil, fmt.Errorf("%s: want a %d-bit integer; got %q", path, bits[kind], n)
+	}
+	return reflect.ValueOf(i).Convert(reflect.TypeOf(template)).Interface(), nil
+}
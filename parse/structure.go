@@ -0,0 +1,458 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// structureTemplateKeyPrefix is the prefix of the keys structures
+// saved with a structure block are stored under, which end in the
+// structure's name, like "mystructure:house".
+const structureTemplateKeyPrefix = "structuretemplate_"
+
+// MaxStructureBlocks is the most blocks ReadStructure will read, to
+// stop a mistyped box from using all the memory there is.
+const MaxStructureBlocks = 1 << 24
+
+// Structure is a box of blocks with their block entities and
+// entities, in the .mcstructure format that structure blocks save and
+// load. The same format is used for structuretemplate_ records.
+type Structure struct {
+	Size   [3]int   // x, y, z
+	Origin cube.Pos // Where the structure was in the world it came from
+	// Palette indices of each block, in two layers: the block, and a
+	// second block such as water for waterlogged blocks. Blocks are in
+	// x, y, z order, with z varying fastest (see Index). -1 means the
+	// block is left alone when the structure is placed ("structure
+	// void"), or in layer 1, that there's no second block.
+	Indices [2][]int32
+	Palette []map[string]any
+	// The block entities of blocks, by their index, and the entities.
+	// Their positions are in the world the structure came from, so
+	// subtracting Origin gives positions within the structure.
+	BlockEntities map[int]map[string]any
+	Entities      []map[string]any
+}
+
+// StructureVoid is the palette index of a block the structure leaves
+// alone.
+const StructureVoid = -1
+
+// NewStructure returns a structure of the given size, with every
+// block void.
+func NewStructure(size [3]int, origin cube.Pos) *Structure {
+	n := size[0] * size[1] * size[2]
+	s := &Structure{
+		Size:          size,
+		Origin:        origin,
+		BlockEntities: make(map[int]map[string]any),
+	}
+	for layer := range s.Indices {
+		s.Indices[layer] = voidIndices(n)
+	}
+	return s
+}
+
+func voidIndices(n int) []int32 {
+	res := make([]int32, n)
+	for i := range res {
+		res[i] = StructureVoid
+	}
+	return res
+}
+
+// Index returns the index into Indices of the block at the given
+// position relative to the structure's corner.
+func (s *Structure) Index(x, y, z int) int {
+	return (x*s.Size[1]+y)*s.Size[2] + z
+}
+
+// Pos returns the position relative to the structure's corner of the
+// block with the given index; the inverse of Index.
+func (s *Structure) Pos(index int) cube.Pos {
+	return cube.Pos{index / (s.Size[1] * s.Size[2]), index / s.Size[2] % s.Size[1], index % s.Size[2]}
+}
+
+// Box returns the box the structure covers when placed at origin.
+func (s *Structure) Box(origin cube.Pos) Box {
+	return Box{Min: origin, Max: origin.Add(cube.Pos{s.Size[0] - 1, s.Size[1] - 1, s.Size[2] - 1})}
+}
+
+// PaletteIndex returns the index of block in the palette, adding it
+// if needed.
+func (s *Structure) PaletteIndex(block map[string]any) int32 {
+	index := slices.IndexFunc(s.Palette, func(entry map[string]any) bool {
+		return SameBlock(entry, block)
+	})
+	if index < 0 {
+		index = len(s.Palette)
+		s.Palette = append(s.Palette, block)
+	}
+	return int32(index)
+}
+
+// Block returns the palette entry of the block at the given position
+// and layer, or nil if it's void.
+func (s *Structure) Block(x, y, z, layer int) map[string]any {
+	index := s.Indices[layer][s.Index(x, y, z)]
+	if index == StructureVoid {
+		return nil
+	}
+	return s.Palette[index]
+}
+
+// BlockCount returns the number of non-void blocks in layer 0.
+func (s *Structure) BlockCount() int {
+	n := 0
+	for _, index := range s.Indices[0] {
+		if index != StructureVoid {
+			n++
+		}
+	}
+	return n
+}
+
+// ParseStructure decodes a .mcstructure file or structuretemplate_
+// record.
+func ParseStructure(val []byte) (*Structure, error) {
+	var data map[string]any
+	if err := nbt.UnmarshalEncoding(val, &data, nbt.LittleEndian); err != nil {
+		return nil, fmt.Errorf("unable to decode structure: %w", err)
+	}
+	if version, _ := data["format_version"].(int32); version != 1 {
+		return nil, fmt.Errorf("unknown structure format version %v", data["format_version"])
+	}
+	size, ok := int32Triple(data["size"])
+	if !ok {
+		return nil, fmt.Errorf("structure has no size")
+	}
+	for _, n := range size {
+		if n < 0 {
+			return nil, fmt.Errorf("structure has negative size %v", size)
+		}
+	}
+	origin, _ := int32Triple(data["structure_world_origin"])
+	s := &Structure{
+		Size:          [3]int{int(size[0]), int(size[1]), int(size[2])},
+		Origin:        cube.Pos{int(origin[0]), int(origin[1]), int(origin[2])},
+		BlockEntities: make(map[int]map[string]any),
+	}
+	n, ok := structureBlocks(s.Size)
+	if !ok {
+		return nil, fmt.Errorf("structure of size %v has more than %d blocks", s.Size, MaxStructureBlocks)
+	}
+
+	structure, _ := data["structure"].(map[string]any)
+	layers, _ := structure["block_indices"].([]any)
+	if len(layers) != 2 {
+		return nil, fmt.Errorf("want 2 layers of block indices; got %d", len(layers))
+	}
+	palette, _ := structure["palette"].(map[string]any)
+	def, _ := palette["default"].(map[string]any)
+	s.Palette = compounds(def["block_palette"])
+	if s.Palette == nil {
+		s.Palette = []map[string]any{}
+	}
+	for layer, v := range layers {
+		indices, _ := v.([]int32)
+		if len(indices) == 0 && n > 0 {
+			// An empty list has no element type, so it doesn't
+			// decode as []int32.
+			indices = voidIndices(n)
+		}
+		if len(indices) != n {
+			return nil, fmt.Errorf("layer %d has %d block indices for %d blocks", layer, len(indices), n)
+		}
+		for _, index := range indices {
+			if index < StructureVoid || int(index) >= len(s.Palette) {
+				return nil, fmt.Errorf("layer %d has palette index %d, but the palette has %d entries", layer, index, len(s.Palette))
+			}
+		}
+		s.Indices[layer] = indices
+	}
+
+	positionData, _ := def["block_position_data"].(map[string]any)
+	for k, v := range positionData {
+		index, err := strconv.Atoi(k)
+		if err != nil || index < 0 || index >= n {
+			return nil, fmt.Errorf("bad block index %q in block position data", k)
+		}
+		m, _ := v.(map[string]any)
+		if be, ok := m["block_entity_data"].(map[string]any); ok {
+			s.BlockEntities[index] = be
+		}
+	}
+	s.Entities = compounds(structure["entities"])
+	return s, nil
+}
+
+// Encode returns the structure in the .mcstructure format.
+func (s *Structure) Encode() ([]byte, error) {
+	positionData := make(map[string]any)
+	for index, be := range s.BlockEntities {
+		positionData[strconv.Itoa(index)] = map[string]any{"block_entity_data": be}
+	}
+	palette := make([]any, len(s.Palette))
+	for i, block := range s.Palette {
+		palette[i] = block
+	}
+	entities := make([]any, len(s.Entities))
+	for i, e := range s.Entities {
+		entities[i] = e
+	}
+	data := map[string]any{
+		"format_version": int32(1),
+		"size":           []int32{int32(s.Size[0]), int32(s.Size[1]), int32(s.Size[2])},
+		"structure_world_origin": []int32{
+			int32(s.Origin[0]), int32(s.Origin[1]), int32(s.Origin[2]),
+		},
+		"structure": map[string]any{
+			"block_indices": []any{s.Indices[0], s.Indices[1]},
+			"entities":      entities,
+			"palette": map[string]any{
+				"default": map[string]any{
+					"block_palette":       palette,
+					"block_position_data": positionData,
+				},
+			},
+		},
+	}
+	return nbt.MarshalEncoding(data, nbt.LittleEndian)
+}
+
+// int32Triple returns a list of three TAG_Int.
+func int32Triple(v any) ([3]int32, bool) {
+	list, ok := v.([]int32)
+	if !ok || len(list) != 3 {
+		return [3]int32{}, false
+	}
+	return [3]int32(list), true
+}
+
+// structureBlocks returns the number of blocks in a structure of the
+// given size, and false if it's more than MaxStructureBlocks. Each
+// dimension is checked before multiplying, so huge sizes can't
+// overflow.
+func structureBlocks(size [3]int) (int, bool) {
+	n := 1
+	for _, d := range size {
+		if d > MaxStructureBlocks || (d > 0 && n > MaxStructureBlocks/d) {
+			return 0, false
+		}
+		n *= d
+	}
+	return n, true
+}
+
+// ReadStructure copies a box of a dimension into a new Structure, with
+// its Origin at the box's minimum corner. Blocks in chunks with no
+// data are left void; blocks in missing sub-chunks of existing chunks
+// are air.
+func ReadStructure(db *leveldb.DB, dimension world.Dimension, box Box) (*Structure, error) {
+	box, ok := box.Intersect(DimensionBox(dimension))
+	if !ok {
+		return nil, fmt.Errorf("box is outside the height limits of %v", dimension)
+	}
+	size := [3]int{box.Max[0] - box.Min[0] + 1, box.Max[1] - box.Min[1] + 1, box.Max[2] - box.Min[2] + 1}
+	if _, ok := structureBlocks(size); !ok {
+		return nil, fmt.Errorf("box %s has more than %d blocks", box, MaxStructureBlocks)
+	}
+	s := NewStructure(size, box.Min)
+	air := s.PaletteIndex(AirBlock())
+
+	for _, chunkPos := range box.Chunks() {
+		kvs, err := AllEntriesWithChunkCoordinatePrefix(db, chunkPos, dimension)
+		if err != nil {
+			return nil, err
+		}
+		subChunks := make(map[int32]SubChunk)
+		found := false
+		for _, kv := range kvs {
+			kt := kv.KeyType()
+			if !kt.IsChunkDataForDimension(dimension) {
+				continue
+			}
+			found = true
+			switch kt.LevelChunkTag() {
+			case LevelChunkTagSubChunkPrefix:
+				sc, err := ParseSubChunk(kv)
+				if err != nil {
+					return nil, fmt.Errorf("chunk %v in %v: %w", chunkPos, dimension, err)
+				}
+				subChunks[sc.YIndex()] = sc
+			case LevelChunkTagBlockEntity:
+				records, err := DecodeNBTRecords(kv.Val)
+				if err != nil {
+					return nil, fmt.Errorf("block entities of chunk %v in %v: %w", chunkPos, dimension, err)
+				}
+				for _, rec := range records {
+					if pos, ok := NBTBlockPos(rec.Data); ok && box.Contains(pos) {
+						d := pos.Sub(box.Min)
+						s.BlockEntities[s.Index(d[0], d[1], d[2])] = rec.Data
+					}
+				}
+			case LevelChunkTagEntity:
+				records, err := DecodeNBTRecords(kv.Val)
+				if err != nil {
+					return nil, fmt.Errorf("entities of chunk %v in %v: %w", chunkPos, dimension, err)
+				}
+				for _, rec := range records {
+					if pos, ok := EntityBlockPos(rec.Data); ok && box.Contains(pos) {
+						s.Entities = append(s.Entities, rec.Data)
+					}
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+		if err := s.readActors(db, chunkPos, dimension, box); err != nil {
+			return nil, err
+		}
+
+		chunkBox := Box{
+			Min: cube.Pos{int(chunkPos.X()) << 4, box.Min[1], int(chunkPos.Z()) << 4},
+			Max: cube.Pos{int(chunkPos.X())<<4 + 15, box.Max[1], int(chunkPos.Z())<<4 + 15},
+		}
+		clip, _ := chunkBox.Intersect(box)
+		for yIndex := clip.Min[1] >> 4; yIndex <= clip.Max[1]>>4; yIndex++ {
+			sc, ok := subChunks[int32(yIndex)]
+			// Map each layer's palette to the structure's.
+			var remap [2][]int32
+			if ok {
+				for layer := 0; layer < min(sc.LayerCount(), 2); layer++ {
+					for _, block := range sc.Layer(layer).Palette() {
+						index := s.PaletteIndex(block)
+						if layer == 1 && IsAirBlock(block) {
+							index = StructureVoid
+						}
+						remap[layer] = append(remap[layer], index)
+					}
+				}
+			}
+			for y := max(clip.Min[1], yIndex<<4); y <= min(clip.Max[1], yIndex<<4+15); y++ {
+				for x := clip.Min[0]; x <= clip.Max[0]; x++ {
+					for z := clip.Min[2]; z <= clip.Max[2]; z++ {
+						i := s.Index(x-box.Min[0], y-box.Min[1], z-box.Min[2])
+						s.Indices[0][i] = air
+						for layer := range remap {
+							if remap[layer] != nil {
+								s.Indices[layer][i] = remap[layer][sc.Layer(layer).PaletteIndex(x&15, y&15, z&15)]
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// readActors adds the entities listed in a chunk's actor digest that
+// are inside box.
+func (s *Structure) readActors(db *leveldb.DB, chunkPos world.ChunkPos, dimension world.Dimension, box Box) error {
+	digp, err := db.Get(MakeDigpKey(chunkPos, dimension), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ids, err := ParseDigp(digp)
+	if err != nil {
+		return fmt.Errorf("digp of chunk %v in %v: %w", chunkPos, dimension, err)
+	}
+	for _, id := range ids {
+		val, err := db.Get(MakeActorKey(id), nil)
+		if errors.Is(err, leveldb.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		records, err := DecodeNBTRecords(val)
+		if err != nil || len(records) != 1 {
+			return fmt.Errorf("actor %x: can't decode: %v", id, err)
+		}
+		if pos, ok := EntityBlockPos(records[0].Data); ok && box.Contains(pos) {
+			s.Entities = append(s.Entities, records[0].Data)
+		}
+	}
+	return nil
+}
+
+// EntityBlockPos returns the block position of an entity's "Pos".
+func EntityBlockPos(data map[string]any) (cube.Pos, bool) {
+	pos, ok := data["Pos"].([]any)
+	if !ok || len(pos) != 3 {
+		return cube.Pos{}, false
+	}
+	var res cube.Pos
+	for i, p := range pos {
+		f, ok := p.(float32)
+		if !ok {
+			return cube.Pos{}, false
+		}
+		res[i] = int(math.Floor(float64(f)))
+	}
+	return res, true
+}
+
+// StructureTemplate is a structure saved in the world with a structure
+// block, under a structuretemplate_<name> key.
+type StructureTemplate struct {
+	Key           string `json:"key"`
+	Name          string `json:"name"` // eg. "mystructure:house"
+	Size          [3]int `json:"size"`
+	Blocks        int    `json:"blocks"` // Non-void blocks
+	BlockEntities int    `json:"block_entities"`
+	Entities      int    `json:"entities"`
+}
+
+// StructureTemplateName returns the structure name in a
+// structuretemplate_ key.
+func StructureTemplateName(key []byte) (string, bool) {
+	return strings.CutPrefix(string(key), structureTemplateKeyPrefix)
+}
+
+// ReadStructureTemplates returns the structure templates saved in the
+// world, in key order, with their values. Templates that fail to
+// decode are returned as errors alongside the others.
+func ReadStructureTemplates(db *leveldb.DB) ([]StructureTemplate, [][]byte, []error) {
+	var res []StructureTemplate
+	var vals [][]byte
+	var errs []error
+	iter := db.NewIterator(util.BytesPrefix([]byte(structureTemplateKeyPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		name, _ := StructureTemplateName(iter.Key())
+		s, err := ParseStructure(iter.Value())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("structure %q: %w", name, err))
+			continue
+		}
+		res = append(res, StructureTemplate{
+			Key:           string(iter.Key()),
+			Name:          name,
+			Size:          s.Size,
+			Blocks:        s.BlockCount(),
+			BlockEntities: len(s.BlockEntities),
+			Entities:      len(s.Entities),
+		})
+		vals = append(vals, slices.Clone(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		errs = append(errs, err)
+	}
+	return res, vals, errs
+}
@@ -0,0 +1,70 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+func TestStructureRoundTrip(t *testing.T) {
+	s := NewStructure([3]int{2, 3, 4}, cube.Pos{10, 64, -5})
+	stone, _ := NewBlock("stone")
+	water, _ := NewBlock("water", "liquid_depth=0")
+	fence, _ := NewBlock("oak_fence")
+	s.Indices[0][s.Index(0, 0, 0)] = s.PaletteIndex(stone)
+	s.Indices[0][s.Index(1, 2, 3)] = s.PaletteIndex(fence)
+	s.Indices[1][s.Index(1, 2, 3)] = s.PaletteIndex(water)
+	s.BlockEntities[s.Index(0, 0, 0)] = map[string]any{"id": "Sign", "x": int32(10), "y": int32(64), "z": int32(-5)}
+	s.Entities = []map[string]any{{"identifier": "minecraft:pig", "Pos": []any{float32(10.5), float32(64), float32(-4.5)}}}
+
+	for _, pos := range []cube.Pos{{0, 0, 0}, {1, 2, 3}, {1, 0, 2}} {
+		if got := s.Pos(s.Index(pos[0], pos[1], pos[2])); got != pos {
+			t.Errorf("want Pos(Index(%v)) = %v; got %v", pos, pos, got)
+		}
+	}
+
+	val, err := s.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseStructure(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Size != s.Size || got.Origin != s.Origin || !reflect.DeepEqual(got.Indices, s.Indices) {
+		t.Errorf("want size %v, origin %v, indices %v; got %v, %v, %v", s.Size, s.Origin, s.Indices, got.Size, got.Origin, got.Indices)
+	}
+	if name := got.Block(1, 2, 3, 1)["name"]; name != "minecraft:water" || got.Block(0, 1, 0, 0) != nil {
+		t.Errorf("want water in layer 1 at 1,2,3 and void at 0,1,0; got %v and %v", name, got.Block(0, 1, 0, 0))
+	}
+	if got.BlockCount() != 2 || len(got.BlockEntities) != 1 || got.BlockEntities[0]["id"] != "Sign" {
+		t.Errorf("got %d blocks and block entities %v", got.BlockCount(), got.BlockEntities)
+	}
+	if len(got.Entities) != 1 || got.Entities[0]["identifier"] != "minecraft:pig" {
+		t.Errorf("got entities %v", got.Entities)
+	}
+	if pos, ok := EntityBlockPos(got.Entities[0]); !ok || pos != (cube.Pos{10, 64, -5}) {
+		t.Errorf("want entity at 10,64,-5; got %v, %v", pos, ok)
+	}
+}
+
+func TestStructureBlocks(t *testing.T) {
+	for _, tc := range []struct {
+		size [3]int
+		want int
+		ok   bool
+	}{
+		{[3]int{2, 3, 4}, 24, true},
+		{[3]int{0, 5, 5}, 0, true},
+		{[3]int{0, 1 << 30, 1 << 30}, 0, false},
+		{[3]int{256, 256, 256}, MaxStructureBlocks, true},
+		{[3]int{256, 256, 257}, 0, false},
+		// The product wraps around to 0 in 64 bits.
+		{[3]int{1 << 31, 1 << 31, 1 << 2}, 0, false},
+	} {
+		if got, ok := structureBlocks(tc.size); got != tc.want || ok != tc.ok {
+			t.Errorf("structureBlocks(%v): want %d, %v; got %d, %v", tc.size, tc.want, tc.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package parse
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// tickingAreaKeyPrefix is the prefix of ticking area keys, which end
+// in the area's UUID.
+const tickingAreaKeyPrefix = "tickingarea_"
+
+// TickingArea is a ticking area added with /tickingarea, which keeps
+// its chunks loaded while no player is near them.
+type TickingArea struct {
+	Key       string `json:"key"`
+	ID        string `json:"id"` // The UUID in the key
+	Name      string `json:"name"`
+	Dimension string `json:"dimension"`
+	// The area's bounds in block coordinates, inclusive. A circular
+	// area is the circle inscribed in them.
+	MinX     int32 `json:"min_x"`
+	MinZ     int32 `json:"min_z"`
+	MaxX     int32 `json:"max_x"`
+	MaxZ     int32 `json:"max_z"`
+	IsCircle bool  `json:"is_circle,omitempty"`
+	Preload  bool  `json:"preload,omitempty"`
+
+	dimension world.Dimension
+	data      map[string]any // The full record, for re-encoding
+}
+
+// MakeTickingAreaKey returns the key of the ticking area with the
+// given UUID.
+func MakeTickingAreaKey(id string) []byte {
+	return []byte(tickingAreaKeyPrefix + id)
+}
+
+// NewTickingArea returns a ticking area covering the given chunk area
+// of a dimension.
+func NewTickingArea(id, name string, dimension world.Dimension, area ChunkArea, isCircle, preload bool) TickingArea {
+	t := TickingArea{Key: string(MakeTickingAreaKey(id)), ID: id, Name: name, IsCircle: isCircle, Preload: preload}
+	t.SetDimension(dimension)
+	t.SetArea(area)
+	return t
+}
+
+// ParseTickingArea decodes a tickingarea_<uuid> record.
+func ParseTickingArea(kv *KeyVal) (TickingArea, error) {
+	if kv.KeyType() != KeyTypeTickingArea {
+		return TickingArea{}, fmt.Errorf("can't parse a ticking area from a %s key", kv.KeyType())
+	}
+	records, err := DecodeNBTRecords(kv.Val)
+	if err != nil {
+		return TickingArea{}, err
+	}
+	if len(records) != 1 {
+		return TickingArea{}, fmt.Errorf("want one NBT compound for ticking area %q; got %d", kv.Key, len(records))
+	}
+	data := records[0].Data
+
+	t := TickingArea{
+		Key:      string(kv.Key),
+		ID:       strings.TrimPrefix(string(kv.Key), tickingAreaKeyPrefix),
+		IsCircle: nbtBool(data["IsCircle"]),
+		Preload:  nbtBool(data["Preload"]),
+		data:     data,
+	}
+	t.Name, _ = data["Name"].(string)
+	t.MinX, _ = data["MinX"].(int32)
+	t.MinZ, _ = data["MinZ"].(int32)
+	t.MaxX, _ = data["MaxX"].(int32)
+	t.MaxZ, _ = data["MaxZ"].(int32)
+	dimensionID, _ := data["Dimension"].(int32)
+	dimension, ok := world.DimensionByID(int(dimensionID))
+	if !ok {
+		return TickingArea{}, fmt.Errorf("ticking area %q has unknown dimension %d", kv.Key, dimensionID)
+	}
+	t.SetDimension(dimension)
+	return t, nil
+}
+
+// Encode returns the area as a tickingarea_ record. Fields of the
+// original record that TickingArea doesn't know are kept.
+func (t TickingArea) Encode() ([]byte, error) {
+	data := maps.Clone(t.data)
+	if data == nil {
+		data = make(map[string]any)
+	}
+	dimensionID, _ := world.DimensionID(t.dimension)
+	data["Name"] = t.Name
+	data["Dimension"] = int32(dimensionID)
+	data["MinX"], data["MinZ"] = t.MinX, t.MinZ
+	data["MaxX"], data["MaxZ"] = t.MaxX, t.MaxZ
+	data["IsCircle"] = boolByte(t.IsCircle)
+	data["Preload"] = boolByte(t.Preload)
+	return nbt.MarshalEncoding(data, nbt.LittleEndian)
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WorldDimension returns the dimension the area is in.
+func (t TickingArea) WorldDimension() world.Dimension {
+	return t.dimension
+}
+
+// SetDimension moves the area to the given dimension.
+func (t *TickingArea) SetDimension(dimension world.Dimension) {
+	t.dimension = dimension
+	t.Dimension = fmt.Sprint(dimension)
+}
+
+// Area returns the chunks the area's bounds touch.
+func (t TickingArea) Area() ChunkArea {
+	return ChunkArea{
+		Min: world.ChunkPos{t.MinX >> 4, t.MinZ >> 4},
+		Max: world.ChunkPos{t.MaxX >> 4, t.MaxZ >> 4},
+	}
+}
+
+// SetArea sets the area's bounds to cover the given chunks.
+func (t *TickingArea) SetArea(area ChunkArea) {
+	t.MinX, t.MinZ = area.Min.X()*16, area.Min.Z()*16
+	t.MaxX, t.MaxZ = area.Max.X()*16+15, area.Max.Z()*16+15
+}
+
+// Contains reports whether the area keeps the chunk loaded. For a
+// circular area, that's when the chunk's centre is inside the circle.
+func (t TickingArea) Contains(chunkPos world.ChunkPos) bool {
+	if !t.Area().Contains(chunkPos) {
+		return false
+	}
+	if !t.IsCircle {
+		return true
+	}
+	// Work in half-blocks, so centres are whole numbers.
+	cx, cz := int64(t.MinX)+int64(t.MaxX)+1, int64(t.MinZ)+int64(t.MaxZ)+1
+	r := min(int64(t.MaxX)-int64(t.MinX), int64(t.MaxZ)-int64(t.MinZ)) + 1
+	dx := int64(chunkPos.X())*32 + 16 - cx
+	dz := int64(chunkPos.Z())*32 + 16 - cz
+	return dx*dx+dz*dz <= r*r
+}
+
+// ChunkCount returns the number of chunks the area keeps loaded.
+func (t TickingArea) ChunkCount() int {
+	area := t.Area()
+	n := 0
+	for x := area.Min.X(); x <= area.Max.X(); x++ {
+		for z := area.Min.Z(); z <= area.Max.Z(); z++ {
+			if t.Contains(world.ChunkPos{x, z}) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Matches reports whether name is the area's name or UUID.
+func (t TickingArea) Matches(name string) bool {
+	return t.Name == name || t.ID == name
+}
+
+// ReadTickingAreas returns every ticking area in the world, sorted by
+// dimension and name. Areas that fail to decode are returned as errors
+// alongside the others.
+func ReadTickingAreas(db *leveldb.DB) ([]TickingArea, []error) {
+	var res []TickingArea
+	var errs []error
+	iter := db.NewIterator(util.BytesPrefix([]byte(tickingAreaKeyPrefix)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		t, err := ParseTickingArea(NewKeyVal(iter.Key(), iter.Value()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res = append(res, t)
+	}
+	if err := iter.Error(); err != nil {
+		errs = append(errs, err)
+	}
+	slices.SortFunc(res, func(a, b TickingArea) int {
+		return cmp.Or(strings.Compare(a.Dimension, b.Dimension), strings.Compare(a.Name, b.Name), strings.Compare(a.ID, b.ID))
+	})
+	return res, errs
+}
@@ -0,0 +1,53 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+)
+
+func TestTickingAreaRoundTrip(t *testing.T) {
+	val := marshalNBT(t, map[string]any{
+		"Name":      "farm",
+		"Dimension": int32(1),
+		"MinX":      int32(-16),
+		"MinZ":      int32(0),
+		"MaxX":      int32(31),
+		"MaxZ":      int32(15),
+		"IsCircle":  uint8(0),
+		"Preload":   uint8(1),
+		"Extra":     int32(9),
+	})
+	kv := NewKeyVal(MakeTickingAreaKey("abcd"), val)
+	if kt := kv.KeyType(); kt != KeyTypeTickingArea {
+		t.Fatalf("want key type %s; got %s", KeyTypeTickingArea, kt)
+	}
+	ta, err := ParseTickingArea(kv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ta.ID != "abcd" || ta.Name != "farm" || ta.WorldDimension() != world.Nether || !ta.Preload {
+		t.Errorf("got %+v", ta)
+	}
+	if want := (ChunkArea{Min: world.ChunkPos{-1, 0}, Max: world.ChunkPos{1, 0}}); ta.Area() != want || ta.ChunkCount() != 3 {
+		t.Errorf("want area %v of 3 chunks; got %v of %d", want, ta.Area(), ta.ChunkCount())
+	}
+
+	ta.SetArea(ChunkArea{Min: world.ChunkPos{-2, -2}, Max: world.ChunkPos{2, 2}})
+	ta.IsCircle = true
+	val, err = ta.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseTickingArea(NewKeyVal(kv.Key, val))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MinX != -32 || got.MaxZ != 47 || !got.IsCircle || got.data["Extra"] != int32(9) {
+		t.Errorf("got %+v after round trip", got)
+	}
+	// A radius-2 circle covers the 5x5 chunks but the corners.
+	if got.ChunkCount() != 21 || got.Contains(world.ChunkPos{2, 2}) || !got.Contains(world.ChunkPos{2, 0}) {
+		t.Errorf("want 21 chunks, not including the corners; got %d", got.ChunkCount())
+	}
+}
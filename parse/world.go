@@ -1,6 +1,9 @@
 package parse
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/df-mc/goleveldb/leveldb"
 )
@@ -22,3 +25,19 @@ func GetOccupiedChunkCoordinates(db *leveldb.DB) map[world.Dimension]map[world.C
 	}
 	return res
 }
+
+// DimensionByName returns the Dimension with the given name. It
+// accepts the names world.Dimension's String method returns, in any
+// case, as well as "over", "the_end", and "TheEnd" (as used in
+// village keys).
+func DimensionByName(name string) (world.Dimension, error) {
+	switch strings.ToLower(name) {
+	case "overworld", "over":
+		return world.Overworld, nil
+	case "nether":
+		return world.Nether, nil
+	case "end", "the_end", "theend":
+		return world.End, nil
+	}
+	return nil, fmt.Errorf("unknown dimension %q; want overworld, nether, or end", name)
+}
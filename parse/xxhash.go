@@ -0,0 +1,77 @@
+package parse
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// The primes of the XXH64 algorithm, from
+// https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md. They
+// are variables so arithmetic on them wraps instead of overflowing.
+var (
+	xxPrime1 uint64 = 0x9E3779B185EBCA87
+	xxPrime2 uint64 = 0xC2B2AE3D27D4EB4F
+	xxPrime3 uint64 = 0x165667B19E3779F9
+	xxPrime4 uint64 = 0x85EBCA77C2B2AE63
+	xxPrime5 uint64 = 0x27D4EB2F165667C5
+)
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
+
+// xxhash64 returns the XXH64 hash of data, with a seed of 0, as used by
+// CheckSums records.
+func xxhash64(data []byte) uint64 {
+	n := len(data)
+	var h uint64
+	if n >= 32 {
+		v1 := xxPrime1 + xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := -xxPrime1
+		for len(data) >= 32 {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(data[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(data[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(data[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(data[24:32]))
+			data = data[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += uint64(n)
+
+	for ; len(data) >= 8; data = data[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		data = data[4:]
+	}
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
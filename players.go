@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+func runPlayers(args []string) error {
+	f := newFlags("players")
+	items := f.Bool("items", false, "also list inventory, armor and ender chest contents")
+	f.Parse(args)
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	players, errs := parse.ReadPlayers(w.DB)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if *f.json {
+		if players == nil {
+			players = []parse.Player{}
+		}
+		return writeJSON(players)
+	}
+	if len(players) == 0 {
+		fmt.Printf("No players in %q\n", w.Name)
+	}
+	for _, p := range players {
+		fmt.Printf("%s (%s)\n", p.Name(), p.Key)
+		fmt.Printf("  position: %v in %s\n", p.BlockPos(), p.Dimension)
+		if p.Spawn != nil {
+			fmt.Printf("  spawn: %v in %s\n", *p.Spawn, p.SpawnDimension)
+		}
+		fmt.Printf("  game mode: %s, level %d (%.0f%%)\n", p.GameMode, p.Level, p.LevelProgress*100)
+		if !*items {
+			fmt.Printf("  %d inventory, %d ender chest items\n", len(p.Inventory)+len(p.Armor)+len(p.Offhand), len(p.EnderChest))
+			continue
+		}
+		for _, list := range []struct {
+			name  string
+			items []parse.Item
+		}{
+			{"inventory", p.Inventory},
+			{"armor", p.Armor},
+			{"offhand", p.Offhand},
+			{"ender chest", p.EnderChest},
+		} {
+			if len(list.items) == 0 {
+				continue
+			}
+			fmt.Printf("  %s:\n", list.name)
+			for _, item := range list.items {
+				fmt.Printf("    %2d: %dx %s\n", item.Slot, item.Count, item.Name)
+			}
+		}
+	}
+	return nil
+}
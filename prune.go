@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/edit"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// keepFunc returns the KeepFunc that keeps the given areas of -dim and
+// everything in other dimensions, or nil if there are no areas.
+func (f *flags) keepFunc(keep parse.ChunkSelection) (save.KeepFunc, error) {
+	if len(keep) == 0 {
+		return nil, nil
+	}
+	dimension, err := f.dimension()
+	if err != nil {
+		return nil, err
+	}
+	return func(dim world.Dimension, pos world.ChunkPos) bool {
+		return dim != dimension || keep.Contains(pos)
+	}, nil
+}
+
+func runPrune(args []string) error {
+	f := newEditFlags("prune")
+	f.addDim("overworld", false)
+	var keep parse.ChunkSelection
+	f.Var(&keep, "keep", "keep only these chunks of -dim, as x1,z1,x2,z2 in chunk coordinates (repeatable); other dimensions are kept whole")
+	dropMaps := f.Bool("drop-maps", false, "also drop map items centred in dropped chunks")
+	out := f.String("o", "", "write a pruned copy to this world folder or .mcworld file, instead of pruning in place")
+	f.Parse(args)
+
+	keepFunc, err := f.keepFunc(keep)
+	if err != nil {
+		return err
+	}
+	if keepFunc == nil {
+		return usageErrorf("prune needs at least one -keep area")
+	}
+	opts := save.PruneOptions{DropMaps: *dropMaps}
+
+	if *out == "" {
+		return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+			return edit.PruneChunks(db, keepFunc, opts, fmt.Sprintf("Prune %s to %v", *f.dimName, keep))
+		})
+	}
+
+	if *f.dryRun || *f.noSnapshot || *f.compact {
+		return usageErrorf("-dry-run, -no-snapshot and -compact only apply when pruning in place")
+	}
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	var stats save.PruneStats
+	if save.IsMCWorld(*out) {
+		stats, err = save.Export(w, *out, keepFunc, opts)
+	} else {
+		stats, err = save.PruneToFolder(w, *out, keepFunc, opts)
+	}
+	if err != nil {
+		return err
+	}
+	if *f.json {
+		return writeJSON(stats)
+	}
+	fmt.Printf("Pruned copy of %q written to %s: %v\n", w.Name, *out, stats)
+	return nil
+}
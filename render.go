@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/resources"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// maxRenderPixels limits the size of rendered images, so a typo in
+// -chunks doesn't try to allocate gigabytes.
+const maxRenderPixels = 1 << 28
+
+// blockRenderer returns the image of one block: 16x16 pixels for
+// -scale 16, or 1x1 for -scale 1.
+type blockRenderer struct {
+	textureSource *resources.TextureSource
+	scale         int
+	cache         map[string]*image.RGBA
+}
+
+func (r *blockRenderer) get(block map[string]any) (*image.RGBA, error) {
+	key := fmt.Sprint(block)
+	if img, ok := r.cache[key]; ok {
+		return img, nil
+	}
+	var img *image.RGBA
+	if r.textureSource == nil {
+		img = image.NewRGBA(image.Rect(0, 0, r.scale, r.scale))
+		draw.Draw(img, img.Bounds(), image.NewUniform(nameColor(block)), image.Point{}, draw.Src)
+	} else {
+		texture, err := r.textureSource.Get(block)
+		if texture == nil {
+			return nil, err
+		}
+		img = texture
+		if r.scale == 1 {
+			img = image.NewRGBA(image.Rect(0, 0, 1, 1))
+			img.Set(0, 0, averageColor(texture))
+		}
+	}
+	r.cache[key] = img
+	return img, nil
+}
+
+// nameColor returns an arbitrary but stable color for a block, based
+// on a hash of its name.
+func nameColor(block map[string]any) color.RGBA {
+	h := fnv.New32a()
+	fmt.Fprint(h, block["name"])
+	sum := h.Sum32()
+	return color.RGBA{R: uint8(sum), G: uint8(sum >> 8), B: uint8(sum >> 16), A: 0xFF}
+}
+
+// averageColor returns the average color of an image.
+func averageColor(img *image.RGBA) color.RGBA {
+	var r, g, b, a, n uint64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			r, g, b, a = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), a+uint64(c.A)
+			n++
+		}
+	}
+	if n == 0 {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}
+}
+
+func runRender(args []string) error {
+	f := newFlags("render")
+	f.addDim("overworld", false)
+	areaString := f.String("chunks", "", "area to render, as x1,z1,x2,z2 in chunk coordinates")
+	out := f.String("o", "", "PNG file to write")
+	scale := f.Int("scale", 1, "pixels per block: 1 (average texture color) or 16 (full texture)")
+	noTextures := f.Bool("no-textures", false, "color blocks by a hash of their name instead of their texture, so no game assets are needed")
+	f.Parse(args)
+
+	if *areaString == "" || *out == "" {
+		return usageErrorf("render needs -chunks and -o")
+	}
+	area, err := parse.ParseChunkArea(*areaString)
+	if err != nil {
+		return usageError{err}
+	}
+	if *scale != 1 && *scale != 16 {
+		return usageErrorf("want -scale 1 or 16; got %d", *scale)
+	}
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	width := int(area.Max.X()-area.Min.X()+1) * 16 * *scale
+	height := int(area.Max.Z()-area.Min.Z()+1) * 16 * *scale
+	if int64(width)*int64(height) > maxRenderPixels {
+		return usageErrorf("area %v is too big to render at scale %d", area, *scale)
+	}
+
+	renderer := &blockRenderer{scale: *scale, cache: make(map[string]*image.RGBA)}
+	if !*noTextures {
+		renderer.textureSource, err = resources.NewTextureSource(context.Background(), resources.UseOnlyCached)
+		if err != nil {
+			return fmt.Errorf("%w (download assets with the assets command, or use -no-textures)", err)
+		}
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rendered, err := renderArea(w, dimension, area, renderer, img)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if *f.json {
+		return writeJSON(map[string]any{"file": *out, "width": width, "height": height, "chunks": rendered})
+	}
+	fmt.Printf("Rendered %d chunks to %s (%dx%d)\n", rendered, *out, width, height)
+	return nil
+}
+
+// renderArea draws the top block of each column in the area into img,
+// and returns the number of chunks that had any data.
+func renderArea(w *save.World, dimension world.Dimension, area parse.ChunkArea, renderer *blockRenderer, img *image.RGBA) (int, error) {
+	occupied := w.OccupiedChunks(dimension)
+	size := 16 * renderer.scale
+	rendered := 0
+	for cz := area.Min.Z(); cz <= area.Max.Z(); cz++ {
+		for cx := area.Min.X(); cx <= area.Max.X(); cx++ {
+			chunkPos := world.ChunkPos{cx, cz}
+			if !occupied[chunkPos] {
+				continue
+			}
+			chunk, err := w.Chunk(chunkPos, dimension)
+			if err != nil {
+				return rendered, err
+			}
+			if chunk.Empty() {
+				continue
+			}
+			rendered++
+			hm := chunk.GetHeightMap(0)
+			originX := int(cx-area.Min.X()) * size
+			originZ := int(cz-area.Min.Z()) * size
+			for z := range 16 {
+				for x := range 16 {
+					y := hm.Get(x, z)
+					if y == parse.NoHeight {
+						continue
+					}
+					block, err := chunk.GetBlock(x, z, y, 0)
+					if err != nil {
+						return rendered, err
+					}
+					if block == nil {
+						continue
+					}
+					blockImg, err := renderer.get(block)
+					if err != nil {
+						return rendered, err
+					}
+					at := image.Pt(originX+x*renderer.scale, originZ+z*renderer.scale)
+					draw.Draw(img, image.Rectangle{at, at.Add(image.Pt(renderer.scale, renderer.scale))}, blockImg, blockImg.Bounds().Min, draw.Over)
+				}
+			}
+		}
+	}
+	return rendered, nil
+}
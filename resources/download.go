@@ -237,11 +237,11 @@ func LatestJavaReleaseClientZip(ctx context.Context) (*zip.ReadCloser, error) {
 
 	sha := match[1]
 
-	cacheDir, err := os.UserCacheDir()
+	cacheDir, err := CacheDir()
 	if err != nil {
 		return nil, err
 	}
-	filename := filepath.Join(cacheDir, "bedrockprune", "java-"+sha+".jar")
+	filename := filepath.Join(cacheDir, "java-"+sha+".jar")
 
 	exists, err := FileExists(filename)
 	if err != nil {
@@ -396,11 +396,11 @@ func LatestBedrockReleaseClientZip(ctx context.Context) (*zip.ReadCloser, error)
 		return nil, err
 	}
 
-	cacheDir, err := os.UserCacheDir()
+	cacheDir, err := CacheDir()
 	if err != nil {
 		return nil, err
 	}
-	filename := filepath.Join(cacheDir, "bedrockprune", "bedrock-"+path.Base(clientURL))
+	filename := filepath.Join(cacheDir, "bedrock-"+path.Base(clientURL))
 
 	exists, err := FileExists(filename)
 	if err != nil {
@@ -442,12 +442,20 @@ func LatestBedrockReleaseClientZip(ctx context.Context) (*zip.ReadCloser, error)
 	return zip.OpenReader(filename)
 }
 
-func LatestCachedZip(prefix, suffix string) (*zip.ReadCloser, error) {
+// CacheDir returns the directory downloaded game assets are cached in.
+func CacheDir() (string, error) {
 	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, "bedrockprune"), nil
+}
+
+func LatestCachedZip(prefix, suffix string) (*zip.ReadCloser, error) {
+	myCacheDir, err := CacheDir()
 	if err != nil {
 		return nil, err
 	}
-	myCacheDir := filepath.Join(cacheDir, "bedrockprune")
 
 	files, err := os.ReadDir(myCacheDir)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"image/png"
 	"io"
 	"io/fs"
+	"os"
 	"path"
 	"regexp"
 	"slices"
@@ -219,7 +220,7 @@ func NewTextureSource(ctx context.Context, downloadMode downloadMode) (*TextureS
 	}
 
 	for _, rp := range rps {
-		fmt.Printf("resource pack: %s\n", path.Base(rp.basepath))
+		fmt.Fprintf(os.Stderr, "resource pack: %s\n", path.Base(rp.basepath))
 	}
 
 	missing, err := getPng(bedrockMissingTexturePath, bedrockZip)
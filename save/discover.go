@@ -0,0 +1,127 @@
+package save
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WorldInfo describes a world found by Discover.
+type WorldInfo struct {
+	Dir        string
+	Name       string
+	Size       int64 // Total size of the world folder, in bytes
+	LastPlayed time.Time
+}
+
+// DefaultRoots returns the folders that are searched for worlds given
+// by name: ./worlds, and on Windows, the game's own minecraftWorlds
+// folder.
+func DefaultRoots() []string {
+	roots := []string{"./worlds"}
+	if runtime.GOOS == "windows" {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			roots = append(roots, filepath.Join(local, "Packages", "Microsoft.MinecraftUWP_8wekyb3d8bbwe", "LocalState", "games", "com.mojang", "minecraftWorlds"))
+		}
+	}
+	return roots
+}
+
+// IsWorldDir reports whether dir looks like a world folder: one with
+// a db folder inside.
+func IsWorldDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "db"))
+	return err == nil && info.IsDir()
+}
+
+// Discover lists the worlds in root, which can be a minecraftWorlds
+// folder (one world per subfolder), a dedicated server folder (with
+// its worlds in a worlds/ subfolder), or a single world folder. The
+// worlds are sorted by name.
+func Discover(root string) ([]WorldInfo, error) {
+	if IsWorldDir(root) {
+		info, err := describeWorld(root)
+		if err != nil {
+			return nil, err
+		}
+		return []WorldInfo{info}, nil
+	}
+	if bds := filepath.Join(root, "worlds"); !IsWorldDir(bds) {
+		if info, err := os.Stat(bds); err == nil && info.IsDir() {
+			root = bds
+		}
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var res []WorldInfo
+	for _, entry := range entries {
+		dir := filepath.Join(root, entry.Name())
+		if !entry.IsDir() || !IsWorldDir(dir) {
+			continue
+		}
+		info, err := describeWorld(dir)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, info)
+	}
+	slices.SortFunc(res, func(a, b WorldInfo) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), cmp.Compare(a.Dir, b.Dir))
+	})
+	return res, nil
+}
+
+func describeWorld(dir string) (WorldInfo, error) {
+	info := WorldInfo{Dir: dir}
+	levelDat, err := ReadLevelDat(filepath.Join(dir, "level.dat"))
+	if err == nil {
+		info.LastPlayed = levelDat.LastPlayed
+	} else {
+		levelDat = nil
+	}
+	info.Name = readWorldName(dir, levelDat)
+	if info.Size, err = DirSize(dir); err != nil {
+		return WorldInfo{}, err
+	}
+	return info, nil
+}
+
+// Find returns the folder or .mcworld file of the world given by
+// nameOrPath. If nameOrPath exists, it's returned as-is. Otherwise,
+// the worlds in the given roots (DefaultRoots if none are given) are
+// searched for one whose name or folder name matches, ignoring case.
+func Find(nameOrPath string, roots ...string) (string, error) {
+	if _, err := os.Stat(nameOrPath); err == nil {
+		return nameOrPath, nil
+	}
+	if len(roots) == 0 {
+		roots = DefaultRoots()
+	}
+	var matches []string
+	for _, root := range roots {
+		worlds, err := Discover(root)
+		if err != nil {
+			continue
+		}
+		for _, w := range worlds {
+			if strings.EqualFold(w.Name, nameOrPath) || strings.EqualFold(filepath.Base(w.Dir), nameOrPath) {
+				matches = append(matches, w.Dir)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no world named %q in %s", nameOrPath, strings.Join(roots, ", "))
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%d worlds named %q: %s", len(matches), nameOrPath, strings.Join(matches, ", "))
+}
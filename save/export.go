@@ -0,0 +1,279 @@
+package save
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/opt"
+	"github.com/df-mc/goleveldb/leveldb/util"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// KeepFunc reports whether the chunk at the given position should be
+// kept when pruning.
+type KeepFunc func(dimension world.Dimension, chunkPos world.ChunkPos) bool
+
+// PruneOptions are optional extras for pruning.
+type PruneOptions struct {
+	// DropMaps drops map items (map_-<id>) whose centres are in
+	// chunks that are dropped.
+	DropMaps bool
+}
+
+// PruneStats counts what a pruning copy kept and dropped.
+type PruneStats struct {
+	KeptChunks      int   `json:"kept_chunks"`
+	DroppedChunks   int   `json:"dropped_chunks"`
+	DroppedActors   int   `json:"dropped_actors"`
+	DroppedVillages int   `json:"dropped_villages"`
+	DroppedMaps     int   `json:"dropped_maps"`
+	KeptKeys        int   `json:"kept_keys"`
+	DroppedKeys     int   `json:"dropped_keys"`
+	KeptBytes       int64 `json:"kept_bytes"`
+	DroppedBytes    int64 `json:"dropped_bytes"`
+}
+
+// String summarizes the stats on one line.
+func (s PruneStats) String() string {
+	return fmt.Sprintf("kept %d chunks (%d keys, %d bytes), dropped %d chunks, %d actors, %d villages, %d maps (%d keys, %d bytes)",
+		s.KeptChunks, s.KeptKeys, s.KeptBytes, s.DroppedChunks, s.DroppedActors, s.DroppedVillages, s.DroppedMaps, s.DroppedKeys, s.DroppedBytes)
+}
+
+// copyBatchSize is the number of keys written per leveldb batch when
+// copying.
+const copyBatchSize = 1000
+
+// PruneFilter decides which keys pruning keeps. Besides chunk
+// keys, it drops the actor digests (digp) of dropped chunks, the
+// actors (actorprefix) listed only in those digests, villages whose
+// bounds don't touch any kept chunk, and, with opts.DropMaps, maps
+// centred in dropped chunks.
+type PruneFilter struct {
+	keep          KeepFunc
+	opts          PruneOptions
+	droppedActors map[string]bool
+	keptVillages  map[string]bool // By dimension and ID; villages with no INFO record are kept
+	seenVillages  map[string]bool
+}
+
+// NewPruneFilter returns a PruneFilter for db. It reads the digests
+// and village records up front, since actor keys sort before the
+// digests that list them.
+func NewPruneFilter(db *leveldb.DB, keep KeepFunc, opts PruneOptions) (*PruneFilter, error) {
+	f := &PruneFilter{
+		keep:          keep,
+		opts:          opts,
+		droppedActors: make(map[string]bool),
+		keptVillages:  make(map[string]bool),
+		seenVillages:  make(map[string]bool),
+	}
+	keptActors := make(map[string]bool)
+
+	iter := db.NewIterator(util.BytesPrefix([]byte("digp")), nil)
+	for iter.Next() {
+		chunkPos, dimension, err := parse.ParseDigpKey(iter.Key())
+		if err != nil {
+			continue
+		}
+		ids, err := parse.ParseDigp(iter.Value())
+		if err != nil {
+			iter.Release()
+			return nil, fmt.Errorf("chunk %v in %v: %w", chunkPos, dimension, err)
+		}
+		for _, id := range ids {
+			if keep(dimension, chunkPos) {
+				keptActors[string(id)] = true
+			} else {
+				f.droppedActors[string(id)] = true
+			}
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	// An actor listed in a kept digest stays, even if a dropped one
+	// lists it too.
+	for id := range keptActors {
+		delete(f.droppedActors, id)
+	}
+
+	iter = db.NewIterator(util.BytesPrefix([]byte("VILLAGE_")), nil)
+	defer iter.Release()
+	for iter.Next() {
+		vk, err := parse.ParseVillageKey(iter.Key())
+		if err != nil || vk.Record != "INFO" {
+			continue
+		}
+		id, _ := villageID(iter.Key())
+		f.seenVillages[id] = true
+		bounds, err := parse.VillageBounds(iter.Value())
+		if err != nil {
+			// Keep villages we can't make sense of.
+			f.keptVillages[id] = true
+			continue
+		}
+		for _, chunkPos := range bounds.Chunks() {
+			if keep(vk.Dimension, chunkPos) {
+				f.keptVillages[id] = true
+				break
+			}
+		}
+	}
+	return f, iter.Error()
+}
+
+// Keep reports whether the key belongs in the pruned world.
+func (f *PruneFilter) Keep(kv *parse.KeyVal) bool {
+	keyInfo := kv.KeyTypeAndChunkLocation()
+	switch {
+	case keyInfo.HasLocation:
+		return f.keep(keyInfo.Dimension, keyInfo.ChunkPos)
+	case keyInfo.KeyType == parse.KeyTypeDigp:
+		chunkPos, dimension, err := parse.ParseDigpKey(kv.Key)
+		return err != nil || f.keep(dimension, chunkPos)
+	case keyInfo.KeyType == parse.KeyTypeActorprefix:
+		id, ok := parse.ActorID(kv.Key)
+		return !ok || !f.droppedActors[string(id)]
+	}
+	if id, ok := villageID(kv.Key); ok {
+		return !f.seenVillages[id] || f.keptVillages[id]
+	}
+	if f.opts.DropMaps && kv.KeyType() == parse.KeyTypeMap {
+		m, err := parse.ParseMap(kv)
+		// Keep maps we can't make sense of.
+		return err != nil || f.keep(m.WorldDimension(), m.CenterChunk())
+	}
+	return true
+}
+
+// villageID returns the dimension and ID of the village a village
+// record key belongs to, as a single string.
+func villageID(key []byte) (string, bool) {
+	vk, err := parse.ParseVillageKey(key)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprint(vk.Dimension) + "/" + vk.ID, true
+}
+
+// PruneCopy copies db into a fresh leveldb at destDir, which must not
+// exist yet, and compacts it. All keys that don't belong to a chunk
+// are copied, except for the actor digests, actors, villages, and
+// with opts.DropMaps, maps of chunks that keep rejects (see
+// PruneFilter). db itself is not
+// changed.
+func PruneCopy(db *leveldb.DB, destDir string, keep KeepFunc, opts PruneOptions) (PruneStats, error) {
+	var stats PruneStats
+	filter, err := NewPruneFilter(db, keep, opts)
+	if err != nil {
+		return stats, err
+	}
+
+	dbOpts := DBOptions(false)
+	dbOpts.ErrorIfExist = true
+	dest, err := leveldb.OpenFile(destDir, dbOpts)
+	if err != nil {
+		return stats, fmt.Errorf("error creating leveldb: %w", err)
+	}
+	defer dest.Close()
+
+	type chunkKey struct {
+		dimension world.Dimension
+		pos       world.ChunkPos
+	}
+	kept := make(map[chunkKey]bool)
+	dropped := make(map[chunkKey]bool)
+	droppedVillages := make(map[string]bool)
+
+	batch := new(leveldb.Batch)
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &parse.KeyVal{Key: iter.Key(), Val: iter.Value()}
+		ok := filter.Keep(kv)
+		size := int64(len(kv.Key) + len(kv.Val))
+		keyInfo := kv.KeyTypeAndChunkLocation()
+		if keyInfo.HasLocation {
+			ck := chunkKey{keyInfo.Dimension, keyInfo.ChunkPos}
+			if ok {
+				kept[ck] = true
+			} else {
+				dropped[ck] = true
+			}
+		}
+		if !ok {
+			stats.DroppedKeys++
+			stats.DroppedBytes += size
+			switch keyInfo.KeyType {
+			case parse.KeyTypeActorprefix:
+				stats.DroppedActors++
+			case parse.KeyTypeMap:
+				stats.DroppedMaps++
+			}
+			if id, ok := villageID(kv.Key); ok {
+				droppedVillages[id] = true
+			}
+			continue
+		}
+		stats.KeptKeys++
+		stats.KeptBytes += size
+		batch.Put(iter.Key(), iter.Value())
+		if batch.Len() >= copyBatchSize {
+			if err := dest.Write(batch, nil); err != nil {
+				return stats, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return stats, err
+	}
+	if err := dest.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return stats, err
+	}
+	stats.KeptChunks, stats.DroppedChunks, stats.DroppedVillages = len(kept), len(dropped), len(droppedVillages)
+
+	if err := dest.CompactRange(util.Range{}); err != nil {
+		return stats, fmt.Errorf("error compacting leveldb: %w", err)
+	}
+	return stats, dest.Close()
+}
+
+// PruneToFolder writes a pruned copy of the world to a new world
+// folder destDir: everything but the db is copied as-is, and the db
+// is written by PruneCopy. The world itself is left untouched.
+func PruneToFolder(w *World, destDir string, keep KeepFunc, opts PruneOptions) (PruneStats, error) {
+	if _, err := os.Stat(destDir); err == nil {
+		return PruneStats{}, fmt.Errorf("%s already exists", destDir)
+	}
+	if err := CopyDir(w.Dir, destDir, "db"); err != nil {
+		return PruneStats{}, err
+	}
+	return PruneCopy(w.DB, filepath.Join(destDir, "db"), keep, opts)
+}
+
+// Export writes the world to a new .mcworld file at dest. If keep is
+// nil, the world is exported as-is. Otherwise only the chunks keep
+// selects are written, by PruneToFolder into a temporary folder; the
+// world itself is left untouched.
+func Export(w *World, dest string, keep KeepFunc, opts PruneOptions) (PruneStats, error) {
+	if keep == nil {
+		return PruneStats{}, WriteMCWorld(w.Dir, dest)
+	}
+
+	staging, err := os.MkdirTemp("", "bedrockprune-export-")
+	if err != nil {
+		return PruneStats{}, err
+	}
+	defer os.RemoveAll(staging)
+	stagingWorld := filepath.Join(staging, "world")
+	stats, err := PruneToFolder(w, stagingWorld, keep, opts)
+	if err != nil {
+		return stats, err
+	}
+	return stats, WriteMCWorld(stagingWorld, dest)
+}
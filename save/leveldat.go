@@ -0,0 +1,220 @@
+// Package save handles the files of a Bedrock world folder other than
+// the leveldb itself.
+package save
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// levelDatHeaderSize is the size of the header before the NBT in a
+// level.dat file: a little-endian int32 storage version, then a
+// little-endian int32 length of the NBT that follows.
+const levelDatHeaderSize = 8
+
+// MinSupportedVersion is the oldest game version whose worlds we can
+// read: sub-chunks in older worlds may predate version 9.
+var MinSupportedVersion = Version{1, 18, 0}
+
+// Version is a game version, as stored in level.dat: major, minor,
+// patch, and so on.
+type Version []int32
+
+// ParseVersion parses a dotted version like "1.20.50".
+func ParseVersion(s string) (Version, error) {
+	var res Version
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.ParseInt(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad version %q: %w", s, err)
+		}
+		res = append(res, int32(n))
+	}
+	return res, nil
+}
+
+// String returns the version in dotted form, leaving off trailing
+// zeros past the patch number.
+func (v Version) String() string {
+	for len(v) > 3 && v[len(v)-1] == 0 {
+		v = v[:len(v)-1]
+	}
+	var parts []string
+	for _, n := range v {
+		parts = append(parts, strconv.Itoa(int(n)))
+	}
+	return strings.Join(parts, ".")
+}
+
+// Compare returns -1, 0, or 1 depending on whether v is older than,
+// the same as, or newer than other. Missing parts count as zero.
+func (v Version) Compare(other Version) int {
+	for i := range max(len(v), len(other)) {
+		var a, b int32
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// LevelDat holds the contents of a world's level.dat file. The most
+// useful fields are decoded into typed fields. Data holds every field,
+// including the many we don't know about, so writing a LevelDat back
+// out keeps them all.
+type LevelDat struct {
+	HeaderVersion int32 // Storage version from the file header
+
+	LevelName                      string
+	Seed                           int64
+	Spawn                          cube.Pos
+	LastPlayed                     time.Time
+	LastOpenedWithVersion          Version
+	MinimumCompatibleClientVersion Version
+	StorageVersion                 int32
+	NetworkVersion                 int32
+	GameType                       int32
+	Difficulty                     int32
+
+	Data map[string]any
+}
+
+// ReadLevelDat reads and parses the level.dat file at path.
+func ReadLevelDat(path string) (*LevelDat, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ParseLevelDat(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
+
+// ParseLevelDat parses the contents of a level.dat file.
+func ParseLevelDat(b []byte) (*LevelDat, error) {
+	if len(b) < levelDatHeaderSize {
+		return nil, fmt.Errorf("level.dat is too short (%d bytes) to hold its header", len(b))
+	}
+	l := &LevelDat{HeaderVersion: int32(binary.LittleEndian.Uint32(b))}
+	length := int(binary.LittleEndian.Uint32(b[4:]))
+	if length != len(b)-levelDatHeaderSize {
+		return nil, fmt.Errorf("level.dat header says %d bytes of NBT; file has %d", length, len(b)-levelDatHeaderSize)
+	}
+	if err := nbt.UnmarshalEncoding(b[levelDatHeaderSize:], &l.Data, nbt.LittleEndian); err != nil {
+		return nil, fmt.Errorf("unable to decode level.dat NBT: %w", err)
+	}
+
+	l.LevelName, _ = l.Data["LevelName"].(string)
+	l.Seed, _ = l.Data["RandomSeed"].(int64)
+	x, _ := l.Data["SpawnX"].(int32)
+	y, _ := l.Data["SpawnY"].(int32)
+	z, _ := l.Data["SpawnZ"].(int32)
+	l.Spawn = cube.Pos{int(x), int(y), int(z)}
+	if lastPlayed, ok := l.Data["LastPlayed"].(int64); ok {
+		l.LastPlayed = time.Unix(lastPlayed, 0)
+	}
+	if v, ok := l.Data["lastOpenedWithVersion"].([]int32); ok {
+		l.LastOpenedWithVersion = Version(v)
+	}
+	if v, ok := l.Data["MinimumCompatibleClientVersion"].([]int32); ok {
+		l.MinimumCompatibleClientVersion = Version(v)
+	}
+	l.StorageVersion, _ = l.Data["StorageVersion"].(int32)
+	l.NetworkVersion, _ = l.Data["NetworkVersion"].(int32)
+	l.GameType, _ = l.Data["GameType"].(int32)
+	l.Difficulty, _ = l.Data["Difficulty"].(int32)
+	return l, nil
+}
+
+// Encode returns the contents of a level.dat file, with the typed
+// fields copied into Data first, so they win over what's in Data.
+// Typed fields that weren't in the original file are only added if
+// they're set.
+func (l *LevelDat) Encode() ([]byte, error) {
+	if l.Data == nil {
+		l.Data = map[string]any{}
+	}
+	l.set("LevelName", l.LevelName, l.LevelName != "")
+	l.set("RandomSeed", l.Seed, l.Seed != 0)
+	l.set("SpawnX", int32(l.Spawn.X()), l.Spawn != cube.Pos{})
+	l.set("SpawnY", int32(l.Spawn.Y()), l.Spawn != cube.Pos{})
+	l.set("SpawnZ", int32(l.Spawn.Z()), l.Spawn != cube.Pos{})
+	l.set("LastPlayed", l.LastPlayed.Unix(), !l.LastPlayed.IsZero())
+	l.set("lastOpenedWithVersion", []int32(l.LastOpenedWithVersion), l.LastOpenedWithVersion != nil)
+	l.set("MinimumCompatibleClientVersion", []int32(l.MinimumCompatibleClientVersion), l.MinimumCompatibleClientVersion != nil)
+	l.set("StorageVersion", l.StorageVersion, l.StorageVersion != 0)
+	l.set("NetworkVersion", l.NetworkVersion, l.NetworkVersion != 0)
+	l.set("GameType", l.GameType, l.GameType != 0)
+	l.set("Difficulty", l.Difficulty, l.Difficulty != 0)
+
+	data, err := nbt.MarshalEncoding(l.Data, nbt.LittleEndian)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode level.dat NBT: %w", err)
+	}
+	res := make([]byte, levelDatHeaderSize, levelDatHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(res, uint32(l.HeaderVersion))
+	binary.LittleEndian.PutUint32(res[4:], uint32(len(data)))
+	return append(res, data...), nil
+}
+
+// set sets a field in Data if it's already there, or if isSet.
+func (l *LevelDat) set(key string, value any, isSet bool) {
+	if _, ok := l.Data[key]; ok || isSet {
+		l.Data[key] = value
+	}
+}
+
+// WriteFile writes the level.dat to path. Like the game, it keeps the
+// previous file as level.dat_old alongside it. The new contents are
+// written to a temporary file first, so a failed write doesn't leave
+// a truncated level.dat behind.
+func (l *LevelDat) WriteFile(path string) error {
+	b, err := l.Encode()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(path); err == nil {
+		if err := os.WriteFile(filepath.Join(filepath.Dir(path), "level.dat_old"), old, 0o644); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// CheckSupported returns an error if the world was last opened with a
+// game version too old for us to read.
+func (l *LevelDat) CheckSupported() error {
+	if l.LastOpenedWithVersion == nil {
+		return fmt.Errorf("level.dat has no lastOpenedWithVersion; can't tell if the world is supported")
+	}
+	if l.LastOpenedWithVersion.Compare(MinSupportedVersion) < 0 {
+		return fmt.Errorf("world was last opened with version %v; need at least %v", l.LastOpenedWithVersion, MinSupportedVersion)
+	}
+	return nil
+}
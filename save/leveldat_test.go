@@ -0,0 +1,81 @@
+package save
+
+import (
+	"testing"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+)
+
+func TestLevelDatRoundTrip(t *testing.T) {
+	orig := &LevelDat{
+		HeaderVersion:         10,
+		LevelName:             "Old Name",
+		Spawn:                 cube.Pos{1, 2, 3},
+		LastOpenedWithVersion: Version{1, 20, 50, 0, 0},
+		Data: map[string]any{
+			"keepinventory": uint8(1),
+			"abilities":     map[string]any{"flySpeed": float32(0.05)},
+		},
+	}
+	b, err := orig.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ParseLevelDat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.HeaderVersion != 10 || l.LevelName != "Old Name" || l.Spawn != (cube.Pos{1, 2, 3}) {
+		t.Errorf("got header version %d, name %q, spawn %v", l.HeaderVersion, l.LevelName, l.Spawn)
+	}
+	if got, want := l.LastOpenedWithVersion.String(), "1.20.50"; got != want {
+		t.Errorf("want lastOpenedWithVersion %s; got %s", want, got)
+	}
+	if err := l.CheckSupported(); err != nil {
+		t.Error(err)
+	}
+
+	l.LevelName = "New Name"
+	l.LastPlayed = time.Unix(1700000000, 0)
+	b, err = l.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = ParseLevelDat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.LevelName != "New Name" || l.LastPlayed.Unix() != 1700000000 {
+		t.Errorf("got name %q, last played %v", l.LevelName, l.LastPlayed)
+	}
+	if l.Data["keepinventory"] != uint8(1) {
+		t.Errorf("unknown field keepinventory not preserved: %v", l.Data["keepinventory"])
+	}
+	if _, ok := l.Data["RandomSeed"]; ok {
+		t.Errorf("unset field RandomSeed was added")
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.18.0", "1.18", 0},
+		{"1.17.40", "1.18.0", -1},
+		{"1.20.50.0.0", "1.18.0", 1},
+	} {
+		a, err := ParseVersion(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s.Compare(%s) = %d; want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package save
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsMCWorld reports whether path names a .mcworld file.
+func IsMCWorld(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mcworld")
+}
+
+// ExtractMCWorld extracts the .mcworld file (a zip of the world
+// folder's contents) at path into the folder dest.
+func ExtractMCWorld(path, dest string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%s: refusing to extract %q outside the world folder", path, f.Name)
+		}
+		target := filepath.Join(dest, name)
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := extractFile(f, target); err != nil {
+			return fmt.Errorf("%s: unable to extract %q: %w", path, f.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// WriteMCWorld zips the contents of the world folder dir into a new
+// .mcworld file at dest. leveldb LOCK files are left out.
+func WriteMCWorld(dir, dest string) (err error) {
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dest)
+		}
+	}()
+
+	zw := zip.NewWriter(f)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == "LOCK" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addZipFile(zw, path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func addZipFile(zw *zip.Writer, path, name string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, in)
+	return err
+}
+
+// Import extracts the .mcworld file at path into a new world folder
+// under root (a minecraftWorlds folder, say), and returns the new
+// folder's path. The folder is named after the file.
+func Import(path, root string) (string, error) {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	dest := filepath.Join(root, base)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(dest); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		dest = filepath.Join(root, fmt.Sprintf("%s-%d", base, i))
+	}
+	if err := ExtractMCWorld(path, dest); err != nil {
+		os.RemoveAll(dest)
+		return "", err
+	}
+	if !IsWorldDir(dest) {
+		os.RemoveAll(dest)
+		return "", fmt.Errorf("%s doesn't hold a world: no db folder", path)
+	}
+	return dest, nil
+}
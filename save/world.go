@@ -0,0 +1,171 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/df-mc/goleveldb/leveldb/opt"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// World is an open Bedrock world: its leveldb, level.dat and name, and
+// a cache of parsed chunks shared by everything that reads them.
+type World struct {
+	Dir      string    // The world folder (for a .mcworld file, a temporary folder it was extracted to)
+	Source   string    // The folder or .mcworld file the world was opened from
+	Name     string    // From levelname.txt, or level.dat if that's missing
+	LevelDat *LevelDat // Nil if the world has no level.dat
+	DB       *leveldb.DB
+	ReadOnly bool
+
+	tempDir string // Removed on Close, if set
+
+	mu             sync.Mutex
+	chunkCache     map[chunkKey]*parse.Chunk
+	occupiedChunks map[world.Dimension]map[world.ChunkPos]bool
+}
+
+type chunkKey struct {
+	pos       world.ChunkPos
+	dimension world.Dimension
+}
+
+// Open opens the world in the given folder, or in the given .mcworld
+// file. A .mcworld file is extracted to a temporary folder, which is
+// removed again by Close; changes to it are lost unless exported.
+func Open(path string, readOnly bool) (*World, error) {
+	w := &World{Source: path, ReadOnly: readOnly}
+	if IsMCWorld(path) {
+		tempDir, err := os.MkdirTemp("", "bedrockprune-mcworld-")
+		if err != nil {
+			return nil, err
+		}
+		if err := ExtractMCWorld(path, tempDir); err != nil {
+			os.RemoveAll(tempDir)
+			return nil, err
+		}
+		w.Dir, w.tempDir = tempDir, tempDir
+	} else {
+		w.Dir = path
+	}
+
+	if err := w.open(); err != nil {
+		if w.tempDir != "" {
+			os.RemoveAll(w.tempDir)
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *World) open() error {
+	dbDir := filepath.Join(w.Dir, "db")
+	if _, err := os.Stat(dbDir); err != nil {
+		return fmt.Errorf("%s doesn't look like a world folder: %w", w.Dir, err)
+	}
+
+	var err error
+	w.LevelDat, err = ReadLevelDat(filepath.Join(w.Dir, "level.dat"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	w.Name = readWorldName(w.Dir, w.LevelDat)
+
+	w.DB, err = leveldb.OpenFile(dbDir, DBOptions(w.ReadOnly))
+	if err != nil {
+		return fmt.Errorf("error opening leveldb: %w", err)
+	}
+	w.chunkCache = make(map[chunkKey]*parse.Chunk)
+	return nil
+}
+
+// readWorldName returns the name in levelname.txt, falling back to the
+// one in level.dat, and then to the name of the folder.
+func readWorldName(dir string, levelDat *LevelDat) string {
+	if b, err := os.ReadFile(filepath.Join(dir, "levelname.txt")); err == nil {
+		if name := strings.TrimSpace(string(b)); name != "" {
+			return name
+		}
+	}
+	if levelDat != nil && levelDat.LevelName != "" {
+		return levelDat.LevelName
+	}
+	return filepath.Base(dir)
+}
+
+// Close closes the leveldb, and removes the temporary folder of a
+// world opened from a .mcworld file.
+func (w *World) Close() error {
+	err := w.DB.Close()
+	if w.tempDir != "" {
+		if rmErr := os.RemoveAll(w.tempDir); err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
+
+// Bounds returns the range of y coordinates of the given dimension.
+func (w *World) Bounds(dimension world.Dimension) cube.Range {
+	return dimension.Range()
+}
+
+// OccupiedChunks returns the positions of all chunks with sub-chunk
+// data in the given dimension. The result is computed once and cached,
+// so callers must not change it.
+func (w *World) OccupiedChunks(dimension world.Dimension) map[world.ChunkPos]bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.occupiedChunks == nil {
+		w.occupiedChunks = parse.GetOccupiedChunkCoordinates(w.DB)
+	}
+	return w.occupiedChunks[dimension]
+}
+
+// Chunk returns the parsed chunk at the given position, from the cache
+// if it has been read before.
+func (w *World) Chunk(chunkPos world.ChunkPos, dimension world.Dimension) (*parse.Chunk, error) {
+	key := chunkKey{chunkPos, dimension}
+	w.mu.Lock()
+	chunk, ok := w.chunkCache[key]
+	w.mu.Unlock()
+	if ok {
+		return chunk, nil
+	}
+
+	chunk, err := parse.GetChunk(w.DB, chunkPos, dimension)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.chunkCache[key] = chunk
+	w.mu.Unlock()
+	return chunk, nil
+}
+
+// InvalidateChunk drops the given chunk from the cache, so the next
+// call to Chunk reads it again. Call it after changing the chunk.
+func (w *World) InvalidateChunk(chunkPos world.ChunkPos, dimension world.Dimension) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.chunkCache, chunkKey{chunkPos, dimension})
+	w.occupiedChunks = nil
+}
+
+// DBOptions returns the leveldb options to open a world's db with.
+// Bedrock can only read tables compressed with raw zlib ("flate"), so
+// that's set explicitly, rather than relying on the default.
+func DBOptions(readOnly bool) *opt.Options {
+	return &opt.Options{
+		Compression: opt.FlateCompression,
+		BlockSize:   16 * opt.KiB,
+		ReadOnly:    readOnly,
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/zellyn/bedrockprune/parse"
+	"golang.org/x/exp/maps"
+)
+
+// stateFlags collects repeated name=value block state flags.
+type stateFlags []string
+
+func (s *stateFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stateFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func runSearch(args []string) error {
+	f := newFlags("search")
+	f.addDim("overworld", false)
+	var states stateFlags
+	blockName := f.String("block", "", `block name to search for, eg. "mob_spawner" or "minecraft:ancient_debris"`)
+	boxString := f.String("box", "", "optional box to search, as x1,y1,z1,x2,y2,z2 (inclusive)")
+	csvOut := f.Bool("csv", false, "write CSV instead of a list")
+	out := f.String("o", "", "output file (default stdout)")
+	f.Var(&states, "state", "block state to match, as name=value (repeatable)")
+	f.Parse(args)
+
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	q, err := parse.NewBlockQuery(*blockName, states...)
+	if err != nil {
+		return usageError{err}
+	}
+	box := parse.DimensionBox(dimension)
+	if *boxString != "" {
+		box, err = parse.ParseBox(*boxString)
+		if err != nil {
+			return usageError{err}
+		}
+	}
+	if *csvOut && *f.json {
+		return usageErrorf("-csv and -json can't be used together")
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	matches, errs := parse.SearchBlocks(w.DB, dimension, box, q)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Found %d matching blocks\n", len(matches))
+
+	dest := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		dest = file
+	}
+
+	switch {
+	case *csvOut:
+		return writeCSV(dest, matches)
+	case *f.json:
+		if matches == nil {
+			matches = []parse.BlockMatch{}
+		}
+		return writeJSONTo(dest, matches)
+	}
+	for _, m := range matches {
+		fmt.Fprintf(dest, "%d %d %d (layer %d): %s %s\n", m.X, m.Y, m.Z, m.Layer, m.Name, formatStates(m.States))
+	}
+	return nil
+}
+
+// formatStates formats block states as name=value pairs separated by
+// semicolons, sorted by name.
+func formatStates(blockStates map[string]any) string {
+	keys := maps.Keys(blockStates)
+	slices.Sort(keys)
+	var states []string
+	for _, k := range keys {
+		states = append(states, fmt.Sprintf("%s=%v", k, blockStates[k]))
+	}
+	return strings.Join(states, ";")
+}
+
+func writeCSV(w io.Writer, matches []parse.BlockMatch) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"dimension", "x", "y", "z", "layer", "name", "states"}); err != nil {
+		return err
+	}
+	for _, m := range matches {
+		record := []string{
+			m.Dimension,
+			strconv.Itoa(m.X),
+			strconv.Itoa(m.Y),
+			strconv.Itoa(m.Z),
+			strconv.Itoa(m.Layer),
+			m.Name,
+			formatStates(m.States),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
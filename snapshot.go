@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/zellyn/bedrockprune/edit"
+	"github.com/zellyn/bedrockprune/save"
+)
+
+// snapshotEntry is one snapshot in the output of snapshot -list.
+type snapshotEntry struct {
+	Dir  string `json:"dir"`
+	Size int64  `json:"size"`
+}
+
+func runSnapshot(args []string) error {
+	f := newFlags("snapshot")
+	list := f.Bool("list", false, "list the world's snapshots instead of making a new one")
+	f.Parse(args)
+
+	worldDir, err := f.worldDir()
+	if err != nil {
+		return err
+	}
+
+	if !*list {
+		dir, err := edit.Snapshot(worldDir)
+		if err != nil {
+			return err
+		}
+		if *f.json {
+			return writeJSON(map[string]string{"dir": dir})
+		}
+		fmt.Printf("Snapshot saved to %s\n", dir)
+		return nil
+	}
+
+	snapshotDir := edit.SnapshotDir(worldDir)
+	entries, err := os.ReadDir(snapshotDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	snapshots := []snapshotEntry{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(snapshotDir, entry.Name())
+		size, err := save.DirSize(dir)
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snapshotEntry{Dir: dir, Size: size})
+	}
+	if *f.json {
+		return writeJSON(snapshots)
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("No snapshots in %s\n", snapshotDir)
+	}
+	for _, s := range snapshots {
+		fmt.Printf("%s  %d bytes\n", s.Dir, s.Size)
+	}
+	return nil
+}
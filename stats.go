@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/zellyn/bedrockprune/parse"
+	"golang.org/x/exp/maps"
+)
+
+// keyTypeStats counts the keys of one KeyType.
+type keyTypeStats struct {
+	Keys     int   `json:"keys"`
+	KeyBytes int64 `json:"key_bytes"`
+	ValBytes int64 `json:"value_bytes"`
+}
+
+func runStats(args []string) error {
+	f := newFlags("stats")
+	f.Parse(args)
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	stats := make(map[parse.KeyType]*keyTypeStats)
+	var total keyTypeStats
+	iter := w.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		kv := &parse.KeyVal{Key: iter.Key()}
+		kt := kv.KeyType()
+		st := stats[kt]
+		if st == nil {
+			st = &keyTypeStats{}
+			stats[kt] = st
+		}
+		for _, s := range []*keyTypeStats{st, &total} {
+			s.Keys++
+			s.KeyBytes += int64(len(iter.Key()))
+			s.ValBytes += int64(len(iter.Value()))
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if *f.json {
+		res := make(map[string]*keyTypeStats)
+		for kt, st := range stats {
+			res[kt.String()] = st
+		}
+		res["total"] = &total
+		return writeJSON(res)
+	}
+	keyTypes := maps.Keys(stats)
+	slices.Sort(keyTypes)
+	for _, kt := range keyTypes {
+		st := stats[kt]
+		fmt.Printf("%-50s %8d keys %12d bytes\n", kt, st.Keys, st.KeyBytes+st.ValBytes)
+	}
+	fmt.Printf("%-50s %8d keys %12d bytes\n", "total", total.Keys, total.KeyBytes+total.ValBytes)
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/edit"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// structureFileName returns the file name to extract a structure
+// template to: its name, with the namespace separator replaced, since
+// ':' isn't allowed in Windows file names.
+func structureFileName(name string) string {
+	return strings.ReplaceAll(name, ":", "_") + ".mcstructure"
+}
+
+// parsePos parses a block position given as "x,y,z".
+func parsePos(s string) (cube.Pos, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 3 {
+		var pos cube.Pos
+		ok := true
+		for i, part := range parts {
+			c, err := strconv.Atoi(strings.TrimSpace(part))
+			ok = ok && err == nil
+			pos[i] = c
+		}
+		if ok {
+			return pos, nil
+		}
+	}
+	return cube.Pos{}, usageErrorf("want block position as x,y,z; got %q", s)
+}
+
+func runStructure(args []string) error {
+	f := newEditFlags("structure")
+	f.addDim("overworld", false)
+	export := f.String("export", "", "write the blocks, block entities and entities in -box to this .mcstructure file")
+	importFile := f.String("import", "", "place the structure in this .mcstructure file in -dim, at -at")
+	at := f.String("at", "", "with -import, where to put the structure's minimum corner, as x,y,z (default: where it was exported from)")
+	boxString := f.String("box", "", "with -export, the box to export, as x1,y1,z1,x2,y2,z2 (inclusive)")
+	extract := f.String("extract", "", "write the structure templates saved in the world to .mcstructure files in this folder")
+	name := f.String("name", "", "with -extract, only extract the structure template with this name, eg. mystructure:house")
+	f.Parse(args)
+	ops := 0
+	for _, op := range []string{*export, *importFile, *extract} {
+		if op != "" {
+			ops++
+		}
+	}
+	if ops > 1 {
+		return usageErrorf("use only one of -export, -import and -extract")
+	}
+
+	if *importFile != "" {
+		val, err := os.ReadFile(*importFile)
+		if err != nil {
+			return err
+		}
+		s, err := parse.ParseStructure(val)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *importFile, err)
+		}
+		origin := s.Origin
+		if *at != "" {
+			if origin, err = parsePos(*at); err != nil {
+				return err
+			}
+		}
+		dimension, err := f.dimension()
+		if err != nil {
+			return err
+		}
+		return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+			return edit.PlaceStructure(db, s, dimension, origin)
+		})
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if *export != "" {
+		if *boxString == "" {
+			return usageErrorf("-export needs a -box")
+		}
+		box, err := parse.ParseBox(*boxString)
+		if err != nil {
+			return usageError{err}
+		}
+		dimension, err := f.dimension()
+		if err != nil {
+			return err
+		}
+		s, err := parse.ReadStructure(w.DB, dimension, box)
+		if err != nil {
+			return err
+		}
+		val, err := s.Encode()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*export, val, 0o644); err != nil {
+			return err
+		}
+		if *f.json {
+			return writeJSON(map[string]any{
+				"file":           *export,
+				"size":           s.Size,
+				"blocks":         s.BlockCount(),
+				"block_entities": len(s.BlockEntities),
+				"entities":       len(s.Entities),
+			})
+		}
+		fmt.Printf("Exported %v, box %s (%dx%dx%d, %d blocks, %d block entities, %d entities) to %s\n",
+			dimension, box, s.Size[0], s.Size[1], s.Size[2], s.BlockCount(), len(s.BlockEntities), len(s.Entities), *export)
+		return nil
+	}
+
+	templates, vals, errs := parse.ReadStructureTemplates(w.DB)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if *extract != "" {
+		if err := os.MkdirAll(*extract, 0o755); err != nil {
+			return err
+		}
+	}
+	type entry struct {
+		parse.StructureTemplate
+		File string `json:"file,omitempty"`
+	}
+	entries := []entry{}
+	for i, t := range templates {
+		if *name != "" && t.Name != *name {
+			continue
+		}
+		e := entry{StructureTemplate: t}
+		if *extract != "" {
+			e.File = filepath.Join(*extract, structureFileName(t.Name))
+			if err := os.WriteFile(e.File, vals[i], 0o644); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+	}
+	if *name != "" && len(entries) == 0 {
+		return fmt.Errorf("no structure template named %q", *name)
+	}
+
+	if *f.json {
+		return writeJSON(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No structure templates in %q\n", w.Name)
+	}
+	for _, e := range entries {
+		fmt.Printf("%s: %dx%dx%d, %d blocks, %d block entities, %d entities", e.Name, e.Size[0], e.Size[1], e.Size[2], e.Blocks, e.BlockEntities, e.Entities)
+		if e.File != "" {
+			fmt.Printf(" -> %s", e.File)
+		}
+		fmt.Println()
+	}
+	return nil
+}
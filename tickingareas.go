@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	"github.com/zellyn/bedrockprune/edit"
+	"github.com/zellyn/bedrockprune/parse"
+)
+
+// tickingAreaEntry is one ticking area in the output of the
+// tickingareas command.
+type tickingAreaEntry struct {
+	parse.TickingArea
+	Chunks int `json:"chunks"`
+}
+
+// parseCircle parses a circular area given as "x,z,r": a centre chunk
+// and a radius in chunks.
+func parseCircle(s string) (parse.ChunkArea, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 3 {
+		var coords [3]int32
+		ok := true
+		for i, part := range parts {
+			c, err := strconv.ParseInt(strings.TrimSpace(part), 10, 32)
+			ok = ok && err == nil
+			coords[i] = int32(c)
+		}
+		if ok && coords[2] >= 0 {
+			x, z, r := coords[0], coords[1], coords[2]
+			return parse.ChunkArea{Min: world.ChunkPos{x - r, z - r}, Max: world.ChunkPos{x + r, z + r}}, nil
+		}
+	}
+	return parse.ChunkArea{}, usageErrorf("want circle as x,z,r in chunk coordinates; got %q", s)
+}
+
+func runTickingAreas(args []string) error {
+	f := newEditFlags("tickingareas")
+	f.addDim("overworld", false)
+	add := f.String("add", "", "add a ticking area with this name in -dim, covering -area or -circle")
+	remove := f.String("remove", "", "remove the ticking area with this name or UUID")
+	resize := f.String("resize", "", "change the ticking area with this name or UUID to cover -area or -circle")
+	areaString := f.String("area", "", "with -add or -resize, the chunks to cover, as x1,z1,x2,z2 in chunk coordinates")
+	circleString := f.String("circle", "", "with -add or -resize, the circle of chunks to cover, as x,z,r: a centre chunk and radius in chunks")
+	preload := f.Bool("preload", false, "with -add, load the area's chunks before the world starts")
+	f.Parse(args)
+
+	ops := 0
+	for _, op := range []string{*add, *remove, *resize} {
+		if op != "" {
+			ops++
+		}
+	}
+	if ops > 1 {
+		return usageErrorf("use only one of -add, -remove and -resize")
+	}
+	var area parse.ChunkArea
+	isCircle := *circleString != ""
+	if *add != "" || *resize != "" {
+		var err error
+		switch {
+		case *areaString != "" && isCircle:
+			return usageErrorf("use -area or -circle, not both")
+		case *areaString != "":
+			if area, err = parse.ParseChunkArea(*areaString); err != nil {
+				return usageError{err}
+			}
+		case isCircle:
+			if area, err = parseCircle(*circleString); err != nil {
+				return err
+			}
+		default:
+			return usageErrorf("-add and -resize need -area or -circle")
+		}
+	}
+
+	switch {
+	case *add != "":
+		dimension, err := f.dimension()
+		if err != nil {
+			return err
+		}
+		t := parse.NewTickingArea("", *add, dimension, area, isCircle, *preload)
+		return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+			return edit.AddTickingArea(db, t)
+		})
+	case *remove != "":
+		return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+			return edit.RemoveTickingArea(db, *remove)
+		})
+	case *resize != "":
+		return f.run(func(db *leveldb.DB) (*edit.Plan, error) {
+			return edit.ResizeTickingArea(db, *resize, area, isCircle)
+		})
+	}
+
+	w, err := f.openWorld(true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	areas, errs := parse.ReadTickingAreas(w.DB)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	entries := []tickingAreaEntry{}
+	total := 0
+	for _, t := range areas {
+		entries = append(entries, tickingAreaEntry{TickingArea: t, Chunks: t.ChunkCount()})
+		total += t.ChunkCount()
+	}
+
+	if *f.json {
+		return writeJSON(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No ticking areas in %q\n", w.Name)
+		return nil
+	}
+	for _, e := range entries {
+		shape := "box"
+		if e.IsCircle {
+			shape = "circle"
+		}
+		preload := ""
+		if e.Preload {
+			preload = ", preloaded"
+		}
+		fmt.Printf("%q (%s): %s, %s of chunks %s, %d chunks%s\n", e.Name, e.ID, e.Dimension, shape, e.Area(), e.Chunks, preload)
+	}
+	fmt.Printf("%d ticking areas keep %d chunks loaded\n", len(entries), total)
+	return nil
+}
@@ -62,6 +62,12 @@ type Tile struct {
 	Empty bool            // True if the whole area was "empty" according to the TileSource16
 }
 
+// SetSource switches to a different TileSource16, keeping the cached
+// tiles: callers should Invalidate the areas where the two differ.
+func (ts *TileServer) SetSource(source TileSource16) {
+	ts.source = source
+}
+
 // Invalidate any cached tiles or images for the given area.
 func (ts *TileServer) Invalidate(area image.Rectangle) {
 	var pos image.Point
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/zellyn/bedrockprune/backup"
+	"github.com/zellyn/bedrockprune/occupation"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/save"
+	"github.com/zellyn/bedrockprune/tiles"
+)
+
+// worldTimeline is a zoomview.Timeline of earlier copies of a world,
+// ending with the world itself, for view -timeline.
+type worldTimeline struct {
+	current  *worldTileSource16
+	overlays viewOverlays
+	names    []string                     // The earlier copies' names
+	paths    []string                     // Their world folders, or for a backup store, where they're restored to
+	store    *backup.Store                // The backup store the earlier copies come from, if any
+	frames   []*worldTileSource16         // Each frame, or nil until it's loaded
+	marks    []map[string]image.Rectangle // Each loaded frame's overlays, by what they show
+	changes  []map[world.ChunkPos]bool    // The chunks that differ between frames i and i+1
+	opened   []*save.World                // The earlier worlds, which Close closes
+	tempDir  string                       // Where snapshots from a backup store are restored, if any
+}
+
+// newWorldTimeline reads the earlier copies of a world from dir: either
+// a backup store, or a folder of world folders, like the .snapshots
+// folder edits leave. Frames are ordered by name, which for both is the
+// time they were taken, and end with current.
+//
+// The copies in a folder are opened and compared with each other up
+// front. A backup store's snapshots are compared using their manifests,
+// and each is only restored, to a temporary folder, when it's first
+// shown.
+func newWorldTimeline(dir string, current *worldTileSource16, overlays viewOverlays) (*worldTimeline, error) {
+	t := &worldTimeline{current: current, overlays: overlays}
+	if backup.IsStore(dir) {
+		if err := t.readStore(dir); err != nil {
+			t.Close()
+			return nil, err
+		}
+	} else {
+		if err := t.readFolder(dir); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// readFolder opens the world folders in dir, and compares each with
+// the next.
+func (t *worldTimeline) readFolder(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if fi, err := os.Stat(filepath.Join(path, "db")); !entry.IsDir() || err != nil || !fi.IsDir() {
+			continue
+		}
+		t.names = append(t.names, entry.Name())
+		t.paths = append(t.paths, path)
+	}
+	if len(t.paths) == 0 {
+		return fmt.Errorf("no world folders in %s", dir)
+	}
+	t.frames = make([]*worldTileSource16, len(t.paths)+1)
+	t.marks = make([]map[string]image.Rectangle, len(t.frames))
+	for i := range t.frames {
+		if _, err := t.Source(i); err != nil {
+			return err
+		}
+	}
+
+	for i := range len(t.frames) - 1 {
+		fmt.Printf("Comparing %s with %s...", t.frames[i].world.Source, t.frames[i+1].world.Source)
+		diff, err := parse.DiffWorlds(t.frames[i].world.DB, t.frames[i+1].world.DB)
+		if err != nil {
+			fmt.Println()
+			return err
+		}
+		changed := make(map[world.ChunkPos]bool)
+		for chunkPos := range diff.ChunkChanges(t.current.dimension) {
+			changed[chunkPos] = true
+		}
+		t.changes = append(t.changes, changed)
+		fmt.Printf(" %d chunks differ\n", len(changed))
+	}
+	return nil
+}
+
+// readStore compares the snapshots in the backup store in dir, and the
+// current world, using their manifests.
+func (t *worldTimeline) readStore(dir string) error {
+	store, err := backup.Open(dir)
+	if err != nil {
+		return err
+	}
+	t.store = store
+	if t.names, err = store.Names(); err != nil {
+		return err
+	}
+	if len(t.names) == 0 {
+		return fmt.Errorf("no backups in %s", dir)
+	}
+	if t.tempDir, err = os.MkdirTemp("", "bedrockprune-timeline-"); err != nil {
+		return err
+	}
+	for _, name := range t.names {
+		t.paths = append(t.paths, filepath.Join(t.tempDir, name))
+	}
+	t.frames = make([]*worldTileSource16, len(t.paths)+1)
+	t.marks = make([]map[string]image.Rectangle, len(t.frames))
+	if _, err := t.Source(len(t.frames) - 1); err != nil {
+		return err
+	}
+
+	var prev *backup.Manifest
+	for i := range t.frames {
+		var m *backup.Manifest
+		if i < len(t.names) {
+			m, err = store.Manifest(t.names[i])
+		} else {
+			m, err = backup.DBManifest(t.current.world)
+		}
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			changed := backup.ChangedChunks(prev, m, t.current.dimension)
+			t.changes = append(t.changes, changed)
+			fmt.Printf("%s to %s: %d chunks differ\n", t.names[i-1], t.frameName(i), len(changed))
+		}
+		prev = m
+	}
+	return nil
+}
+
+// frameName returns the name of frame i: the name of the copy of the
+// world it shows, or "now".
+func (t *worldTimeline) frameName(i int) string {
+	if i == len(t.names) {
+		return "now"
+	}
+	return t.names[i]
+}
+
+func (t *worldTimeline) Len() int {
+	return len(t.frames)
+}
+
+func (t *worldTimeline) Label(i int) string {
+	name := t.current.world.Name
+	if t.frames[i] != nil {
+		name = t.frames[i].world.Name
+	}
+	return fmt.Sprintf("Bedrock Pruner: %s (%s)", name, t.frameName(i))
+}
+
+// Source returns frame i, opening its world, and restoring it from the
+// backup store first if need be.
+func (t *worldTimeline) Source(i int) (tiles.TileSource16, error) {
+	if t.frames[i] != nil {
+		return t.frames[i], nil
+	}
+	wts := t.current
+	if i < len(t.paths) {
+		path := t.paths[i]
+		if t.store != nil {
+			fmt.Printf("Restoring snapshot %s...\n", t.names[i])
+			if err := t.store.Restore(t.names[i], path); err != nil {
+				return nil, err
+			}
+		}
+		w, err := save.Open(path, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		t.opened = append(t.opened, w)
+		dimension := t.current.dimension
+		occupiedChunks := w.OccupiedChunks(dimension)
+		wts = newWorldTileSource16(w, t.current.textureSource, dimension, occupiedChunks, occupation.New(occupiedChunks))
+		wts.highlights = t.current.highlights
+		t.overlays.add(wts)
+	}
+	t.frames[i] = wts
+	t.marks[i] = overlayMarks(wts)
+	return wts, nil
+}
+
+// Changed returns the chunks that differ between any two adjacent
+// frames from i to j, and the areas of the overlays that differ between
+// frames i and j, since those come from global records that the chunk
+// changes don't cover.
+func (t *worldTimeline) Changed(i, j int) []image.Rectangle {
+	seen := make(map[world.ChunkPos]bool)
+	var res []image.Rectangle
+	for _, changed := range t.changes[min(i, j):max(i, j)] {
+		for chunkPos := range changed {
+			if seen[chunkPos] {
+				continue
+			}
+			seen[chunkPos] = true
+			x, z := int(chunkPos[0])*16, int(chunkPos[1])*16
+			res = append(res, image.Rect(x, z, x+15, z+15))
+		}
+	}
+	for mark, area := range t.marks[i] {
+		if _, ok := t.marks[j][mark]; !ok {
+			res = append(res, area)
+		}
+	}
+	for mark, area := range t.marks[j] {
+		if _, ok := t.marks[i][mark]; !ok {
+			res = append(res, area)
+		}
+	}
+	return res
+}
+
+// overlayMarks returns the areas, in blocks, with inclusive maxima, of
+// the player markers, maps and ticking areas drawn over a frame's tiles,
+// keyed by a description of each that changes whenever how it's drawn
+// does.
+func overlayMarks(wts *worldTileSource16) map[string]image.Rectangle {
+	res := make(map[string]image.Rectangle)
+	for pos, names := range wts.players {
+		res[fmt.Sprintf("player %v %q", pos, names)] = image.Rectangle{Min: pos, Max: pos}
+	}
+	for _, m := range wts.maps {
+		b := m.Bounds()
+		res[fmt.Sprintf("map %s %v %x", m.Key, b, sha256.Sum256(m.Image().Pix))] = image.Rectangle{Min: b.Min, Max: b.Max.Sub(image.Pt(1, 1))}
+	}
+	for _, ta := range wts.tickingAreas {
+		area := ta.Area()
+		res[fmt.Sprintf("ticking %s %d,%d %d,%d %t", ta.Key, ta.MinX, ta.MinZ, ta.MaxX, ta.MaxZ, ta.IsCircle)] = image.Rect(int(area.Min.X())*16, int(area.Min.Z())*16, int(area.Max.X())*16+15, int(area.Max.Z())*16+15)
+	}
+	return res
+}
+
+// Close closes the earlier worlds and the backup store, and deletes any
+// restored snapshots.
+func (t *worldTimeline) Close() {
+	for _, w := range t.opened {
+		w.Close()
+	}
+	t.opened = nil
+	if t.store != nil {
+		t.store.Close()
+		t.store = nil
+	}
+	if t.tempDir != "" {
+		os.RemoveAll(t.tempDir)
+		t.tempDir = ""
+	}
+}
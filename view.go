@@ -0,0 +1,447 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/goleveldb/leveldb"
+	_ "github.com/zellyn/bedrockprune/lerp"
+	"github.com/zellyn/bedrockprune/occupation"
+	"github.com/zellyn/bedrockprune/parse"
+	"github.com/zellyn/bedrockprune/resources"
+	"github.com/zellyn/bedrockprune/save"
+	"github.com/zellyn/bedrockprune/types"
+	"github.com/zellyn/bedrockprune/zoomview"
+
+	"gioui.org/app"
+)
+
+type worldTileSource16 struct {
+	world          *save.World
+	occupiedChunks map[world.ChunkPos]bool
+	occupation     occupation.Map
+	textureSource  *resources.TextureSource
+	dimension      world.Dimension
+	highlights     map[image.Point]int
+	players        map[image.Point][]string // Names of players in each block column
+	maps           []parse.MapItem          // Most detailed first
+	tickingAreas   []parse.TickingArea
+	diff           map[world.ChunkPos]parse.ChunkDiff // Changes since the world given by -diff
+}
+
+func runView(args []string) error {
+	f := newFlags("view")
+	f.addDim("overworld", false)
+	highlightFile := f.String("highlight", "", "JSON file of block search results (from search -json) to highlight on the map")
+	showPlayers := f.Bool("players", true, "mark where players are on the map")
+	showMaps := f.Bool("maps", false, "draw in-game map items over the blocks they cover")
+	showTicking := f.Bool("ticking", true, "shade the chunks ticking areas keep loaded")
+	diffWorld := f.String("diff", "", "shade the chunks added (green), removed (red) or changed (yellow) since this earlier world, like diff -old")
+	timelineDir := f.String("timeline", "", "a backup store, or a folder of earlier copies of the world (like its .snapshots folder), to step through with a time slider ending at -world")
+	f.Parse(args)
+
+	dimension, err := f.dimension()
+	if err != nil {
+		return err
+	}
+	if *timelineDir != "" && *diffWorld != "" {
+		return usageErrorf("use only one of -diff and -timeline")
+	}
+
+	fmt.Printf("Getting texture source from downloaded assets...")
+	ts, err := resources.NewTextureSource(context.Background(), resources.UseOnlyCached)
+	if err != nil {
+		fmt.Println()
+		return err
+	}
+	fmt.Printf(" done\n")
+
+	fmt.Printf("Getting occupied chunks...")
+	w, err := f.openWorld(true)
+	if err != nil {
+		fmt.Println()
+		return err
+	}
+	defer w.Close()
+
+	occupiedChunks := w.OccupiedChunks(dimension)
+	fmt.Printf(" done\n")
+
+	var diff map[world.ChunkPos]parse.ChunkDiff
+	shown := occupiedChunks
+	if *diffWorld != "" {
+		fmt.Printf("Comparing with %s...", *diffWorld)
+		if diff, err = viewDiff(*diffWorld, w, dimension); err != nil {
+			fmt.Println()
+			return err
+		}
+		// Removed chunks are drawn too, so they need to count as
+		// occupied.
+		shown = maps.Clone(occupiedChunks)
+		for chunkPos, c := range diff {
+			if c.Change == parse.ChangeRemoved {
+				shown[chunkPos] = true
+			}
+		}
+		fmt.Printf(" %d chunks differ\n", len(diff))
+	}
+	occ := occupation.New(shown)
+
+	wts16 := newWorldTileSource16(w, ts, dimension, occupiedChunks, occ)
+	wts16.diff = diff
+
+	if *highlightFile != "" {
+		wts16.highlights, err = readHighlights(*highlightFile, dimension)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Highlighting %d columns\n", len(wts16.highlights))
+	}
+	overlays := viewOverlays{players: *showPlayers, maps: *showMaps, ticking: *showTicking}
+	overlays.add(wts16)
+	if *showPlayers {
+		fmt.Printf("Marking %d players\n", len(wts16.players))
+	}
+	if *showMaps {
+		fmt.Printf("Drawing %d maps\n", len(wts16.maps))
+	}
+	if *showTicking {
+		fmt.Printf("Shading %d ticking areas\n", len(wts16.tickingAreas))
+	}
+
+	run := func(window *app.Window) error {
+		window.Option(app.Title("Bedrock Pruner: " + w.Name))
+		return zoomview.Run(window, wts16)
+	}
+	if *timelineDir != "" {
+		timeline, err := newWorldTimeline(*timelineDir, wts16, overlays)
+		if err != nil {
+			return err
+		}
+		defer timeline.Close()
+		run = func(window *app.Window) error {
+			defer timeline.Close()
+			return zoomview.RunTimeline(window, timeline)
+		}
+	}
+
+	go func() {
+		window := new(app.Window)
+		err := run(window)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}()
+	app.Main()
+	return nil
+}
+
+func newWorldTileSource16(w *save.World, ts *resources.TextureSource, dimension world.Dimension, occupiedChunks map[world.ChunkPos]bool, occ occupation.Map) *worldTileSource16 {
+	return &worldTileSource16{
+		world:          w,
+		occupiedChunks: occupiedChunks,
+		occupation:     occ,
+		textureSource:  ts,
+		dimension:      dimension,
+	}
+}
+
+// viewOverlays says which of the world's own markings to draw.
+type viewOverlays struct {
+	players bool
+	maps    bool
+	ticking bool
+}
+
+// add reads the overlays from the tile source's world.
+func (o viewOverlays) add(wts *worldTileSource16) {
+	if o.players {
+		wts.players = playerMarkers(wts.world.DB, wts.dimension)
+	}
+	if o.maps {
+		wts.maps = mapOverlays(wts.world.DB, wts.dimension)
+	}
+	if o.ticking {
+		areas, _ := parse.ReadTickingAreas(wts.world.DB)
+		for _, t := range areas {
+			if t.WorldDimension() == wts.dimension {
+				wts.tickingAreas = append(wts.tickingAreas, t)
+			}
+		}
+	}
+}
+
+func (wts *worldTileSource16) AllEmpty(area image.Rectangle) (bool, error) {
+	minX := int32(area.Min.X >> 4)
+	minY := int32(area.Min.Y >> 4)
+	maxX := int32((area.Max.X + 15) >> 4)
+	maxY := int32((area.Max.Y + 15) >> 4)
+
+	return wts.occupation.AllEmpty(minX, minY, maxX, maxY), nil
+
+	for x := minX; x < maxX; x++ {
+		for y := minY; y < maxY; y++ {
+			if !wts.occupiedChunks[world.ChunkPos{x, y}] {
+				continue
+			}
+			chunk, err := wts.getChunk(world.ChunkPos{x, y})
+			if err != nil {
+				continue
+			}
+			if !chunk.Empty() {
+				return false, nil
+			}
+
+		}
+	}
+	return true, nil
+}
+
+func (wts *worldTileSource16) getChunk(chunkPos world.ChunkPos) (*parse.Chunk, error) {
+	return wts.world.Chunk(chunkPos, wts.dimension)
+}
+
+func (wts *worldTileSource16) Get(x, z int) (*image.RGBA, error) {
+	chunkPos := world.ChunkPos{int32(x >> 4), int32(z >> 4)}
+	if wts.diff[chunkPos].Change == parse.ChangeRemoved && !wts.occupiedChunks[chunkPos] {
+		return removedChunkImage, nil
+	}
+	if !wts.occupiedChunks[chunkPos] {
+		return nil, fmt.Errorf("%w: no world data at position (%d,%d), dimension %s", types.ErrNotFound, x, z, wts.dimension)
+	}
+
+	chunk, err := wts.getChunk(chunkPos)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := 0
+	hm := chunk.GetHeightMap(layer)
+	y := hm.Get(x&0xF, z&0xF)
+	block, err := chunk.GetBlock(x&0xF, z&0xF, y, layer)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := wts.textureSource.Get(block)
+	if err != nil {
+		if img == nil {
+			return nil, err
+		}
+		fmt.Printf("Error getting image at (%d,%d): %v\n", x, z, err)
+	}
+
+	if _, c, ok := wts.mapAt(x, z); ok {
+		c.A = mapOverlayAlpha
+		img = tinted(img, image.NewUniform(c))
+	}
+	if len(wts.tickingAreasAt(chunkPos)) > 0 {
+		img = tinted(img, tickingTint)
+	}
+	if c, ok := wts.diff[chunkPos]; ok {
+		img = tinted(img, diffTints[c.Change])
+	}
+	if wts.highlights[image.Pt(x, z)] > 0 {
+		img = highlight(img, highlightTint, highlightBorder)
+	}
+	if len(wts.players[image.Pt(x, z)]) > 0 {
+		img = highlight(img, playerTint, playerBorder)
+	}
+
+	return img, nil
+}
+
+// readHighlights reads a JSON file of search results, as written by
+// search -json, and returns the count of matches in each block column of
+// the given dimension.
+func readHighlights(filename string, dimension world.Dimension) (map[image.Point]int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var matches []parse.BlockMatch
+	if err := json.Unmarshal(data, &matches); err != nil {
+		return nil, fmt.Errorf("error reading search results from %q: %w", filename, err)
+	}
+
+	res := make(map[image.Point]int)
+	for _, m := range matches {
+		if m.Dimension != fmt.Sprint(dimension) {
+			continue
+		}
+		res[image.Pt(m.X, m.Z)]++
+	}
+	return res, nil
+}
+
+// playerMarkers returns the names of the players in each block column
+// of the given dimension. Players that can't be read are skipped.
+func playerMarkers(db *leveldb.DB, dimension world.Dimension) map[image.Point][]string {
+	players, _ := parse.ReadPlayers(db)
+	res := make(map[image.Point][]string)
+	for _, p := range players {
+		if p.Dimension != fmt.Sprint(dimension) {
+			continue
+		}
+		pos := p.BlockPos()
+		res[image.Pt(pos.X(), pos.Z())] = append(res[image.Pt(pos.X(), pos.Z())], p.Name())
+	}
+	return res
+}
+
+var highlightTint = image.NewUniform(color.NRGBA{R: 0xFF, A: 0x80})
+var highlightBorder = image.NewUniform(color.NRGBA{R: 0xFF, G: 0xFF, A: 0xFF})
+var playerTint = image.NewUniform(color.NRGBA{B: 0xFF, A: 0x80})
+var playerBorder = image.NewUniform(color.NRGBA{G: 0xFF, B: 0xFF, A: 0xFF})
+var tickingTint = image.NewUniform(color.NRGBA{G: 0xFF, A: 0x50})
+
+// diffTints shade chunks by how they changed, with view -diff.
+var diffTints = map[parse.Change]*image.Uniform{
+	parse.ChangeAdded:   image.NewUniform(color.NRGBA{G: 0xC0, A: 0x80}),
+	parse.ChangeRemoved: image.NewUniform(color.NRGBA{R: 0xFF, A: 0x80}),
+	parse.ChangeChanged: image.NewUniform(color.NRGBA{R: 0xFF, G: 0xD0, A: 0x80}),
+}
+
+// removedChunkImage is drawn for every block of a chunk that's gone
+// since the world given by -diff.
+var removedChunkImage = func() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{R: 0xC0, A: 0xFF}), image.Point{}, draw.Src)
+	return img
+}()
+
+// viewDiff compares the world with an earlier one, and returns the
+// chunks of the given dimension that differ.
+func viewDiff(oldName string, w *save.World, dimension world.Dimension) (map[world.ChunkPos]parse.ChunkDiff, error) {
+	oldWorld, err := openOtherWorld(oldName)
+	if err != nil {
+		return nil, err
+	}
+	defer oldWorld.Close()
+	diff, err := parse.DiffWorlds(oldWorld.DB, w.DB)
+	if err != nil {
+		return nil, err
+	}
+	return diff.ChunkChanges(dimension), nil
+}
+
+// tickingAreasAt returns the names of the ticking areas that keep the
+// chunk loaded.
+func (wts *worldTileSource16) tickingAreasAt(chunkPos world.ChunkPos) []string {
+	var res []string
+	for _, t := range wts.tickingAreas {
+		if t.Contains(chunkPos) {
+			res = append(res, t.Name)
+		}
+	}
+	return res
+}
+
+// mapOverlays returns the map items of the given dimension, most
+// detailed first. Maps that can't be read are skipped.
+func mapOverlays(db *leveldb.DB, dimension world.Dimension) []parse.MapItem {
+	maps, _ := parse.ReadMaps(db)
+	var res []parse.MapItem
+	for _, m := range maps {
+		if m.WorldDimension() == dimension {
+			res = append(res, m)
+		}
+	}
+	slices.SortStableFunc(res, func(a, b parse.MapItem) int {
+		return cmp.Compare(a.Scale, b.Scale)
+	})
+	return res
+}
+
+// mapOverlayAlpha is the opacity of map colors drawn over blocks.
+const mapOverlayAlpha = 0xC0
+
+// mapAt returns the most detailed map with an explored pixel covering
+// the block column at x, z, and that pixel's color.
+func (wts *worldTileSource16) mapAt(x, z int) (parse.MapItem, color.NRGBA, bool) {
+	for _, m := range wts.maps {
+		if c, ok := m.ColorAt(x, z); ok {
+			return m, c, true
+		}
+	}
+	return parse.MapItem{}, color.NRGBA{}, false
+}
+
+// tinted returns a copy of the given block image with tint drawn over
+// it.
+func tinted(img *image.RGBA, tint *image.Uniform) *image.RGBA {
+	b := img.Bounds()
+	res := image.NewRGBA(b)
+	draw.Draw(res, b, img, b.Min, draw.Src)
+	draw.Draw(res, b, tint, image.Point{}, draw.Over)
+	return res
+}
+
+// highlight returns a copy of the given block image, tinted with tint
+// and with a one-pixel border.
+func highlight(img *image.RGBA, tint, border *image.Uniform) *image.RGBA {
+	res := tinted(img, tint)
+	b := res.Bounds()
+	for _, edge := range []image.Rectangle{
+		image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Min.Y+1),
+		image.Rect(b.Min.X, b.Max.Y-1, b.Max.X, b.Max.Y),
+		image.Rect(b.Min.X, b.Min.Y, b.Min.X+1, b.Max.Y),
+		image.Rect(b.Max.X-1, b.Min.Y, b.Max.X, b.Max.Y),
+	} {
+		draw.Draw(res, edge, border, image.Point{}, draw.Src)
+	}
+	return res
+}
+
+func (wts *worldTileSource16) Info(x, z int) (string, error) {
+	chunkPos := world.ChunkPos{int32(x >> 4), int32(z >> 4)}
+	if c, ok := wts.diff[chunkPos]; ok && !wts.occupiedChunks[chunkPos] {
+		return fmt.Sprintf("empty (%s: %s)", c.Change, c.Summary()), nil
+	}
+	if !wts.occupiedChunks[chunkPos] {
+		return "empty", nil
+	}
+
+	chunk, err := wts.getChunk(chunkPos)
+	if err != nil {
+		return "", err
+	}
+
+	layer := 0
+	hm := chunk.GetHeightMap(layer)
+	y := hm.Get(x&0xF, z&0xF)
+	block, err := chunk.GetBlock(x&0xF, z&0xF, y, layer)
+	if err != nil {
+		return "", err
+	}
+
+	info := fmt.Sprintf("%v", block)
+	if n := wts.highlights[image.Pt(x, z)]; n > 0 {
+		info += fmt.Sprintf(" (%d highlighted)", n)
+	}
+	if players := wts.players[image.Pt(x, z)]; len(players) > 0 {
+		info += fmt.Sprintf(" (players: %s)", strings.Join(players, ", "))
+	}
+	if m, _, ok := wts.mapAt(x, z); ok {
+		info += fmt.Sprintf(" (map %d)", m.ID)
+	}
+	if names := wts.tickingAreasAt(chunkPos); len(names) > 0 {
+		info += fmt.Sprintf(" (ticking: %s)", strings.Join(names, ", "))
+	}
+	if c, ok := wts.diff[chunkPos]; ok {
+		info += fmt.Sprintf(" (%s: %s)", c.Change, c.Summary())
+	}
+	return info, nil
+}
@@ -0,0 +1,209 @@
+package zoomview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/zellyn/bedrockprune/lerp"
+	"github.com/zellyn/bedrockprune/tiles"
+
+	"gioui.org/app"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+// Timeline is a series of frames of the same area at different times,
+// like the backups of a world, oldest first.
+type Timeline interface {
+	Len() int
+	Label(i int) string // Used as the window title while frame i is shown
+	// Source returns frame i's tiles, loading it first if need be.
+	Source(i int) (tiles.TileSource16, error)
+	// Changed returns the areas, in blocks, with inclusive maxima, that
+	// differ between frames i and j. Both have been loaded by Source.
+	Changed(i, j int) []image.Rectangle
+}
+
+const (
+	flashDuration = time.Second / 2
+	playInterval  = time.Second
+)
+
+var (
+	flashColor  = color.NRGBA{R: 0xFF, G: 0xFF, A: 0xA0}
+	trackColor  = color.NRGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xC0}
+	tickColor   = color.NRGBA{R: 0xA0, G: 0xA0, B: 0xA0, A: 0xFF}
+	knobColor   = yellow
+	sliderInset = unit.Dp(12)
+	sliderSize  = unit.Dp(20)
+)
+
+// RunTimeline is like Run, but shows a timeline, starting at its last
+// frame. A slider at the bottom of the window, or "[" and "]", move
+// between frames; space plays through them.
+func RunTimeline(w *app.Window, timeline Timeline) error {
+	last := timeline.Len() - 1
+	source, err := timeline.Source(last)
+	if err != nil {
+		return err
+	}
+	zv := newZoomView(source)
+	zv.timeline = timeline
+	zv.frame = last
+	zv.titleFrame = -1
+	return run(w, zv)
+}
+
+// setFrame switches to frame i, invalidating the tiles that differ
+// from the current frame and flashing them. If frame i can't be loaded,
+// it stays on the current frame, and stops playing.
+func (zv *zoomView) setFrame(i int, now time.Time) {
+	i = min(max(i, 0), zv.timeline.Len()-1)
+	if i == zv.frame {
+		return
+	}
+	source, err := zv.timeline.Source(i)
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", zv.timeline.Label(i), err)
+		zv.playing = false
+		return
+	}
+	zv.changed = zv.timeline.Changed(zv.frame, i)
+	for _, area := range zv.changed {
+		zv.tileserver.Invalidate(area)
+	}
+	zv.tileserver.SetSource(source)
+	zv.frame = i
+	zv.flashing = true
+	zv.flashLerp = lerp.NewTimeLerp[float32](1, 0, now, flashDuration, nil)
+}
+
+func (zv *zoomView) stepFrame(delta int) {
+	if zv.timeline == nil {
+		return
+	}
+	zv.playing = false
+	zv.setFrame(zv.frame+delta, time.Now())
+}
+
+func (zv *zoomView) togglePlay() {
+	if zv.timeline == nil {
+		return
+	}
+	zv.playing = !zv.playing
+	if zv.playing {
+		now := time.Now()
+		if zv.frame == zv.timeline.Len()-1 {
+			zv.setFrame(0, now)
+		}
+		zv.nextPlay = now.Add(playInterval)
+	}
+}
+
+// updateTimeline handles slider input, plays frames, and keeps the
+// window title current.
+func (zv *zoomView) updateTimeline(gtx layout.Context, w *app.Window) {
+	now := time.Now()
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: &zv.sliderPressed, Kinds: pointer.Press | pointer.Drag | pointer.Release})
+		if !ok {
+			break
+		}
+		e, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		zv.sliderPressed = e.Kind != pointer.Release
+		zv.playing = false
+		zv.setFrame(zv.frameAt(e.Position.X), now)
+	}
+
+	if zv.playing {
+		if !now.Before(zv.nextPlay) {
+			zv.setFrame(zv.frame+1, now)
+			zv.nextPlay = now.Add(playInterval)
+			if zv.frame == zv.timeline.Len()-1 {
+				zv.playing = false
+			}
+		}
+		if zv.playing {
+			gtx.Execute(op.InvalidateCmd{At: zv.nextPlay})
+		}
+	}
+
+	if zv.flashing {
+		if zv.flashLerp.Done(now) {
+			zv.flashing = false
+		}
+		gtx.Execute(op.InvalidateCmd{})
+	}
+
+	if zv.titleFrame != zv.frame {
+		w.Option(app.Title(zv.timeline.Label(zv.frame)))
+		zv.titleFrame = zv.frame
+	}
+}
+
+// frameAt returns the frame nearest to x on the slider.
+func (zv *zoomView) frameAt(x float32) int {
+	n := zv.timeline.Len()
+	if n < 2 || zv.sliderTrack.Dx() == 0 {
+		return zv.frame
+	}
+	frac := (x - float32(zv.sliderTrack.Min.X)) / float32(zv.sliderTrack.Dx())
+	return int(frac*float32(n-1) + 0.5)
+}
+
+// sliderX returns the x position of frame i on the slider.
+func (zv *zoomView) sliderX(i int) int {
+	n := zv.timeline.Len()
+	if n < 2 {
+		return zv.sliderTrack.Min.X
+	}
+	return zv.sliderTrack.Min.X + zv.sliderTrack.Dx()*i/(n-1)
+}
+
+// drawTimeline draws the flash over what changed in the last switch of
+// frames, and the slider.
+func (zv *zoomView) drawTimeline(gtx layout.Context) {
+	ops := gtx.Ops
+	if zv.flashing {
+		c := flashColor
+		c.A = uint8(float32(c.A) * zv.flashLerp.At(time.Now()))
+		for _, area := range zv.changed {
+			r := image.Rectangle{
+				Min: zv.screenPosition(area.Min.X, area.Min.Y).Min,
+				Max: zv.screenPosition(area.Max.X, area.Max.Y).Max,
+			}
+			if r.Overlaps(image.Rectangle{Max: zv.size}) {
+				paint.FillShape(ops, c, clip.Rect(r).Op())
+			}
+		}
+	}
+
+	inset, size := gtx.Dp(sliderInset), gtx.Dp(sliderSize)
+	bar := image.Rect(inset, zv.size.Y-inset-size, zv.size.X-inset, zv.size.Y-inset)
+	zv.sliderTrack = image.Rect(bar.Min.X+size/2, bar.Min.Y, bar.Max.X-size/2, bar.Max.Y)
+	paint.FillShape(ops, trackColor, clip.UniformRRect(bar, size/2).Op(ops))
+
+	mid := (bar.Min.Y + bar.Max.Y) / 2
+	for i := range zv.timeline.Len() {
+		x := zv.sliderX(i)
+		paint.FillShape(ops, tickColor, clip.Rect(image.Rect(x-1, mid-size/4, x+1, mid+size/4)).Op())
+	}
+	x := zv.sliderX(zv.frame)
+	knob := image.Rect(x-size/3, bar.Min.Y+2, x+size/3, bar.Max.Y-2)
+	paint.FillShape(ops, black, clip.UniformRRect(knob.Inset(-1), size/3).Op(ops))
+	paint.FillShape(ops, knobColor, clip.UniformRRect(knob, size/3).Op(ops))
+
+	area := clip.Rect(bar).Push(ops)
+	event.Op(ops, &zv.sliderPressed)
+	area.Pop()
+}
@@ -61,6 +61,17 @@ type zoomView struct {
 	zoomLerp     lerp.TimeLerp[float32]
 	tlXLerp      lerp.TimeLerp[float32]
 	tlYLerp      lerp.TimeLerp[float32]
+
+	timeline      Timeline // Nil unless run by RunTimeline
+	frame         int
+	titleFrame    int               // The frame the window title was last set for
+	changed       []image.Rectangle // What changed in the last switch of frames, in blocks
+	flashing      bool
+	flashLerp     lerp.TimeLerp[float32]
+	playing       bool
+	nextPlay      time.Time
+	sliderTrack   image.Rectangle // In pixels
+	sliderPressed bool            // Used as the slider's event tag
 }
 
 func (zv *zoomView) setContextInfo(metric unit.Metric, size image.Point) {
@@ -217,16 +228,23 @@ func (zv *zoomView) screenPosition(x, y int) image.Rectangle {
 
 const minDpPerBlock = unit.Dp(5)
 
-func Run(w *app.Window, ts16 tiles.TileSource16) error {
-	if err := bringToFront(); err != nil {
-		return err
-	}
-	zv := &zoomView{
+func newZoomView(ts16 tiles.TileSource16) *zoomView {
+	return &zoomView{
 		tileserver: tiles.NewServer(ts16, tiles.OptionEmpty16x16(empty16x16())),
 		zoom:       1,
 		topleft:    f32.Pt(1.5, 2.75),
 		cell:       image.Pt(3, 3),
 	}
+}
+
+func Run(w *app.Window, ts16 tiles.TileSource16) error {
+	return run(w, newZoomView(ts16))
+}
+
+func run(w *app.Window, zv *zoomView) error {
+	if err := bringToFront(); err != nil {
+		return err
+	}
 	var ops op.Ops
 	for {
 		switch e := w.Event().(type) {
@@ -240,6 +258,9 @@ func Run(w *app.Window, ts16 tiles.TileSource16) error {
 				zv.doZoom()
 				gtx.Execute(op.InvalidateCmd{})
 			}
+			if zv.timeline != nil {
+				zv.updateTimeline(gtx, w)
+			}
 
 			event.Op(&ops, zv)
 			if !zv.haveContextInfo || zv.metric != gtx.Metric || zv.size != gtx.Constraints.Max {
@@ -312,6 +333,10 @@ func Run(w *app.Window, ts16 tiles.TileSource16) error {
 				}.Op(),
 			)
 
+			if zv.timeline != nil {
+				zv.drawTimeline(gtx)
+			}
+
 			// Pass the drawing operations to the GPU.
 			e.Frame(gtx.Ops)
 
@@ -330,6 +355,8 @@ func (zv *zoomView) handleKeyPresses(gtx layout.Context, units int) (quit bool)
 			key.Filter{Name: "="}, key.Filter{Name: "+", Optional: key.ModShift},
 			key.Filter{Name: "-"},
 			key.Filter{Name: "D", Optional: key.ModShift},
+			key.Filter{Name: "["}, key.Filter{Name: "]"},
+			key.Filter{Name: key.NameSpace},
 		)
 		if !ok {
 			break
@@ -359,6 +386,12 @@ func (zv *zoomView) handleKeyPresses(gtx layout.Context, units int) (quit bool)
 				zv.zoomin()
 			case "-":
 				zv.zoomout()
+			case "[":
+				zv.stepFrame(-1)
+			case "]":
+				zv.stepFrame(1)
+			case key.NameSpace:
+				zv.togglePlay()
 			}
 		}
 	}